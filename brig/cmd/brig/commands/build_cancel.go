@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+
+	"github.com/spf13/cobra"
+)
+
+const buildCancelUsage = `Cancels a running or pending build.
+
+The worker and job pods of the build are sent SIGTERM, and killed once their
+termination grace period is over. The build and its logs are kept, and its
+status becomes Cancelled.
+`
+
+func init() {
+	build.AddCommand(buildCancel)
+}
+
+var buildCancel = &cobra.Command{
+	Use:   "cancel BUILD_ID",
+	Short: "cancels a build",
+	Long:  buildCancelUsage,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("build ID is a required argument")
+		}
+		return cancelBuild(cmd.OutOrStdout(), args[0])
+	},
+}
+
+func cancelBuild(out io.Writer, bid string) error {
+	c, err := kubeClient()
+	if err != nil {
+		return err
+	}
+
+	store := kube.New(c, globalNamespace)
+	if err := store.CancelBuild(bid); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Cancelled build %s\n", bid)
+	return nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/brigadecore/brigade/pkg/storage"
@@ -39,7 +40,11 @@ func deleteBuild(out io.Writer, bid string) error {
 	}
 
 	store := kube.New(c, globalNamespace)
-	return store.DeleteBuild(bid, storage.DeleteBuildOptions{
+	err = store.DeleteBuild(bid, storage.DeleteBuildOptions{
 		SkipRunningBuilds: !forceDeleteRunning,
 	})
+	if err == storage.ErrBuildRunning {
+		return fmt.Errorf("build %s is still running, use --force to delete it", bid)
+	}
+	return err
 }
@@ -57,7 +57,7 @@ func deleteAllBuilds(out io.Writer, projectID string) error {
 		})
 		// loop will continue even if an error is encountered
 		// maybe add a check => "if errorCount > threshold then return"?
-		if err != nil {
+		if err != nil && err != storage.ErrBuildRunning {
 			if errDeletes == nil {
 				errDeletes = errors.New("")
 			}
@@ -5,16 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/spf13/cobra"
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
 )
 
 const buildGetUsage = `Get details for a build.
 
-Print the attributes of a build.
+Print the attributes of a build. If the build reruns another build, or was
+rerun, the rerun chain of the build is printed after them, oldest build first.
 `
 
 func init() {
@@ -72,5 +76,43 @@ func getBuild(out io.Writer, bid string) error {
 		fmt.Fprintf(out, "script: |-\n%s\npayload: |- %s\n", script, payload)
 	}
 
+	// The build is printed even if its rerun chain cannot be read.
+	lineage, err := store.GetBuildLineage(bid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not get the rerun chain of build %s: %s\n", bid, err)
+		return nil
+	}
+	if len(lineage) > 1 {
+		data, err := yaml.Marshal(map[string][]lineageEntry{"lineage": newLineage(lineage)})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	}
+
 	return nil
 }
+
+// lineageEntry is a build of a rerun chain.
+type lineageEntry struct {
+	ID            string            `yaml:"id"`
+	ParentBuildID string            `yaml:"parent_build_id,omitempty"`
+	RerunReason   string            `yaml:"rerun_reason,omitempty"`
+	Attempt       int               `yaml:"attempt,omitempty"`
+	Status        brigade.JobStatus `yaml:"status"`
+}
+
+// newLineage returns the entries of the builds of a rerun chain.
+func newLineage(builds []*brigade.Build) []lineageEntry {
+	entries := make([]lineageEntry, len(builds))
+	for i, b := range builds {
+		entries[i] = lineageEntry{
+			ID:            b.ID,
+			ParentBuildID: b.ParentBuildID,
+			RerunReason:   b.RerunReason,
+			Attempt:       b.Attempt,
+			Status:        storage.BuildStatus(b),
+		}
+	}
+	return entries
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"sort"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
@@ -15,23 +14,49 @@ import (
 	"k8s.io/apimachinery/pkg/util/duration"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
 )
 
 const buildListUsage = `List all installed builds.
 
-Print a list of the current builds starting from latest (in creation time) to oldest. By default it will print all the builds, use --count to get a subset of them.
+Print a list of the current builds starting from latest (in start time) to oldest. By default it will print all the builds, use --count to get a subset of them.
+
+When --count is set and more builds are available, a continue token is printed. Pass it
+with --continue to get the next page of builds:
+
+	$ brig build list --count 20 --status Failed --event push
+	$ brig build list --count 20 --status Failed --event push --continue <token>
 `
 
 var (
-	buildListCount int
-	output         string
+	buildListCount     int
+	buildListContinue  string
+	buildListStatus    []string
+	buildListEvent     string
+	buildListProvider  string
+	buildListCommit    string
+	buildListRef       string
+	buildListSince     string
+	buildListUntil     string
+	buildListAscending bool
+	output             string
 )
 
 func init() {
 	build.AddCommand(buildList)
-	buildList.Flags().IntVarP(&buildListCount, "count", "c", 0, "The maximum number of builds to return. 0 for all")
-	buildList.Flags().StringVarP(&output, "output", "o", "", "Return output in another format. Supported formats: json")
+	f := buildList.Flags()
+	f.IntVarP(&buildListCount, "count", "c", 0, "The maximum number of builds to return. 0 for all")
+	f.StringVar(&buildListContinue, "continue", "", "The continue token printed with the previous page of builds")
+	f.StringSliceVar(&buildListStatus, "status", nil, "Only list builds with these statuses (Pending, Queued, Running, Succeeded, Failed, Cancelled, TimedOut, Superseded, Unknown)")
+	f.StringVarP(&buildListEvent, "event", "e", "", "Only list builds for this event type")
+	f.StringVar(&buildListProvider, "provider", "", "Only list builds from this event provider")
+	f.StringVar(&buildListCommit, "commit", "", "Only list builds for commits starting with this prefix")
+	f.StringVarP(&buildListRef, "ref", "r", "", "Only list builds for this ref")
+	f.StringVar(&buildListSince, "since", "", "Only list builds started after this RFC 3339 time or duration ago (e.g. 24h)")
+	f.StringVar(&buildListUntil, "until", "", "Only list builds started before this RFC 3339 time or duration ago (e.g. 1h)")
+	f.BoolVar(&buildListAscending, "ascending", false, "List the oldest builds first")
+	f.StringVarP(&output, "output", "o", "", "Return output in another format. Supported formats: json")
 }
 
 var buildList = &cobra.Command{
@@ -49,13 +74,18 @@ var buildList = &cobra.Command{
 			return err
 		}
 
-		bls, err := getBuilds(proj, c, buildListCount)
+		opts, err := buildListOptions()
+		if err != nil {
+			return err
+		}
+
+		bl, err := getBuildList(proj, c, opts)
 		if err != nil {
 			return err
 		}
 
 		if output == "json" {
-			bj, err := json.MarshalIndent(bls, "", "    ")
+			bj, err := json.MarshalIndent(bl.Items, "", "    ")
 			if err != nil {
 				return err
 			}
@@ -63,11 +93,52 @@ var buildList = &cobra.Command{
 			return err
 		}
 
-		listBuilds(getBuildsForStdout(bls), cmd.OutOrStdout())
+		listBuilds(getBuildsForStdout(bl.Items), cmd.OutOrStdout())
+		if bl.Continue != "" {
+			fmt.Fprintf(cmd.OutOrStdout(), "\nMore builds are available, use --continue %s\n", bl.Continue)
+		}
 		return nil
 	},
 }
 
+func buildListOptions() (storage.BuildListOptions, error) {
+	opts := storage.BuildListOptions{
+		Limit:     buildListCount,
+		Continue:  buildListContinue,
+		Type:      buildListEvent,
+		Provider:  buildListProvider,
+		Commit:    buildListCommit,
+		Ref:       buildListRef,
+		Ascending: buildListAscending,
+	}
+	for _, s := range buildListStatus {
+		opts.Status = append(opts.Status, brigade.JobStatus(s))
+	}
+	var err error
+	if opts.Since, err = parseTimeFlag("since", buildListSince); err != nil {
+		return opts, err
+	}
+	if opts.Until, err = parseTimeFlag("until", buildListUntil); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseTimeFlag accepts either an RFC 3339 time or a duration before now.
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--%s must be an RFC 3339 time or a duration: %s", name, value)
+	}
+	return t, nil
+}
+
 func listBuilds(bs []*buildForStdout, out io.Writer) {
 	table := uitable.New()
 	table.AddRow("ID", "TYPE", "PROVIDER", "PROJECT", "STATUS", "AGE")
@@ -77,41 +148,16 @@ func listBuilds(bs []*buildForStdout, out io.Writer) {
 	fmt.Fprintln(out, table)
 }
 
-func getBuilds(project string, client kubernetes.Interface, count int) ([]*brigade.Build, error) {
-	var builds []*brigade.Build
-	var err error
-
+func getBuildList(project string, client kubernetes.Interface, opts storage.BuildListOptions) (*storage.BuildList, error) {
 	store := kube.New(client, globalNamespace)
 	if project == "" {
-		builds, err = store.GetBuilds()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		proj, err := store.GetProject(project)
-		if err != nil {
-			return nil, err
-		}
-		builds, err = store.GetProjectBuilds(proj)
-		if err != nil {
-			return nil, err
-		}
+		return store.ListBuilds(opts)
 	}
-
-	// sorting here on StartTime because we do not want to rely on K8s sorting
-	// which would be the order that Secrets/Pods were created
-	sort.Slice(builds, func(i, j int) bool {
-		if builds[i].Worker == nil || builds[j].Worker == nil {
-			return false
-		}
-		return builds[i].Worker.StartTime.After(builds[j].Worker.StartTime)
-	})
-
-	if count == 0 || count > len(builds) {
-		count = len(builds)
+	proj, err := store.GetProject(project)
+	if err != nil {
+		return nil, err
 	}
-
-	return builds[:count], err
+	return store.ListProjectBuilds(proj, opts)
 }
 
 func getBuildsForStdout(builds []*brigade.Build) []*buildForStdout {
@@ -125,7 +171,8 @@ func getBuildsForStdout(builds []*brigade.Build) []*buildForStdout {
 		bfs.since = "???"
 		if b.Worker != nil {
 			bfs.status = b.Worker.Status.String()
-			if b.Worker.Status == brigade.JobSucceeded || b.Worker.Status == brigade.JobFailed {
+			switch b.Worker.Status {
+			case brigade.JobSucceeded, brigade.JobFailed, brigade.JobCancelled, brigade.JobTimedOut, brigade.JobSuperseded:
 				bfs.since = duration.ShortHumanDuration(time.Since(b.Worker.StartTime))
 			}
 		}
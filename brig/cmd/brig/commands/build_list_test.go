@@ -9,6 +9,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 const (
@@ -46,18 +49,20 @@ var (
 
 func TestGetEmptyBuildList(t *testing.T) {
 	client := fake.NewSimpleClientset()
-	bls, err := getBuilds("", client, 0)
+	bl, err := getBuildList("", client, storage.BuildListOptions{Limit: 0})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	bls := bl.Items
 	if len(bls) != 0 {
 		t.Error("Error in getBuilds for no project(s)")
 	}
 
-	bls, err = getBuilds("", client, 5)
+	bl, err = getBuildList("", client, storage.BuildListOptions{Limit: 5})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	bls = bl.Items
 	if len(bls) != 0 {
 		t.Error("Error in getBuilds for no project(s) with --count 5")
 	}
@@ -67,10 +72,11 @@ func TestGetEmptyBuildList(t *testing.T) {
 func TestGetBuildList(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	createFakeBuilds(t, client)
-	bls, err := getBuilds("", client, 0)
+	bl, err := getBuildList("", client, storage.BuildListOptions{Limit: 0})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	bls := bl.Items
 
 	bfs := getBuildsForStdout(bls)
 
@@ -93,10 +99,11 @@ func TestGetBuildList(t *testing.T) {
 func TestGetBuildListWithProject(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	createFakeBuilds(t, client)
-	bls, err := getBuilds(stubProject1ID, client, 0)
+	bl, err := getBuildList(stubProject1ID, client, storage.BuildListOptions{Limit: 0})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	bls := bl.Items
 
 	bfs := getBuildsForStdout(bls)
 
@@ -117,10 +124,11 @@ func TestGetBuildListWithProject(t *testing.T) {
 func TestGetBuildListCountTwo(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	createFakeBuilds(t, client)
-	bls, err := getBuilds("", client, 2)
+	bl, err := getBuildList("", client, storage.BuildListOptions{Limit: 2})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	bls := bl.Items
 
 	bfs := getBuildsForStdout(bls)
 
@@ -290,3 +298,27 @@ func createStubPod(projectID string, buildID string, startTime time.Time, podSta
 		Status: podStatus,
 	}
 }
+
+// TestGetBuildListPaged tests the command `brig build list --count 1 --continue TOKEN --status Succeeded`
+func TestGetBuildListPaged(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createFakeBuilds(t, client)
+
+	opts := storage.BuildListOptions{Limit: 1, Status: []brigade.JobStatus{brigade.JobSucceeded}}
+	bl, err := getBuildList("", client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl.Items) != 1 || bl.Items[0].ID != stubBuild3ID || bl.Continue == "" {
+		t.Fatalf("unexpected first page %+v", bl)
+	}
+
+	opts.Continue = bl.Continue
+	bl, err = getBuildList("", client, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bl.Items) != 1 || bl.Items[0].ID != stubBuild1ID || bl.Continue != "" {
+		t.Fatalf("unexpected last page %+v", bl)
+	}
+}
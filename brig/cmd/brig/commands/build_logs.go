@@ -18,19 +18,24 @@ const (
 
 Print the logs for a build, and (optionally) the jobs executed as part of the
 build.
+
+With --merged, the init container, worker and job logs are interleaved in
+the order they were written, and every line is prefixed with its source.
 `
 )
 
 var (
-	logsInit bool
-	logsJobs bool
-	logsLast bool
+	logsInit   bool
+	logsJobs   bool
+	logsLast   bool
+	logsMerged bool
 )
 
 func init() {
 	buildLogs.Flags().BoolVarP(&logsInit, "init", "i", false, "Show init container logs as well as the worker log")
 	buildLogs.Flags().BoolVarP(&logsJobs, "jobs", "j", false, "Show job logs as well as the worker log")
 	buildLogs.Flags().BoolVarP(&logsLast, "last", "l", false, "Show last build's log (ignores BUILD_ID)")
+	buildLogs.Flags().BoolVarP(&logsMerged, "merged", "m", false, "Show init container, worker and job logs interleaved in the order they were written (ignores --init and --jobs)")
 	build.AddCommand(buildLogs)
 }
 
@@ -72,6 +77,16 @@ func showBuildLogs(out io.Writer, buildID string) error {
 		return fmt.Errorf("No logs for build %q", buildID)
 	}
 
+	if logsMerged {
+		logs, err := storage.GetMergedBuildLogStream(store, bs, false)
+		if err != nil {
+			return err
+		}
+		defer logs.Close()
+		_, err = io.Copy(out, logs)
+		return err
+	}
+
 	workerLog, err := store.GetWorkerLog(bs.Worker)
 	if err != nil {
 		return err
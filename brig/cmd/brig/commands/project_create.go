@@ -405,6 +405,39 @@ func advancedQuestionsProject(p *brigade.Project, store storage.Store) []*survey
 				Default: "",
 			},
 		},
+		{
+			Name: "buildTimeout",
+			Prompt: &survey.Input{
+				Message: "Build timeout",
+				Help:    "Maximum duration of a build, such as 1h30m. Leave empty to use the controller's build timeout.",
+				Default: p.BuildTimeout,
+			},
+			Validate: validateDuration,
+		},
+		{
+			Name: "supersedeBuilds",
+			Prompt: &survey.Confirm{
+				Message: "Supersede builds",
+				Help:    "Cancel the pending and running builds for a branch or tag when a newer build for it starts.",
+				Default: p.SupersedeBuilds,
+			},
+		},
+		{
+			Name: "defaultPriority",
+			Prompt: &survey.Input{
+				Message: "Default build priority",
+				Help:    "Name of the Kubernetes PriorityClass of workers whose build does not set a priority. Leave empty for the cluster default.",
+				Default: p.DefaultPriority,
+			},
+		},
+		{
+			Name: "allowedPriorities",
+			Prompt: &survey.Input{
+				Message: "Allowed build priorities",
+				Help:    "Comma-separated names of the Kubernetes PriorityClasses that builds may set, such as through the priority of a webhook. Builds that set any other priority get the default priority.",
+				Default: p.AllowedPriorities,
+			},
+		},
 		{
 			Name: "allowHostMounts",
 			Prompt: &survey.Confirm{
@@ -525,3 +558,12 @@ func genericGatewaySecretValidator(val interface{}) error {
 	}
 	return fmt.Errorf("Generic Gateway secret should only contain alphanumeric characters")
 }
+
+// validateDuration validates the build timeout provided by user, which can be
+// either "" or a duration such as "1h30m"
+func validateDuration(val interface{}) error {
+	if err := brigade.ValidateBuildTimeout(val.(string)); err != nil {
+		return fmt.Errorf("Build timeout should be a duration such as 1h30m")
+	}
+	return nil
+}
@@ -162,6 +162,14 @@ func projectCreatePromptsVCS(p *brigade.Project, store storage.Store) error {
 					Default: p.Github.UploadURL,
 				},
 			},
+			{
+				Name: "buildUntrusted",
+				Prompt: &survey.Confirm{
+					Message: "Build pull requests from forks and comments of untrusted authors",
+					Help:    "Builds of authors who are not owners, members or collaborators of the repository, or of GitLab and Bitbucket forks outside its namespace, run with the secrets of the project",
+					Default: p.Github.BuildUntrusted,
+				},
+			},
 		}, &p.Github); err != nil {
 			return fmt.Errorf(abort, err)
 		}
@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+const projectScheduleUsage = `Manage the schedules of a project
+
+The brigade-cron-gateway creates a build of a project each time one of its
+schedules is due, like a Kubernetes CronJob. Builds of schedules have the
+provider "cron", and the name of their schedule as their short title.
+`
+
+const projectScheduleAddUsage = `Add a schedule to a project, or replace the schedule of the same name.
+
+The schedule is given as a standard cron expression, in UTC:
+
+	$ brig project schedule add my/project nightly --cron "0 3 * * *" --event nightly
+
+A schedule that is due while the previous build it created is still running
+starts another build, unless its concurrency policy is Forbid, which skips the
+new build, or Replace, which cancels the running one.
+
+If the gateway misses times that the schedule is due, it creates a single
+build for the latest of them when it catches up, unless that is later than the
+starting deadline of the schedule.
+`
+
+const projectScheduleListUsage = `List the schedules of a project.
+`
+
+const projectScheduleRemoveUsage = `Remove a schedule from a project.
+
+Builds that the schedule already created are not affected.
+`
+
+var (
+	scheduleCron              string
+	scheduleEvent             string
+	schedulePayloadFile       string
+	scheduleInlinePayload     string
+	scheduleRef               string
+	scheduleConcurrencyPolicy string
+	scheduleStartingDeadline  string
+)
+
+func init() {
+	project.AddCommand(projectSchedule)
+	projectSchedule.AddCommand(projectScheduleAdd, projectScheduleList, projectScheduleRemove)
+
+	flags := projectScheduleAdd.Flags()
+	flags.StringVar(&scheduleCron, "cron", "", "The cron expression of the times the schedule is due, such as \"0 3 * * *\" or @hourly")
+	flags.StringVarP(&scheduleEvent, "event", "e", "", "The name of the event of the builds, if not given the event is \"cron\"")
+	flags.StringVarP(&schedulePayloadFile, "payload", "p", "", "The path to a payload file")
+	flags.StringVarP(&scheduleInlinePayload, "inline-payload", "i", "", "The payload specified inline")
+	flags.StringVarP(&scheduleRef, "ref", "r", "", "A VCS (git) version, tag, or branch, if not given master is used")
+	flags.StringVar(&scheduleConcurrencyPolicy, "concurrency-policy", string(brigade.ConcurrencyAllow), "What to do if the previous build of the schedule is still running: Allow, Forbid or Replace")
+	flags.StringVar(&scheduleStartingDeadline, "starting-deadline", "", "How late a missed build may start, such as 10m, if not given missed builds always start")
+}
+
+var projectSchedule = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage the schedules of a project",
+	Long:  projectScheduleUsage,
+}
+
+var projectScheduleAdd = &cobra.Command{
+	Use:   "add PROJECT NAME",
+	Short: "add a schedule to a project",
+	Long:  projectScheduleAddUsage,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("project and schedule name are required arguments")
+		}
+		if schedulePayloadFile != "" && scheduleInlinePayload != "" {
+			return errors.New("only one of --payload and --inline-payload can be given")
+		}
+		payload := scheduleInlinePayload
+		if schedulePayloadFile != "" {
+			data, err := ioutil.ReadFile(schedulePayloadFile)
+			if err != nil {
+				return err
+			}
+			payload = string(data)
+		}
+		c, err := kubeClient()
+		if err != nil {
+			return err
+		}
+		return addSchedule(cmd.OutOrStdout(), c, args[0], brigade.Schedule{
+			Name:              args[1],
+			Cron:              scheduleCron,
+			EventType:         scheduleEvent,
+			Payload:           payload,
+			Ref:               scheduleRef,
+			ConcurrencyPolicy: brigade.ConcurrencyPolicy(scheduleConcurrencyPolicy),
+			StartingDeadline:  scheduleStartingDeadline,
+		})
+	},
+}
+
+var projectScheduleList = &cobra.Command{
+	Use:   "list PROJECT",
+	Short: "list the schedules of a project",
+	Long:  projectScheduleListUsage,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("project name is a required argument")
+		}
+		c, err := kubeClient()
+		if err != nil {
+			return err
+		}
+		return listSchedules(cmd.OutOrStdout(), c, args[0])
+	},
+}
+
+var projectScheduleRemove = &cobra.Command{
+	Use:   "remove PROJECT NAME",
+	Short: "remove a schedule from a project",
+	Long:  projectScheduleRemoveUsage,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("project and schedule name are required arguments")
+		}
+		c, err := kubeClient()
+		if err != nil {
+			return err
+		}
+		return removeSchedule(cmd.OutOrStdout(), c, args[0], args[1])
+	},
+}
+
+func addSchedule(out io.Writer, c kubernetes.Interface, pid string, sched brigade.Schedule) error {
+	if err := sched.Validate(); err != nil {
+		return err
+	}
+	store := kube.New(c, globalNamespace)
+	proj, err := store.GetProject(pid)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range proj.Schedules {
+		if proj.Schedules[i].Name == sched.Name {
+			proj.Schedules[i] = sched
+			replaced = true
+		}
+	}
+	if !replaced {
+		proj.Schedules = append(proj.Schedules, sched)
+	}
+	if err := store.ReplaceProject(proj); err != nil {
+		return err
+	}
+
+	if replaced {
+		fmt.Fprintf(out, "Replaced schedule %s of project %s\n", sched.Name, proj.Name)
+	} else {
+		fmt.Fprintf(out, "Added schedule %s to project %s\n", sched.Name, proj.Name)
+	}
+	return nil
+}
+
+func listSchedules(out io.Writer, c kubernetes.Interface, pid string) error {
+	proj, err := kube.New(c, globalNamespace).GetProject(pid)
+	if err != nil {
+		return err
+	}
+
+	table := uitable.New()
+	table.AddRow("NAME", "CRON", "EVENT", "REF", "CONCURRENCY", "DEADLINE")
+	for _, s := range proj.Schedules {
+		table.AddRow(s.Name, s.Cron, s.EventType, s.Ref, s.ConcurrencyPolicy, s.StartingDeadline)
+	}
+	fmt.Fprintln(out, table)
+	return nil
+}
+
+func removeSchedule(out io.Writer, c kubernetes.Interface, pid, name string) error {
+	store := kube.New(c, globalNamespace)
+	proj, err := store.GetProject(pid)
+	if err != nil {
+		return err
+	}
+
+	schedules := []brigade.Schedule{}
+	for _, s := range proj.Schedules {
+		if s.Name != name {
+			schedules = append(schedules, s)
+		}
+	}
+	if len(schedules) == len(proj.Schedules) {
+		return fmt.Errorf("project %s has no schedule %s", proj.Name, name)
+	}
+	proj.Schedules = schedules
+	if err := store.ReplaceProject(proj); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Removed schedule %s from project %s\n", name, proj.Name)
+	return nil
+}
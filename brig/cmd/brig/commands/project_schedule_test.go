@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+const scheduleProject = "deis/empty-testbed"
+
+func newScheduleClient(t *testing.T, schedules ...brigade.Schedule) kubernetes.Interface {
+	t.Helper()
+	secret, err := kube.SecretFromProject(&brigade.Project{Name: scheduleProject, Schedules: schedules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Namespace = globalNamespace
+	return fake.NewSimpleClientset(&secret)
+}
+
+// getScheduleProject returns the project in c. The API server moves the
+// StringData of secrets to their Data, which the fake clientset does not.
+func getScheduleProject(t *testing.T, c kubernetes.Interface) *brigade.Project {
+	t.Helper()
+	secrets := c.CoreV1().Secrets(globalNamespace)
+	secret, err := secrets.Get(context.TODO(), brigade.ProjectID(scheduleProject), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+	secret.StringData = nil
+	if secret, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	proj, err := kube.NewProjectFromSecret(secret, globalNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proj
+}
+
+func TestAddSchedule(t *testing.T) {
+	c := newScheduleClient(t)
+	getScheduleProject(t, c)
+
+	nightly := brigade.Schedule{Name: "nightly", Cron: "0 3 * * *", EventType: "nightly"}
+	out := &bytes.Buffer{}
+	if err := addSchedule(out, c, scheduleProject, nightly); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Added schedule nightly to project deis/empty-testbed\n"; out.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, out.String())
+	}
+	proj := getScheduleProject(t, c)
+	if len(proj.Schedules) != 1 || proj.Schedules[0] != nightly {
+		t.Fatalf("expected schedule %+v, got %+v", nightly, proj.Schedules)
+	}
+
+	nightly.Cron = "0 4 * * *"
+	out.Reset()
+	if err := addSchedule(out, c, scheduleProject, nightly); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Replaced schedule nightly of project deis/empty-testbed\n"; out.String() != expected {
+		t.Errorf("expected output %q, got %q", expected, out.String())
+	}
+	proj = getScheduleProject(t, c)
+	if len(proj.Schedules) != 1 || proj.Schedules[0].Cron != "0 4 * * *" {
+		t.Errorf("expected schedule to be replaced, got %+v", proj.Schedules)
+	}
+}
+
+func TestAddSchedule_Invalid(t *testing.T) {
+	c := newScheduleClient(t)
+	getScheduleProject(t, c)
+	err := addSchedule(&bytes.Buffer{}, c, scheduleProject, brigade.Schedule{Name: "nightly", Cron: "every night"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid cron expression")
+	}
+}
+
+func TestListSchedules(t *testing.T) {
+	c := newScheduleClient(t, brigade.Schedule{Name: "nightly", Cron: "0 3 * * *", ConcurrencyPolicy: brigade.ConcurrencyForbid})
+	getScheduleProject(t, c)
+	out := &bytes.Buffer{}
+	if err := listSchedules(out, c, scheduleProject); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("nightly")) || !bytes.Contains(out.Bytes(), []byte("Forbid")) {
+		t.Errorf("expected schedule nightly to be listed, got %q", out.String())
+	}
+}
+
+func TestRemoveSchedule(t *testing.T) {
+	c := newScheduleClient(t,
+		brigade.Schedule{Name: "nightly", Cron: "0 3 * * *"},
+		brigade.Schedule{Name: "hourly", Cron: "@hourly"},
+	)
+	getScheduleProject(t, c)
+	if err := removeSchedule(&bytes.Buffer{}, c, scheduleProject, "nightly"); err != nil {
+		t.Fatal(err)
+	}
+	proj := getScheduleProject(t, c)
+	if len(proj.Schedules) != 1 || proj.Schedules[0].Name != "hourly" {
+		t.Errorf("expected only schedule hourly, got %+v", proj.Schedules)
+	}
+
+	if err := removeSchedule(&bytes.Buffer{}, c, scheduleProject, "nightly"); err == nil {
+		t.Error("expected an error for a schedule that does not exist")
+	}
+}
@@ -148,9 +148,13 @@ func getUpdatedBuild(r *script.Runner, bid string) (*brigade.Build, error) {
 	}
 
 	// Override a few things
+	build.ParentBuildID = build.ID
+	build.RerunReason = brigade.RerunManual
 	build.ID = ""
 	build.LogLevel = rerunLogLevel
 	build.Worker = nil
+	build.Attempt = 0
+	build.NotBefore = nil
 
 	return build, nil
 }
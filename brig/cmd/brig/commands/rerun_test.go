@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/decolorizer"
 
 	"k8s.io/client-go/kubernetes/fake"
@@ -133,6 +134,14 @@ func TestRerun_getUpdatedBuild(t *testing.T) {
 				t.Errorf("expected build.Worker to be %v, was: %v", nil, build.Worker)
 			}
 
+			if build.ParentBuildID != stubBuild1ID {
+				t.Errorf("expected build.ParentBuildID to be %s, was: %s", stubBuild1ID, build.ParentBuildID)
+			}
+
+			if build.RerunReason != brigade.RerunManual {
+				t.Errorf("expected build.RerunReason to be %s, was: %s", brigade.RerunManual, build.RerunReason)
+			}
+
 			if build.LogLevel != tc.LogLevel {
 				t.Errorf("expected build.LogLevel to be %s, was: %s", tc.LogLevel, build.LogLevel)
 			}
@@ -21,6 +21,7 @@ var (
 	runCommitish     string
 	runRef           string
 	runLogLevel      string
+	runPriority      string
 	runNoProgress    bool
 	runNoColor       bool
 	runBackground    bool
@@ -74,6 +75,7 @@ func init() {
 	run.Flags().BoolVar(&runNoColor, "no-color", false, "Remove color codes from log output")
 	run.Flags().BoolVarP(&runBackground, "background", "b", false, "Trigger the event and exit. Let the job run in the background.")
 	run.Flags().StringVarP(&runLogLevel, "level", "l", "log", "Specified log level: log, info, warn, error")
+	run.Flags().StringVar(&runPriority, "priority", "", "The name of the Kubernetes PriorityClass of the worker, which must be one of the project's allowed priorities, if not given the project's default priority is used")
 	Root.AddCommand(run)
 }
 
@@ -132,6 +134,7 @@ var run = &cobra.Command{
 		runner.NoProgress = runNoProgress
 		runner.Background = runBackground
 		runner.Verbose = globalVerbose
+		runner.Priority = runPriority
 
 		err = runner.SendScript(proj, scr, config, runEvent, runCommitish, runRef, payload, runLogLevel)
 		if err == nil {
@@ -3,11 +3,19 @@ package main
 import (
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	restful "github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/brigadecore/brigade/pkg/tracing"
 )
 
 var (
@@ -63,6 +71,69 @@ func NCSACommonLogFormatLogger() restful.FilterFunction {
 	}
 }
 
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "api",
+		Name:      "requests_total",
+		Help:      "Number of requests, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "brigade",
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Time it takes to serve a request, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration)
+}
+
+// MetricsFilter Create a filter that counts requests and measures their
+// latency. Requests are labelled with the path of their route rather than
+// their URL, so that the IDs of builds, jobs and projects do not make a new
+// series each.
+func MetricsFilter() restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		start := time.Now()
+		chain.ProcessFilter(req, resp)
+		route := req.SelectedRoutePath()
+		if route == "" {
+			route = "unmatched"
+		}
+		requestsTotal.WithLabelValues(req.Request.Method, route, strconv.Itoa(resp.StatusCode())).Inc()
+		requestDuration.WithLabelValues(req.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// TracingFilter Create a filter that starts a span for every request, which
+// continues the trace of the traceparent header of the request if it has one.
+// The span is passed on in the context of the request.
+func TracingFilter() restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		name := req.SelectedRoutePath()
+		if name == "" {
+			name = "unmatched"
+		}
+		ctx, span := tracing.Tracer().Start(tracing.FromRequest(req.Request), req.Request.Method+" "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				label.String("http.method", req.Request.Method),
+				label.String("http.route", name),
+			),
+		)
+		defer span.End()
+		req.Request = req.Request.WithContext(ctx)
+		chain.ProcessFilter(req, resp)
+		span.SetAttributes(label.Int("http.status_code", resp.StatusCode()))
+		if resp.StatusCode() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode()))
+		}
+	}
+}
+
 func colorForMethod(method string) string {
 	switch method {
 	case "GET":
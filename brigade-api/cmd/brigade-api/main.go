@@ -6,32 +6,65 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/brigadecore/brigade/pkg/api"
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/logarchive"
+	"github.com/brigadecore/brigade/pkg/metrics"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/history"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
 
 	restful "github.com/emicklei/go-restful"
 	restfulspec "github.com/emicklei/go-restful-openapi"
 	"github.com/go-openapi/spec"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
-	apiPort    string
-	kubeconfig string
-	master     string
-	namespace  string
-	verbose    bool
+	apiPort         string
+	kubeconfig      string
+	master          string
+	namespace       string
+	verbose         bool
+	authMode        string
+	tokenFile       string
+	authzPolicyFile string
+	wsOrigins       string
+	logArchive      logarchive.SinkConfig
+	historyDriver   string
+	historyDSN      string
+	nsConfig        namespaces.Config
+	traceConfig     tracing.Config
+)
+
+const (
+	authModeNone        = "none"
+	authModeTokenFile   = "token-file"
+	authModeTokenReview = "token-review"
 )
 
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	flag.StringVar(&master, "master", "", "master url")
 	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
 	flag.StringVar(&apiPort, "api-port", defaultAPIPort(), "TCP port to use for brigade-api")
 	flag.BoolVar(&verbose, "verbose", false, "enables detailed logging of http request matching and filter invocation")
+	flag.StringVar(&authMode, "auth", defaultAuthMode(), "how bearer tokens are authenticated: none, token-file or token-review")
+	flag.StringVar(&tokenFile, "token-file", os.Getenv("BRIGADE_API_TOKEN_FILE"), "path to a static token file, used with --auth=token-file")
+	flag.StringVar(&wsOrigins, "websocket-allowed-origins", os.Getenv("BRIGADE_API_WEBSOCKET_ALLOWED_ORIGINS"), "comma separated origins of other sites, such as https://dashboard.example.com, whose pages may open log WebSockets, or * for any site")
+	logArchive.AddFlags(flag.CommandLine)
+	traceConfig.AddFlags(flag.CommandLine)
+	flag.StringVar(&historyDriver, "history-db-driver", defaultHistoryDBDriver(), "database/sql driver of the build history database: postgres or sqlite")
+	flag.StringVar(&historyDSN, "history-db-dsn", os.Getenv("BRIGADE_HISTORY_DB_DSN"), "data source name of the build history database, if not given builds are only read from kubernetes")
+	flag.StringVar(&authzPolicyFile, "authz-policy-file", os.Getenv("BRIGADE_API_AUTHZ_POLICY_FILE"), "path to a policy file granting users and groups access to projects, if not given every authenticated user can access every project. Requires --auth other than none")
 }
 
 type jobService struct {
@@ -40,10 +73,12 @@ type jobService struct {
 
 type buildService struct {
 	server api.API
+	events api.Events
 }
 
 type projectService struct {
 	server api.API
+	events api.Events
 }
 
 type healthService struct {
@@ -68,12 +103,21 @@ func (js jobService) WebService() *restful.WebService {
 		Returns(200, "OK", brigade.Job{}).
 		Returns(404, "Not Found", nil))
 
-	ws.Route(ws.GET("/{id}/logs").To(j.Logs).
+	ws.Route(api.AddLogParams(ws, ws.GET("/{id}/logs").To(j.Logs).
 		Doc("get job logs").
-		Param(ws.PathParameter("id", "identifier of the job").DataType("string")).
+		Param(ws.PathParameter("id", "identifier of the job").DataType("string"))).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes([]byte{}). // on the response
 		Returns(200, "OK", []byte{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(api.AddLogParams(ws, ws.GET("/{id}/logs/ws").To(j.LogsWebSocket).
+		Doc("follow job logs over a WebSocket, one message per line").
+		Param(ws.PathParameter("id", "identifier of the job").DataType("string"))).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(101, "Switching Protocols", nil).
+		Returns(400, "Bad Request", nil).
 		Returns(404, "Not Found", nil))
 
 	return ws
@@ -106,14 +150,70 @@ func (bs buildService) WebService() *restful.WebService {
 		Returns(200, "OK", []brigade.Job{}).
 		Returns(404, "Not Found", nil))
 
-	ws.Route(ws.GET("/{id}/logs").To(b.Logs).
+	ws.Route(ws.GET("/{id}/lineage").To(b.Lineage).
+		Doc("get the rerun chain of a build, oldest build first").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]brigade.Build{}).
+		Returns(200, "OK", []brigade.Build{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(api.AddLogParams(ws, ws.GET("/{id}/logs").To(b.Logs).
 		Doc("get logs of a build").
+		Param(ws.PathParameter("id", "id of the build").DataType("string"))).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]byte{}).
+		Returns(200, "OK", []byte{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{id}/logs/all").To(b.MergedLogs).
+		Doc("get the init container, worker and job logs of a build, interleaved in the order they were written").
 		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.QueryParameter("timestamps", "prefix every log line with an RFC 3339 timestamp").DataType("boolean")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Produces("text/plain").
 		Writes([]byte{}).
 		Returns(200, "OK", []byte{}).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(api.AddLogParams(ws, ws.GET("/{id}/logs/ws").To(b.LogsWebSocket).
+		Doc("follow logs of a build over a WebSocket, one message per line").
+		Param(ws.PathParameter("id", "id of the build").DataType("string"))).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(101, "Switching Protocols", nil).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.GET("/{id}/events").To(bs.events.Build).
+		Doc("stream the worker and job state changes of a build as server-sent events").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.HeaderParameter("Last-Event-ID", "id of the last event received, to resume a stream").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Produces("text/event-stream").
+		Writes(brigade.BuildEvent{}).
+		Returns(200, "OK", brigade.BuildEvent{}).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/{id}/cancel").To(b.Cancel).
+		Doc("cancel a build, terminating its worker and jobs").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(202, "Accepted", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Conflict", nil).
+		Returns(500, "Internal Server Error", nil))
+
+	ws.Route(ws.DELETE("/{id}").To(b.Delete).
+		Doc("delete a build and its jobs").
+		Param(ws.PathParameter("id", "id of the build").DataType("string")).
+		Param(ws.QueryParameter("force", "also delete the build if it is still running").DataType("boolean").DefaultValue("false")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(204, "No Content", nil).
+		Returns(404, "Not Found", nil).
+		Returns(409, "Conflict", nil).
+		Returns(500, "Internal Server Error", nil))
+
 	return ws
 }
 
@@ -143,14 +243,81 @@ func (ps projectService) WebService() *restful.WebService {
 		Returns(200, "OK", brigade.Project{}).
 		Returns(404, "Not Found", nil))
 
-	ws.Route(ws.GET("/project/{id}/builds").To(p.Builds).
-		Doc("get list of builds for a project").
+	ws.Route(ws.POST("/project/{id}").To(p.Create).
+		Param(ws.PathParameter("id", "name or id of the project").DataType("string")).
+		Doc("create a project").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(brigade.Project{}).
+		Writes(brigade.Project{}).
+		Returns(201, "Created", brigade.Project{}).
+		Returns(400, "Bad Request", nil).
+		Returns(409, "Conflict", nil).
+		Returns(500, "Internal Server Error", nil))
+
+	ws.Route(ws.PUT("/project/{id}").To(p.Replace).
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Doc("replace a project").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(brigade.Project{}).
+		Writes(brigade.Project{}).
+		Returns(200, "OK", brigade.Project{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil).
+		Returns(500, "Internal Server Error", nil))
+
+	ws.Route(ws.DELETE("/project/{id}").To(p.Delete).
 		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Doc("delete a project").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(204, "No Content", nil).
+		Returns(404, "Not Found", nil).
+		Returns(500, "Internal Server Error", nil))
+
+	ws.Route(api.AddBuildListParams(ws, ws.GET("/project/{id}/builds").To(p.Builds).
+		Doc("get list of builds for a project, newest first").
+		Param(ws.PathParameter("id", "id of the project").DataType("string"))).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes([]brigade.Build{}).
 		Returns(200, "OK", []brigade.Build{}).
+		Returns(400, "Bad Request", nil).
 		Returns(404, "Not Found", nil))
 
+	ws.Route(api.AddBuildListParams(ws, ws.GET("/builds").To(ps.server.Build().List).
+		Doc("get list of builds of all projects, newest first")).
+		Metadata(restfulspec.KeyOpenAPITags, []string{"build"}).
+		Writes([]brigade.Build{}).
+		Returns(200, "OK", []brigade.Build{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil))
+
+	ws.Route(ws.POST("/project/{id}/builds").To(p.CreateBuild).
+		Doc("create a build for a project").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(brigade.Build{}).
+		Writes(brigade.Build{}).
+		Returns(201, "Created", brigade.Build{}).
+		Returns(400, "Bad Request", nil).
+		Returns(404, "Not Found", nil).
+		Returns(500, "Internal Server Error", nil))
+
+	ws.Route(ws.GET("/project/{id}/events").To(ps.events.Project).
+		Doc("stream the build, worker and job state changes of a project as server-sent events").
+		Param(ws.PathParameter("id", "id of the project").DataType("string")).
+		Param(ws.HeaderParameter("Last-Event-ID", "id of the last event received, to resume a stream").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Produces("text/event-stream").
+		Writes(brigade.BuildEvent{}).
+		Returns(200, "OK", brigade.BuildEvent{}))
+
+	ws.Route(ws.GET("/events").To(ps.events.Stream).
+		Doc("stream the build, worker and job state changes of all projects as server-sent events").
+		Param(ws.HeaderParameter("Last-Event-ID", "id of the last event received, to resume a stream").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, []string{"events"}).
+		Produces("text/event-stream").
+		Writes(brigade.BuildEvent{}).
+		Returns(200, "OK", brigade.BuildEvent{}))
+
 	ws.Route(ws.GET("/projects-build").To(p.ListWithLatestBuild).
 		Doc("lists the projects with the latest builds attached.").
 		Metadata(restfulspec.KeyOpenAPITags, tags).
@@ -186,25 +353,78 @@ func main() {
 
 	restful.EnableTracing(verbose)
 
+	stopTracing, err := tracing.Start("brigade-api", traceConfig)
+	if err != nil {
+		log.Fatalf("error configuring tracing (%s)", err)
+	}
+	defer stopTracing()
+
 	clientset, err := kube.GetClient(master, kubeconfig)
 	if err != nil {
 		log.Fatalf("error creating kubernetes client (%s)", err)
 		return
 	}
 
-	storage := kube.New(clientset, namespace)
-	storageServer := api.New(storage)
+	authn, err := newAuthenticator(clientset)
+	if err != nil {
+		log.Fatalf("error configuring authentication (%s)", err)
+	}
+
+	authz := api.AllowAll
+	if authzPolicyFile != "" {
+		// Policies grant access to users, so without authentication they
+		// would forbid every request.
+		if authn == nil {
+			log.Fatalf("--authz-policy-file requires authentication, set --auth to %s or %s", authModeTokenFile, authModeTokenReview)
+		}
+		if authz, err = api.NewPolicyAuthorizer(authzPolicyFile); err != nil {
+			log.Fatalf("error loading authorization policy (%s)", err)
+		}
+	}
+
+	nsSet, err := namespaces.NewSet(clientset, namespace, nsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiCache := apicache.NewForNamespaces(clientset, nsSet, kube.DefaultCacheResyncPeriod)
+	var store storage.Store = kube.NewForNamespaces(clientset, nsSet, apiCache)
+	if historyDSN != "" {
+		db, err := history.Open(historyDriver, historyDSN)
+		if err != nil {
+			log.Fatalf("error opening build history database (%s)", err)
+		}
+		defer db.Close()
+		store = history.NewStore(store, db)
+	}
+	sink, err := logarchive.NewSink(logArchive)
+	if err != nil {
+		log.Fatalf("error configuring log archive (%s)", err)
+	}
+	if sink != nil {
+		store = logarchive.NewStore(store, sink)
+	}
+	storageServer := api.NewWithAuthorizer(store, authz).WithWebSocketOrigins(splitOrigins(wsOrigins))
+
+	broker := api.NewEventBroker(api.DefaultEventHistorySize)
+	apiCache.AddEventHandler(kube.NewBuildEventHandler(broker.Publish))
+	events := storageServer.Events(broker)
 
 	j := jobService{server: storageServer}
-	b := buildService{server: storageServer}
-	p := projectService{server: storageServer}
+	b := buildService{server: storageServer, events: events}
+	p := projectService{server: storageServer, events: events}
 	h := healthService{}
 
-	restful.DefaultContainer.Add(j.WebService())
-	restful.DefaultContainer.Add(b.WebService())
-	restful.DefaultContainer.Add(p.WebService())
+	for _, ws := range []*restful.WebService{j.WebService(), b.WebService(), p.WebService()} {
+		if authn != nil {
+			ws.Filter(api.AuthFilter(authn))
+		}
+		restful.DefaultContainer.Add(ws)
+	}
 	restful.DefaultContainer.Add(h.WebService())
 	restful.DefaultContainer.Filter(NCSACommonLogFormatLogger())
+	restful.DefaultContainer.Filter(MetricsFilter())
+	restful.DefaultContainer.Filter(TracingFilter())
+	restful.DefaultContainer.Handle(metrics.Path, metrics.Handler())
 
 	config := restfulspec.Config{
 		WebServices:                   restful.RegisteredWebServices(),
@@ -213,8 +433,9 @@ func main() {
 	restful.DefaultContainer.Add(restfulspec.NewOpenAPIService(config))
 
 	cors := restful.CrossOriginResourceSharing{
-		AllowedHeaders: []string{"Content-Type", "Accept"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+		ExposeHeaders:  []string{api.ContinueHeader},
 		CookiesAllowed: false,
 		Container:      restful.DefaultContainer}
 	restful.DefaultContainer.Filter(cors.Filter)
@@ -226,6 +447,42 @@ func main() {
 	log.Fatal(hserver.ListenAndServe())
 }
 
+// newAuthenticator returns the Authenticator for the configured auth mode, or
+// nil if requests are not authenticated.
+func newAuthenticator(clientset kubernetes.Interface) (api.Authenticator, error) {
+	switch authMode {
+	case authModeNone, "":
+		return nil, nil
+	case authModeTokenFile:
+		if tokenFile == "" {
+			return nil, fmt.Errorf("--token-file is required with --auth=%s", authModeTokenFile)
+		}
+		return api.NewTokenFileAuthenticator(tokenFile)
+	case authModeTokenReview:
+		return api.NewTokenReviewAuthenticator(clientset), nil
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", authMode)
+	}
+}
+
+// splitOrigins splits a comma separated list of origins.
+func splitOrigins(origins string) []string {
+	res := []string{}
+	for _, o := range strings.Split(origins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+func defaultAuthMode() string {
+	if mode, ok := os.LookupEnv("BRIGADE_API_AUTH"); ok {
+		return mode
+	}
+	return authModeNone
+}
+
 func defaultNamespace() string {
 	if ns, ok := os.LookupEnv("BRIGADE_NAMESPACE"); ok {
 		return ns
@@ -233,6 +490,13 @@ func defaultNamespace() string {
 	return v1.NamespaceDefault
 }
 
+func defaultHistoryDBDriver() string {
+	if driver, ok := os.LookupEnv("BRIGADE_HISTORY_DB_DRIVER"); ok {
+		return driver
+	}
+	return history.DefaultDriver
+}
+
 func defaultAPIPort() string {
 	if port, ok := os.LookupEnv("BRIGADE_API_PORT"); ok {
 		return port
@@ -244,7 +508,7 @@ func enrichSwaggerObject(swo *spec.Swagger) {
 	swo.Info = &spec.Info{
 		InfoProps: spec.InfoProps{
 			Title:       "Brigade API",
-			Description: "Resources for Jobs, Projects, Builds",
+			Description: "Resources for Jobs, Projects, Builds, Events",
 			License: &spec.License{
 				LicenseProps: spec.LicenseProps{
 					Name: "Apache-2.0",
@@ -3,14 +3,21 @@ package controller
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 const (
@@ -35,6 +42,24 @@ type Config struct {
 	WorkerLimitsMemory         string
 	DefaultBuildStorageClass   string
 	DefaultCacheStorageClass   string
+	// BuildTimeout is the maximum duration of builds of projects that do not
+	// set their own. 0 means builds do not time out.
+	BuildTimeout time.Duration
+	// MaxConcurrentBuilds is the maximum number of builds of all projects that
+	// run at the same time. 0 means no limit.
+	MaxConcurrentBuilds int
+	// Namespaces are the namespaces whose builds the controller starts
+	// workers for. If nil, only the builds of Namespace are.
+	Namespaces namespaces.Set
+}
+
+// namespaceSet returns the namespaces whose builds the controller starts
+// workers for.
+func (c *Config) namespaceSet() namespaces.Set {
+	if c.Namespaces == nil {
+		return namespaces.Single(c.Namespace)
+	}
+	return c.Namespaces
 }
 
 // Controller listens for new brigade builds and starts the worker pods.
@@ -44,7 +69,24 @@ type Controller struct {
 	queue    workqueue.RateLimitingInterface
 	informer cache.Controller
 
+	// workerInformer watches worker pods, to start queued builds when running
+	// builds finish.
+	workerStore    cache.Store
+	workerInformer cache.Controller
+	admitLock      sync.Mutex
+	// retryLock keeps a failed build from being retried by both the worker
+	// informer and a sync at once.
+	retryLock sync.Mutex
+
 	clientset kubernetes.Interface
+	// priorityClasses orders the queue, and fails builds whose PriorityClass
+	// does not exist.
+	priorityClasses *kube.PriorityClassCache
+	// recorder records events about builds against their build secrets.
+	recorder record.EventRecorder
+	// broadcaster sends the events of recorder to the API server until the
+	// controller stops.
+	broadcaster record.EventBroadcaster
 }
 
 // NewController creates a new Controller.
@@ -53,8 +95,14 @@ func NewController(clientset kubernetes.Interface, config *Config) *Controller {
 		clientset: clientset,
 		Config:    config,
 		queue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+
+		priorityClasses: kube.NewPriorityClassCache(clientset, 0),
 	}
+	c.broadcaster = record.NewBroadcaster()
+	c.broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	c.recorder = c.broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "brigade-controller"})
 	c.createIndexerInformer()
+	c.createWorkerInformer()
 	return c
 }
 
@@ -78,9 +126,16 @@ func (c *Controller) processNextItem() bool {
 	// This allows safe parallel processing because two secrets with the same key are never processed in
 	// parallel.
 	defer c.queue.Done(key)
+	queueDepth.Set(float64(c.queue.Len()))
 
 	// Invoke the method containing the business logic
+	start := time.Now()
 	err := c.sync(key.(string))
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	syncDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
 	// Handle the error if something went wrong during the execution of the business logic
 	c.handleErr(err, key)
 	return true
@@ -88,7 +143,7 @@ func (c *Controller) processNextItem() bool {
 
 // HasSynced returns true if the controller has synced.
 func (c *Controller) HasSynced() bool {
-	return c.informer.HasSynced()
+	return c.informer.HasSynced() && c.workerInformer.HasSynced()
 }
 
 // sync is the business logic of the controller.
@@ -128,6 +183,7 @@ func (c *Controller) handleErr(err error, key interface{}) {
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
 		c.queue.AddRateLimited(key)
+		requeues.Inc()
 		return
 	}
 
@@ -137,15 +193,19 @@ func (c *Controller) handleErr(err error, key interface{}) {
 	log.Printf("Dropping secret %q out of the queue: %v", key, err)
 }
 
-// Run executes the controller.
-func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
+// Run executes the controller, syncing up to threadiness builds at the same
+// time, until stopCh is closed.
+func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 
 	// Let the workers stop when we are done
 	defer c.queue.ShutDown()
+	defer c.broadcaster.Shutdown()
 	log.Print("Starting Secret controller")
 
 	go c.informer.Run(stopCh)
+	go c.workerInformer.Run(stopCh)
+	go c.priorityClasses.Run(stopCh)
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
 	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
@@ -156,6 +216,8 @@ func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
 	for i := 0; i < threadiness; i++ {
 		go wait.Until(c.runWorker, time.Second, stopCh)
 	}
+	go wait.Until(c.enforceTimeouts, timeoutCheckInterval, stopCh)
+	go wait.Until(c.requeueQueued, queueResyncInterval, stopCh)
 
 	<-stopCh
 	log.Print("Stopping Secret controller")
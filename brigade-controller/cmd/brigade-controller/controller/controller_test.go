@@ -12,9 +12,10 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 )
 
-const expectedEnvironmentLength = 20
+const expectedEnvironmentLength = 21
 
 func TestController(t *testing.T) {
 	createdPod := false
@@ -1033,3 +1034,36 @@ func TestController_WithOverrideServiceAccount(t *testing.T) {
 		t.Error("Pod is missing environment variable BRIGADE_SERVICE_ACCOUNT")
 	}
 }
+
+// stoppedBroadcaster records whether an event broadcaster was shut down.
+type stoppedBroadcaster struct {
+	record.EventBroadcaster
+	stopped bool
+}
+
+func (b *stoppedBroadcaster) Shutdown() {
+	b.stopped = true
+	b.EventBroadcaster.Shutdown()
+}
+
+func TestController_RunStopsBroadcaster(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), &Config{Namespace: v1.NamespaceDefault})
+	broadcaster := &stoppedBroadcaster{EventBroadcaster: controller.broadcaster}
+	controller.broadcaster = broadcaster
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		controller.Run(1, stopCh)
+		close(done)
+	}()
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the controller to stop")
+	}
+	if !broadcaster.stopped {
+		t.Error("expected the event broadcaster to be shut down")
+	}
+}
@@ -9,13 +9,21 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apiresource "k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/tracing"
 )
 
 var (
@@ -25,58 +33,228 @@ var (
 	containerImageRegex = regexp.MustCompile("(.*):([^:]+)$")
 )
 
-func (c *Controller) syncSecret(build *v1.Secret) error {
-	// Ensure this secret has not yet been handled.
-	if build.Labels["status"] == "accepted" {
+// The reasons of the events and conditions that the controller records on
+// build secrets.
+const (
+	reasonQueued             = "Queued"
+	reasonWorkerCreated      = "WorkerCreated"
+	reasonNoBuildID          = "NoBuildID"
+	reasonProjectNotFound    = "ProjectNotFound"
+	reasonWorkerCreateFailed = "WorkerCreateFailed"
+	reasonPriorityNotFound   = "PriorityClassNotFound"
+	reasonSyncFailed         = kube.ReasonSyncFailed
+)
+
+// syncError is an error syncing a build, with the reason it is recorded with.
+type syncError struct {
+	reason string
+	err    error
+}
+
+func (e *syncError) Error() string { return e.err.Error() }
+func (e *syncError) Unwrap() error { return e.err }
+
+func (c *Controller) syncSecret(build *v1.Secret) (err error) {
+	// Ensure this secret has not yet been handled, and the build was not
+	// terminated.
+	switch build.Labels["status"] {
+	case "accepted":
+		// A worker that failed while the controller was down is retried once
+		// the controller syncs its build again.
+		c.retryFinished(build)
+		return nil
+	case kube.StatusCancelled, kube.StatusTimedOut, kube.StatusSuperseded, kube.StatusFailed:
 		return nil
 	}
+	queued := build.Labels["status"] == kube.StatusQueued
+
+	// Failures are recorded on the build secret, so that users can see why
+	// the build does not start.
+	defer func() {
+		if err != nil {
+			c.recordSyncFailure(build, err)
+		}
+	}()
 
 	// If a secret does not have a build ID then it cannot be tracked through
 	// the system. A build ID should be a ULID.
 	if bid, ok := build.Labels["build"]; !ok || len(bid) == 0 {
 		// Alternately, we could add a build ID and then re-save the secret.
 		log.Printf("syncSecret: secret %s/%s has no build ID. Discarding.", build.Namespace, build.Name)
-		return ErrNoBuildID
+		return &syncError{reasonNoBuildID, ErrNoBuildID}
+	}
+
+	// A build that retries a failed build waits for the backoff of the retry
+	// policy of its project.
+	if notBefore, ok := kube.BuildNotBefore(*build); ok {
+		if d := time.Until(notBefore); d > 0 {
+			if key, err := cache.MetaNamespaceKeyFunc(build); err == nil {
+				c.queue.AddAfter(key, d)
+			}
+			return nil
+		}
 	}
 	data := build.Data
 
+	// The sync continues the trace of the build, and the worker continues the
+	// trace of the sync.
+	ctx, span := tracing.Tracer().Start(tracing.WithTraceparent(context.Background(), string(data["traceparent"])), "sync build", trace.WithAttributes(
+		label.String("brigade.build", build.Labels["build"]),
+		label.String("brigade.project", build.Labels["project"]),
+		label.String("brigade.event_type", string(data["event_type"])),
+		label.String("brigade.provider", string(data["event_provider"])),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	log.Printf("EventHandler: type=%s provider=%s commit=%s", data["event_type"], data["event_provider"], data["commit_id"])
 
 	podClient := c.clientset.CoreV1().Pods(build.Namespace)
 
-	if _, err := podClient.Get(context.TODO(), build.Name, metav1.GetOptions{}); err != nil {
+	if _, err := podClient.Get(ctx, build.Name, metav1.GetOptions{}); err != nil {
 		if !apierrors.IsNotFound(err) {
 			return err
 		}
 
 		pid := build.Labels["project"]
 		if pid == "" {
-			return errors.New("project ID not found")
+			return &syncError{reasonProjectNotFound, errors.New("project ID not found")}
 		}
 
 		secretClient := c.clientset.CoreV1().Secrets(build.Namespace)
-		project, err := secretClient.Get(context.TODO(), pid, metav1.GetOptions{})
+		project, err := secretClient.Get(ctx, pid, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return &syncError{reasonProjectNotFound, fmt.Errorf("project %s not found", pid)}
+		}
 		if err != nil {
 			return err
 		}
 
-		pod := NewWorkerPod(build, project, c.Config)
-		if _, err := podClient.Create(context.TODO(), &pod, metav1.CreateOptions{}); err != nil {
+		if !queued {
+			if build, err = c.supersede(build, project); err != nil {
+				return err
+			}
+		}
+
+		// A worker whose PriorityClass does not exist is never created, so the
+		// build fails instead of being retried.
+		if name := kube.BuildPriority(build, project); name != "" {
+			_, err := c.priorityClasses.Get(name)
+			if apierrors.IsNotFound(err) {
+				return c.failBuild(build, &syncError{reasonPriorityNotFound, fmt.Errorf("PriorityClass %s not found", name)})
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// Builds are admitted one at a time, so that concurrent syncs do not
+		// start more workers than the concurrency limits allow.
+		c.admitLock.Lock()
+		defer c.admitLock.Unlock()
+		admitted, err := c.admit(build, project)
+		if err != nil {
 			return err
 		}
+		if !admitted {
+			if queued {
+				return nil
+			}
+			log.Printf("Queued %s for %q [%s]", build.Name, data["event_type"], data["commit_id"])
+			span.AddEvent("build queued")
+			c.recorder.Event(build, v1.EventTypeNormal, reasonQueued, "Build is waiting for running builds to finish")
+			return c.updateBuildStatus(ctx, build, kube.StatusQueued,
+				brigade.BuildCondition{Type: brigade.BuildAccepted, Status: brigade.ConditionTrue, Reason: reasonQueued},
+				brigade.BuildCondition{Type: brigade.BuildWorkerCreated, Status: brigade.ConditionFalse, Reason: reasonQueued},
+			)
+		}
+
+		pod := NewWorkerPod(build, project, c.Config)
+		setTraceparent(&pod, tracing.Traceparent(ctx))
+		if _, err := podClient.Create(ctx, &pod, metav1.CreateOptions{}); err != nil {
+			// The worker was created by an earlier sync that failed to accept
+			// the build, such as one cut short by a new leader taking over.
+			if !apierrors.IsAlreadyExists(err) {
+				return &syncError{reasonWorkerCreateFailed, fmt.Errorf("could not create worker %s: %s", pod.Name, err)}
+			}
+			log.Printf("Worker %s already exists", pod.Name)
+			return c.acceptBuild(ctx, build)
+		}
+		workerPodsCreated.WithLabelValues(pid, string(data["event_provider"])).Inc()
+		span.AddEvent("worker created", trace.WithAttributes(label.String("brigade.worker", pod.Name)))
+		c.recorder.Eventf(build, v1.EventTypeNormal, reasonWorkerCreated, "Created worker %s", pod.Name)
 		log.Printf("Started %s for %q [%s] at %d", pod.Name, data["event_type"], data["commit_id"], pod.CreationTimestamp.Unix())
 	}
 
-	return c.updateBuildStatus(build)
+	return c.acceptBuild(ctx, build)
+}
+
+// acceptBuild marks a build whose worker exists as accepted.
+func (c *Controller) acceptBuild(ctx context.Context, build *v1.Secret) error {
+	return c.updateBuildStatus(ctx, build, "accepted",
+		brigade.BuildCondition{Type: brigade.BuildAccepted, Status: brigade.ConditionTrue},
+		brigade.BuildCondition{Type: brigade.BuildWorkerCreated, Status: brigade.ConditionTrue, Reason: reasonWorkerCreated},
+		brigade.BuildCondition{Type: brigade.BuildFailed, Status: brigade.ConditionFalse},
+	)
 }
 
-func (c *Controller) updateBuildStatus(build *v1.Secret) error {
+// updateBuildStatus sets the status label and conditions of a build secret.
+func (c *Controller) updateBuildStatus(ctx context.Context, build *v1.Secret, status string, conditions ...brigade.BuildCondition) error {
 	buildCopy := build.DeepCopy()
-	buildCopy.Labels["status"] = "accepted"
-	_, err := c.clientset.CoreV1().Secrets(build.Namespace).Update(context.TODO(), buildCopy, metav1.UpdateOptions{})
+	buildCopy.Labels["status"] = status
+	kube.SetBuildConditions(buildCopy, conditions...)
+	_, err := c.clientset.CoreV1().Secrets(build.Namespace).Update(ctx, buildCopy, metav1.UpdateOptions{})
 	return err
 }
 
+// failBuild records the error a build can not be started for, and marks the
+// build as failed so that it is not synced again.
+func (c *Controller) failBuild(build *v1.Secret, err error) error {
+	log.Printf("Failing %s: %s", build.Name, err)
+	c.recordSyncFailure(build, err)
+	if err := kube.FailBuild(c.clientset, build.Namespace, build.Labels["build"]); err != nil && err != storage.ErrBuildFinished {
+		return err
+	}
+	return nil
+}
+
+// recordSyncFailure records an event and a Failed condition on the build
+// secret of a build that could not be synced.
+func (c *Controller) recordSyncFailure(build *v1.Secret, err error) {
+	reason := reasonSyncFailed
+	var serr *syncError
+	if errors.As(err, &serr) {
+		reason = serr.reason
+	}
+	c.recorder.Event(build, v1.EventTypeWarning, reason, err.Error())
+	failed := brigade.BuildCondition{Type: brigade.BuildFailed, Status: brigade.ConditionTrue, Reason: reason, Message: err.Error()}
+	if perr := kube.PatchBuildConditions(c.clientset, build, failed); perr != nil {
+		log.Printf("failed to record the failure of build secret %s/%s: %s", build.Namespace, build.Name, perr)
+	}
+}
+
+// setTraceparent sets the TRACEPARENT of the containers of a worker pod, so
+// that the spans of the worker are children of the span that created it.
+func setTraceparent(pod *v1.Pod, traceparent string) {
+	if traceparent == "" {
+		return
+	}
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for i := range containers {
+			for j := range containers[i].Env {
+				if containers[i].Env[j].Name == tracing.EnvTraceparent {
+					containers[i].Env[j].Value = traceparent
+				}
+			}
+		}
+	}
+}
+
 // NewWorkerPod returns pod context to create a worker pod
 func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 	env := workerEnv(project, build, config)
@@ -162,6 +340,8 @@ func NewWorkerPod(build, project *v1.Secret, config *Config) v1.Pod {
 		InitContainers: initContainers,
 		Volumes:        volumes,
 		RestartPolicy:  v1.RestartPolicyNever,
+		// An empty name gives the worker the default priority of the cluster.
+		PriorityClassName: kube.BuildPriority(build, project),
 	}
 
 	if scriptName := project.Data["defaultScriptName"]; len(scriptName) > 0 {
@@ -270,6 +450,7 @@ func workerEnv(project, build *v1.Secret, config *Config) []v1.EnvVar {
 		{Name: "BRIGADE_COMMIT_REF", Value: bsv.String("commit_ref")},
 		{Name: "BRIGADE_EVENT_PROVIDER", Value: bsv.String("event_provider")},
 		{Name: "BRIGADE_EVENT_TYPE", Value: bsv.String("event_type")},
+		{Name: tracing.EnvTraceparent, Value: bsv.String("traceparent")},
 		{Name: "BRIGADE_PROJECT_ID", Value: bsv.String("project_id")},
 		{Name: "BRIGADE_LOG_LEVEL", Value: bsv.String("log_level")},
 		{Name: "BRIGADE_REMOTE_URL", Value: cloneURL},
@@ -1,10 +1,21 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
 )
 
 func TestNewWorkerPod_Defaults(t *testing.T) {
@@ -105,6 +116,64 @@ func TestNewWorkerPod_NoSidecar(t *testing.T) {
 	}
 }
 
+func TestNewWorkerPod_Priority(t *testing.T) {
+	proj := &v1.Secret{
+		Data: map[string][]byte{
+			"defaultPriority":   []byte("brigade-low"),
+			"allowedPriorities": []byte("brigade-high, brigade-urgent"),
+		},
+	}
+	config := &Config{
+		Namespace: v1.NamespaceDefault,
+	}
+
+	pod := NewWorkerPod(&v1.Secret{}, proj, config)
+	if pod.Spec.PriorityClassName != "brigade-low" {
+		t.Errorf("expected the default priority of the project, got %q", pod.Spec.PriorityClassName)
+	}
+
+	build := &v1.Secret{
+		Data: map[string][]byte{
+			"priority": []byte("brigade-high"),
+		},
+	}
+	pod = NewWorkerPod(build, proj, config)
+	if pod.Spec.PriorityClassName != "brigade-high" {
+		t.Errorf("expected the priority of the build, got %q", pod.Spec.PriorityClassName)
+	}
+
+	// A priority the project does not allow, such as one set by whoever sent
+	// a webhook, is ignored.
+	build.Data["priority"] = []byte("system-cluster-critical")
+	pod = NewWorkerPod(build, proj, config)
+	if pod.Spec.PriorityClassName != "brigade-low" {
+		t.Errorf("expected the default priority of the project, got %q", pod.Spec.PriorityClassName)
+	}
+}
+
+func TestNewWorkerPod_Traceparent(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	build := &v1.Secret{
+		Data: map[string][]byte{
+			"traceparent": []byte(traceparent),
+		},
+	}
+	config := &Config{
+		Namespace: v1.NamespaceDefault,
+	}
+
+	pod := NewWorkerPod(build, &v1.Secret{}, config)
+	for _, env := range pod.Spec.Containers[0].Env {
+		if env.Name == "TRACEPARENT" {
+			if env.Value != traceparent {
+				t.Errorf("expected the traceparent of the build, got %q", env.Value)
+			}
+			return
+		}
+	}
+	t.Error("expected the worker to have a TRACEPARENT")
+}
+
 func TestNewWorkerPod_WorkerEnv_ServiceAccount(t *testing.T) {
 	testcases := []struct {
 		name        string
@@ -188,3 +257,194 @@ func TestNewWorkerPod_WorkerEnv_ServiceAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestSyncSecret_WorkerAlreadyExists(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+
+	project := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, metav1.CreateOptions{})
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "moby",
+		Namespace: v1.NamespaceDefault,
+		Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+	}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, metav1.CreateOptions{})
+	controller.indexer.Add(build)
+
+	// Another replica created the worker after this one found it missing.
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewAlreadyExists(v1.Resource("pods"), build.Name)
+	})
+
+	if err := controller.syncSecret(build); err != nil {
+		t.Fatal(err)
+	}
+	sec, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sec.Labels["status"] != "accepted" {
+		t.Errorf("expected label 'status=accepted', got %q", sec.Labels["status"])
+	}
+	for _, c := range kube.BuildConditions(*sec) {
+		if c.Type == brigade.BuildWorkerCreated && c.Status != brigade.ConditionTrue {
+			t.Errorf("expected the WorkerCreated condition to be true, got %v", c)
+		}
+	}
+}
+
+func TestSyncSecret_WorkerCreateFailed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	project := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, metav1.CreateOptions{})
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "moby",
+		Namespace: v1.NamespaceDefault,
+		Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+	}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, metav1.CreateOptions{})
+	controller.indexer.Add(build)
+
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(v1.Resource("pods"), build.Name, errors.New("exceeded quota"))
+	})
+
+	if err := controller.syncSecret(build); err == nil {
+		t.Fatal("expected the sync to fail")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning WorkerCreateFailed ") {
+		t.Errorf("expected a WorkerCreateFailed warning, got %q", event)
+	}
+	sec, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conditions := kube.BuildConditions(*sec)
+	if len(conditions) != 1 {
+		t.Fatalf("expected one condition, got %v", conditions)
+	}
+	if c := conditions[0]; c.Type != brigade.BuildFailed || c.Status != brigade.ConditionTrue || c.Reason != "WorkerCreateFailed" {
+		t.Errorf("unexpected condition %v", c)
+	}
+	if !strings.Contains(conditions[0].Message, "exceeded quota") {
+		t.Errorf("expected the message to explain the failure, got %q", conditions[0].Message)
+	}
+}
+
+func TestSyncSecret_ProjectNotFound(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	build := &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+		Name:      "moby",
+		Namespace: v1.NamespaceDefault,
+		Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+	}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, metav1.CreateOptions{})
+
+	if err := controller.syncSecret(build); err == nil {
+		t.Fatal("expected the sync to fail")
+	}
+	if event := <-recorder.Events; event != "Warning ProjectNotFound project ahab not found" {
+		t.Errorf("unexpected event %q", event)
+	}
+	sec, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conditions := kube.BuildConditions(*sec); len(conditions) != 1 || conditions[0].Reason != "ProjectNotFound" {
+		t.Errorf("expected a ProjectNotFound condition, got %v", conditions)
+	}
+}
+
+func TestSyncSecret_PriorityClassNotFound(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	recorder := record.NewFakeRecorder(10)
+	controller.recorder = recorder
+
+	project := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"allowedPriorities": []byte("urgent")},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, metav1.CreateOptions{})
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+		},
+		Data: map[string][]byte{"priority": []byte("urgent")},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, metav1.CreateOptions{})
+
+	// The build fails rather than being retried.
+	if err := controller.syncSecret(build); err != nil {
+		t.Fatal(err)
+	}
+	if event := <-recorder.Events; event != "Warning PriorityClassNotFound PriorityClass urgent not found" {
+		t.Errorf("unexpected event %q", event)
+	}
+	sec, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := sec.Labels["status"]; status != kube.StatusFailed {
+		t.Errorf("expected the build to be marked as failed, got status %q", status)
+	}
+	if conditions := kube.BuildConditions(*sec); len(conditions) != 1 || conditions[0].Reason != "PriorityClassNotFound" {
+		t.Errorf("expected a PriorityClassNotFound condition, got %v", conditions)
+	}
+	if w := kube.NewWorkerFromSecret(*sec); w == nil || w.Status != brigade.JobFailed {
+		t.Errorf("expected a failed worker, got %v", w)
+	}
+	pods, _ := client.CoreV1().Pods(v1.NamespaceDefault).List(context.TODO(), metav1.ListOptions{})
+	if len(pods.Items) != 0 {
+		t.Errorf("expected no worker, got %d pods", len(pods.Items))
+	}
+}
+
+func TestSyncSecret_Traceparent(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	controller.recorder = record.NewFakeRecorder(10)
+
+	project := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, metav1.CreateOptions{})
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	build := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "moby",
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+		},
+		Data: map[string][]byte{"traceparent": []byte(traceparent)},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, metav1.CreateOptions{})
+
+	if err := controller.syncSecret(build); err != nil {
+		t.Fatal(err)
+	}
+	pod, err := client.CoreV1().Pods(v1.NamespaceDefault).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range pod.Spec.Containers[0].Env {
+		if env.Name == "TRACEPARENT" {
+			// The worker continues the trace of the build as a child of the
+			// span of the sync.
+			if !strings.HasPrefix(env.Value, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || env.Value == traceparent {
+				t.Errorf("expected a child of the sync span in the trace of the build, got %q", env.Value)
+			}
+			return
+		}
+	}
+	t.Error("expected the worker to have a TRACEPARENT")
+}
@@ -5,10 +5,13 @@ import (
 	"log"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 func (c *Controller) createIndexerInformer() {
@@ -17,11 +20,22 @@ func (c *Controller) createIndexerInformer() {
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
 				options.FieldSelector = selector
-				return c.clientset.CoreV1().Secrets(c.Namespace).List(context.TODO(), options)
+				list, err := c.clientset.CoreV1().Secrets(c.namespaceSet().Watch()).List(context.TODO(), options)
+				if err != nil {
+					return nil, err
+				}
+				items := []v1.Secret{}
+				for _, s := range list.Items {
+					if c.watched(&s) {
+						items = append(items, s)
+					}
+				}
+				list.Items = items
+				return list, nil
 			},
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
 				options.FieldSelector = selector
-				return c.clientset.CoreV1().Secrets(c.Namespace).Watch(context.TODO(), options)
+				return c.filterWatch(c.clientset.CoreV1().Secrets(c.namespaceSet().Watch()).Watch(context.TODO(), options))
 			},
 		},
 		&v1.Secret{},
@@ -31,9 +45,77 @@ func (c *Controller) createIndexerInformer() {
 				if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
 					log.Println("Adding to workqueue: ", key)
 					c.queue.Add(key)
+					queueDepth.Set(float64(c.queue.Len()))
 				}
 			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.recordBuildTerminated(oldObj.(*v1.Secret), newObj.(*v1.Secret))
+			},
+			// A deleted build no longer waits for, or takes up, a worker.
+			DeleteFunc: func(obj interface{}) {
+				c.requeueQueued()
+			},
 		},
 		cache.Indexers{},
 	)
 }
+
+func (c *Controller) createWorkerInformer() {
+	selector := "heritage=brigade,component=build"
+	c.workerStore, c.workerInformer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				list, err := c.clientset.CoreV1().Pods(c.namespaceSet().Watch()).List(context.TODO(), options)
+				if err != nil {
+					return nil, err
+				}
+				items := []v1.Pod{}
+				for _, p := range list.Items {
+					if c.watched(&p) {
+						items = append(items, p)
+					}
+				}
+				list.Items = items
+				return list, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return c.filterWatch(c.clientset.CoreV1().Pods(c.namespaceSet().Watch()).Watch(context.TODO(), options))
+			},
+		},
+		&v1.Pod{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				if !finished(oldObj.(*v1.Pod)) && finished(newObj.(*v1.Pod)) {
+					c.recordWorkerFinished(newObj.(*v1.Pod))
+					c.retry(newObj.(*v1.Pod))
+					c.requeueQueued()
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				c.requeueQueued()
+			},
+		},
+	)
+}
+
+// watched returns true if an object lives in a namespace of the controller.
+// Objects that have no namespace, such as the status of a failed watch, are
+// always watched.
+func (c *Controller) watched(obj runtime.Object) bool {
+	o, err := meta.Accessor(obj)
+	return err != nil || namespaces.Watched(c.namespaceSet(), o.GetNamespace())
+}
+
+// filterWatch drops the events of objects that do not live in a namespace of
+// the controller.
+func (c *Controller) filterWatch(w watch.Interface, err error) (watch.Interface, error) {
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+		return e, c.watched(e.Object)
+	}), nil
+}
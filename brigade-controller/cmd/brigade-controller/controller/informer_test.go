@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+func TestController_Namespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	config := &Config{
+		Namespace:  "brigade",
+		Namespaces: namespaces.List("brigade", "ahab"),
+	}
+	controller := NewController(client, config)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go controller.Run(1, stop)
+
+	for _, ns := range []string{"ahab", "ishmael"} {
+		project := &v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "pequod", Namespace: ns}}
+		client.CoreV1().Secrets(ns).Create(context.TODO(), project, meta.CreateOptions{})
+		build := &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "moby",
+				Namespace: ns,
+				Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "pequod", "build": "queequeg-" + ns},
+			},
+			Type: "brigade.sh/build",
+		}
+		client.CoreV1().Secrets(ns).Create(context.TODO(), build, meta.CreateOptions{})
+	}
+
+	err := wait.Poll(100*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		_, err := client.CoreV1().Pods("ahab").Get(context.TODO(), "moby", meta.GetOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatal("expected a worker to be started in the namespace of the build")
+	}
+	if _, err := client.CoreV1().Pods("ishmael").Get(context.TODO(), "moby", meta.GetOptions{}); err == nil {
+		t.Error("expected no worker to be started in a namespace outside of the set")
+	}
+}
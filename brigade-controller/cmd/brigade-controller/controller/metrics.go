@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+var (
+	queueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "queue_depth",
+		Help:      "Number of build secrets waiting to be synced.",
+	})
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "sync_duration_seconds",
+		Help:      "Time it takes to sync a build secret, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+	requeues = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "requeues_total",
+		Help:      "Number of build secrets requeued after a failed sync.",
+	})
+	workerPodsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "worker_pods_created_total",
+		Help:      "Number of worker pods created, by project and provider.",
+	}, []string{"project", "provider"})
+	buildsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "builds_finished_total",
+		Help:      "Number of builds that finished, by project, provider and status.",
+	}, []string{"project", "provider", "status"})
+	buildsRetried = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "controller",
+		Name:      "builds_retried_total",
+		Help:      "Number of failed builds retried by the retry policy of their project, by project.",
+	}, []string{"project"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth, syncDuration, requeues, workerPodsCreated, buildsFinished, buildsRetried)
+}
+
+// recordBuildFinished counts a finished build, whose worker has the given
+// status.
+func (c *Controller) recordBuildFinished(namespace, name string, status brigade.JobStatus) {
+	project, provider := "", ""
+	if obj, exists, err := c.indexer.GetByKey(namespace + "/" + name); err == nil && exists {
+		build := obj.(*v1.Secret)
+		project, provider = build.Labels["project"], string(build.Data["event_provider"])
+	}
+	buildsFinished.WithLabelValues(project, provider, string(status)).Inc()
+}
+
+// recordWorkerFinished counts the build of a worker pod that has finished.
+func (c *Controller) recordWorkerFinished(pod *v1.Pod) {
+	c.recordBuildFinished(pod.Namespace, pod.Name, kube.NewWorkerFromPod(*pod).Status)
+}
+
+// recordBuildTerminated counts a build that was terminated before its worker
+// was created.
+func (c *Controller) recordBuildTerminated(oldBuild, newBuild *v1.Secret) {
+	w := kube.NewWorkerFromSecret(*newBuild)
+	if w == nil || w.Status == brigade.JobQueued || oldBuild.Labels["status"] == newBuild.Labels["status"] {
+		return
+	}
+	if _, exists, _ := c.workerStore.GetByKey(newBuild.Namespace + "/" + newBuild.Name); exists {
+		// The build is counted once its worker finishes.
+		return
+	}
+	c.recordBuildFinished(newBuild.Namespace, newBuild.Name, w.Status)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// admit returns true if the worker of a build may be started now.
+//
+// Builds wait while their project, or the controller, runs as many builds as
+// it may run at the same time. Waiting builds are started in the order of
+// kube.QueueState.Sort, skipping the builds of projects that are at their
+// limit.
+func (c *Controller) admit(build, project *v1.Secret) (bool, error) {
+	if c.MaxConcurrentBuilds <= 0 && maxConcurrentBuilds(project) <= 0 {
+		return true, nil
+	}
+
+	waiting := c.waitingBuilds()
+	q, err := kube.LoadQueueState(c.clientset, c.priorityClasses, c.namespaceSet(), waiting)
+	if err != nil {
+		return false, err
+	}
+	q.Sort(waiting)
+	total := 0
+	for _, n := range q.Running {
+		total += n
+	}
+
+	// Limits and running builds are counted by project key, so that projects
+	// with the same ID in different namespaces do not share them.
+	limits := map[string]int{q.ProjectKey(build.ObjectMeta): maxConcurrentBuilds(project)}
+	for i := range waiting {
+		if c.MaxConcurrentBuilds > 0 && total >= c.MaxConcurrentBuilds {
+			return false, nil
+		}
+		w := &waiting[i]
+		key := q.ProjectKey(w.ObjectMeta)
+		limit, ok := limits[key]
+		if !ok {
+			if p := q.Project(w); p == nil {
+				// The build can not be started without its project.
+				limit = -1
+			} else {
+				limit = maxConcurrentBuilds(p)
+			}
+			limits[key] = limit
+		}
+		if limit < 0 || (limit > 0 && q.Running[key] >= limit) {
+			continue
+		}
+		if w.Name == build.Name && w.Namespace == build.Namespace {
+			return true, nil
+		}
+		q.Running[key]++
+		total++
+	}
+	return false, nil
+}
+
+// waitingBuilds returns the build secrets whose worker has not been started.
+func (c *Controller) waitingBuilds() []v1.Secret {
+	waiting := []v1.Secret{}
+	for _, obj := range c.indexer.List() {
+		s := obj.(*v1.Secret)
+		if status := s.Labels["status"]; status == "" || status == kube.StatusQueued {
+			waiting = append(waiting, *s)
+		}
+	}
+	return waiting
+}
+
+// queueResyncInterval is how often queued builds are checked, in case a change
+// that lets them start was missed.
+const queueResyncInterval = time.Minute
+
+// requeueQueued adds the queued builds to the workqueue, so that they are
+// started if they may be. It is called when a worker finishes, when a worker or
+// build is deleted, and every queueResyncInterval.
+func (c *Controller) requeueQueued() {
+	for _, obj := range c.indexer.List() {
+		if obj.(*v1.Secret).Labels["status"] != kube.StatusQueued {
+			continue
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+}
+
+// maxConcurrentBuilds returns the maximum number of builds of a project that
+// run at the same time, or 0 if there is no limit.
+func maxConcurrentBuilds(project *v1.Secret) int {
+	max, ok := project.Data["maxConcurrentBuilds"]
+	if !ok || len(max) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(string(max))
+	if err != nil || n < 0 {
+		log.Printf("project %s has an invalid maxConcurrentBuilds %q: %s", project.Name, max, err)
+		return 0
+	}
+	return n
+}
+
+// finished returns true if a worker pod has terminated.
+func finished(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+func TestAdmit(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault, MaxConcurrentBuilds: 2})
+
+	projects := map[string]*v1.Secret{}
+	for pid, max := range map[string]string{"ahab": "1", "starbuck": ""} {
+		projects[pid] = &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: pid, Namespace: v1.NamespaceDefault},
+			Data:       map[string][]byte{"maxConcurrentBuilds": []byte(max)},
+		}
+		client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), projects[pid], meta.CreateOptions{})
+	}
+	created := time.Now()
+	build := func(name, pid string) *v1.Secret {
+		created = created.Add(time.Second)
+		s := &v1.Secret{ObjectMeta: meta.ObjectMeta{
+			Name:              name,
+			Namespace:         v1.NamespaceDefault,
+			Labels:            map[string]string{"heritage": "brigade", "component": "build", "project": pid, "build": name},
+			CreationTimestamp: meta.NewTime(created),
+		}}
+		controller.indexer.Add(s)
+		return s
+	}
+	worker := func(name, pid string, phase v1.PodPhase) {
+		pod := &v1.Pod{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: v1.NamespaceDefault, Labels: map[string]string{"heritage": "brigade", "component": "build", "project": pid, "build": name}},
+			Status:     v1.PodStatus{Phase: phase},
+		}
+		client.CoreV1().Pods(v1.NamespaceDefault).Create(context.TODO(), pod, meta.CreateOptions{})
+	}
+
+	worker("running", "ahab", v1.PodRunning)
+	worker("done", "starbuck", v1.PodSucceeded)
+	first := build("first", "ahab")
+	second := build("second", "ahab")
+	third := build("third", "starbuck")
+	fourth := build("fourth", "starbuck")
+
+	tests := []struct {
+		build    *v1.Secret
+		admitted bool
+	}{
+		// ahab runs as many builds as it may.
+		{first, false},
+		{second, false},
+		// starbuck has no limit, but only one more build may run in total.
+		{third, true},
+		{fourth, false},
+	}
+	for _, tt := range tests {
+		admitted, err := controller.admit(tt.build, projects[tt.build.Labels["project"]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admitted != tt.admitted {
+			t.Errorf("expected %s to be admitted: %t", tt.build.Name, tt.admitted)
+		}
+	}
+
+	// Once ahab's build finishes, its oldest build is started first.
+	client.CoreV1().Pods(v1.NamespaceDefault).Delete(context.TODO(), "running", meta.DeleteOptions{})
+	for _, tt := range []struct {
+		build    *v1.Secret
+		admitted bool
+	}{{first, true}, {second, false}} {
+		admitted, err := controller.admit(tt.build, projects["ahab"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admitted != tt.admitted {
+			t.Errorf("expected %s to be admitted: %t", tt.build.Name, tt.admitted)
+		}
+	}
+}
+
+func TestAdmitProjectsInNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{
+		Namespace:  v1.NamespaceDefault,
+		Namespaces: namespaces.List(v1.NamespaceDefault, "pequod"),
+	})
+
+	// Both namespaces have a project ahab that runs one build at a time.
+	projects := map[string]*v1.Secret{}
+	for _, ns := range []string{v1.NamespaceDefault, "pequod"} {
+		projects[ns] = &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: ns},
+			Data:       map[string][]byte{"maxConcurrentBuilds": []byte("1")},
+		}
+		client.CoreV1().Secrets(ns).Create(context.TODO(), projects[ns], meta.CreateOptions{})
+	}
+	labels := map[string]string{"heritage": "brigade", "component": "build", "project": "ahab"}
+	client.CoreV1().Pods(v1.NamespaceDefault).Create(context.TODO(), &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "running", Namespace: v1.NamespaceDefault, Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}, meta.CreateOptions{})
+
+	created := time.Now()
+	builds := map[string]*v1.Secret{}
+	for _, ns := range []string{v1.NamespaceDefault, "pequod"} {
+		created = created.Add(time.Second)
+		builds[ns] = &v1.Secret{ObjectMeta: meta.ObjectMeta{
+			Name:              "waiting",
+			Namespace:         ns,
+			Labels:            map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "waiting-" + ns},
+			CreationTimestamp: meta.NewTime(created),
+		}}
+		controller.indexer.Add(builds[ns])
+	}
+
+	// The running build of ahab in default does not count against ahab in
+	// pequod.
+	for ns, expect := range map[string]bool{v1.NamespaceDefault: false, "pequod": true} {
+		admitted, err := controller.admit(builds[ns], projects[ns])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if admitted != expect {
+			t.Errorf("expected the build in %s to be admitted: %t", ns, expect)
+		}
+	}
+}
+
+func TestSyncSecretQueued(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault, MaxConcurrentBuilds: 1})
+
+	project := &v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, meta.CreateOptions{})
+	running := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "running", Namespace: v1.NamespaceDefault, Labels: map[string]string{"heritage": "brigade", "component": "build", "project": "ahab"}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	client.CoreV1().Pods(v1.NamespaceDefault).Create(context.TODO(), running, meta.CreateOptions{})
+	build := &v1.Secret{ObjectMeta: meta.ObjectMeta{
+		Name:      "moby",
+		Namespace: v1.NamespaceDefault,
+		Labels:    map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "queequeg"},
+	}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, meta.CreateOptions{})
+	controller.indexer.Add(build)
+
+	if err := controller.syncSecret(build); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Pods(v1.NamespaceDefault).Get(context.TODO(), build.Name, meta.GetOptions{}); err == nil {
+		t.Error("expected the worker not to be started")
+	}
+	sec, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sec.Labels["status"] != kube.StatusQueued {
+		t.Errorf("expected label 'status=%s', got %q", kube.StatusQueued, sec.Labels["status"])
+	}
+}
+
+func TestRequeueQueued(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), &Config{Namespace: v1.NamespaceDefault})
+	for name, status := range map[string]string{"queued": kube.StatusQueued, "accepted": "accepted", "cancelled": kube.StatusCancelled} {
+		controller.indexer.Add(&v1.Secret{ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: v1.NamespaceDefault,
+			Labels:    map[string]string{"heritage": "brigade", "component": "build", "status": status},
+		}})
+	}
+
+	controller.requeueQueued()
+	if controller.queue.Len() != 1 {
+		t.Fatalf("expected only the queued build to be requeued, got %d builds", controller.queue.Len())
+	}
+	if key, _ := controller.queue.Get(); key != "default/queued" {
+		t.Errorf("expected default/queued to be requeued, got %v", key)
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// reasonRetried is the reason of the event recorded on a build that was
+// retried.
+const reasonRetried = "Retried"
+
+// retry creates a build that retries the build of a finished worker, if the
+// worker failed and the retry policy of its project retries it.
+func (c *Controller) retry(worker *v1.Pod) {
+	c.retryLock.Lock()
+	defer c.retryLock.Unlock()
+
+	w := kube.NewWorkerFromPod(*worker)
+	if w.Status != brigade.JobFailed {
+		return
+	}
+	obj, exists, err := c.indexer.GetByKey(worker.Namespace + "/" + worker.Name)
+	if err != nil || !exists {
+		return
+	}
+	build := obj.(*v1.Secret)
+	if _, ok := build.Annotations[kube.RetriedByAnnotation]; ok {
+		return
+	}
+	// The indexer may not have seen the annotation yet.
+	build, err = c.clientset.CoreV1().Secrets(build.Namespace).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("retry: could not get build %s: %s", worker.Name, err)
+		return
+	}
+	if _, ok := build.Annotations[kube.RetriedByAnnotation]; ok {
+		return
+	}
+
+	pid := build.Labels["project"]
+	secret, err := c.clientset.CoreV1().Secrets(build.Namespace).Get(context.TODO(), pid, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("retry: could not get project %s: %s", pid, err)
+		return
+	}
+	project, err := kube.NewProjectFromSecret(secret, build.Namespace)
+	if err != nil {
+		log.Printf("retry: could not read project %s: %s", pid, err)
+		return
+	}
+	attempt := kube.BuildAttempt(*build)
+	if !project.Retry.Retries(attempt, w.ExitCode, w.Reason) {
+		return
+	}
+
+	delay := project.Retry.Delay(attempt)
+	bid, err := kube.RetryBuild(c.clientset, build, time.Now().Add(delay))
+	if bid == "" {
+		log.Printf("retry: could not retry build %s: %s", build.Labels["build"], err)
+		return
+	}
+	if err != nil {
+		log.Printf("retry: could not mark build %s as retried by build %s: %s", build.Labels["build"], bid, err)
+	}
+	buildsRetried.WithLabelValues(pid).Inc()
+	log.Printf("Build %s failed, retrying it as build %s in %s (attempt %d of %d)", build.Labels["build"], bid, delay, attempt+1, project.Retry.MaxAttempts)
+	c.recorder.Eventf(build, v1.EventTypeNormal, reasonRetried, "Retrying as build %s in %s (attempt %d of %d)", bid, delay, attempt+1, project.Retry.MaxAttempts)
+}
+
+// retryFinished retries the build of an accepted build secret if its worker
+// already failed, so that failures the worker informer missed, such as while
+// the controller was down, are retried too. Builds that were retried are
+// annotated with kube.RetriedByAnnotation, so they are not retried again.
+func (c *Controller) retryFinished(build *v1.Secret) {
+	obj, exists, err := c.workerStore.GetByKey(build.Namespace + "/" + build.Name)
+	if err != nil || !exists {
+		return
+	}
+	if worker := obj.(*v1.Pod); finished(worker) {
+		c.retry(worker)
+	}
+}
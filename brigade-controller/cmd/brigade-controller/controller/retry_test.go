@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestRetry(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	controller.recorder = record.NewFakeRecorder(10)
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data: map[string][]byte{
+			"retry.maxAttempts": []byte("2"),
+			"retry.backoff":     []byte("1m"),
+			"retry.reasons":     []byte("Evicted"),
+		},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, meta.CreateOptions{})
+
+	labels := map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "moby"}
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-worker-moby", Namespace: v1.NamespaceDefault, Labels: labels},
+		Type:       "brigade.sh/build",
+		Data:       map[string][]byte{"payload": []byte("whale"), "event_type": []byte("push")},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, meta.CreateOptions{})
+	controller.indexer.Add(build)
+
+	started := meta.Now()
+	worker := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: build.Name, Namespace: v1.NamespaceDefault, Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodFailed, StartTime: &started, Reason: "Evicted"},
+	}
+	controller.retry(worker)
+
+	retries, err := client.CoreV1().Secrets(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{LabelSelector: "component=build,build!=moby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retries.Items) != 1 {
+		t.Fatalf("expected one retry, got %d", len(retries.Items))
+	}
+	retry := stored(retries.Items[0])
+	client.CoreV1().Secrets(v1.NamespaceDefault).Update(context.TODO(), &retry, meta.UpdateOptions{})
+	b := kube.NewBuildFromSecret(retry)
+	if b.Attempt != 2 || b.ParentBuildID != "moby" || string(b.Payload) != "whale" || b.Type != "push" {
+		t.Errorf("unexpected retry %+v", b)
+	}
+	if b.NotBefore == nil || time.Until(*b.NotBefore) < 50*time.Second {
+		t.Errorf("expected the retry to wait for a minute, got %v", b.NotBefore)
+	}
+	parent, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build.Name, meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.Annotations[kube.RetriedByAnnotation] != b.ID {
+		t.Errorf("expected the build to be retried by %s, got %q", b.ID, parent.Annotations[kube.RetriedByAnnotation])
+	}
+
+	// The worker of the retry waits for the backoff.
+	if err := controller.syncSecret(&retry); err != nil {
+		t.Fatal(err)
+	}
+	if pods, _ := client.CoreV1().Pods(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{}); len(pods.Items) != 0 {
+		t.Errorf("expected no worker before the backoff, got %d", len(pods.Items))
+	}
+
+	// The retry is the last attempt.
+	controller.indexer.Add(&retry)
+	worker = worker.DeepCopy()
+	worker.Name = retry.Name
+	controller.retry(worker)
+	if all, _ := client.CoreV1().Secrets(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{LabelSelector: "component=build"}); len(all.Items) != 2 {
+		t.Errorf("expected no further retry, got %d builds", len(all.Items))
+	}
+}
+
+// stored returns a secret the way the API server stores it, with its
+// StringData in its Data.
+func stored(secret v1.Secret) v1.Secret {
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+	secret.StringData = nil
+	return secret
+}
+
+func TestRetry_NotRetried(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"retry.maxAttempts": []byte("3"), "retry.exitCodes": []byte("137")},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, meta.CreateOptions{})
+
+	labels := map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "moby"}
+	build := &v1.Secret{ObjectMeta: meta.ObjectMeta{Name: "brigade-worker-moby", Namespace: v1.NamespaceDefault, Labels: labels}}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, meta.CreateOptions{})
+	controller.indexer.Add(build)
+
+	started := meta.Now()
+	worker := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: build.Name, Namespace: v1.NamespaceDefault, Labels: labels},
+		Status: v1.PodStatus{
+			Phase:     v1.PodFailed,
+			StartTime: &started,
+			ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{ExitCode: 1},
+			}}},
+		},
+	}
+	controller.retry(worker)
+
+	if all, _ := client.CoreV1().Secrets(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{LabelSelector: "component=build"}); len(all.Items) != 1 {
+		t.Errorf("expected the build not to be retried for exit code 1, got %d builds", len(all.Items))
+	}
+}
+
+func TestRetry_Sync(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	controller.recorder = record.NewFakeRecorder(10)
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"retry.maxAttempts": []byte("2"), "retry.reasons": []byte("Evicted")},
+	}
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), project, meta.CreateOptions{})
+
+	// The worker failed while the controller was down.
+	labels := map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "moby"}
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-worker-moby", Namespace: v1.NamespaceDefault, Labels: labels},
+		Type:       "brigade.sh/build",
+		Data:       map[string][]byte{"payload": []byte("whale")},
+	}
+	build.Labels["status"] = "accepted"
+	client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), build, meta.CreateOptions{})
+	controller.indexer.Add(build)
+	started := meta.Now()
+	controller.workerStore.Add(&v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: build.Name, Namespace: v1.NamespaceDefault, Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodFailed, StartTime: &started, Reason: "Evicted"},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := controller.syncSecret(build); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retries, err := client.CoreV1().Secrets(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{LabelSelector: kube.ParentBuildLabel + "=moby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(retries.Items) != 1 {
+		t.Fatalf("expected the build to be retried once, got %d retries", len(retries.Items))
+	}
+
+	// A retry whose parent was not marked as retried is not created again.
+	if _, err := kube.RetryBuild(client, build, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := client.CoreV1().Secrets(v1.NamespaceDefault).List(context.TODO(), meta.ListOptions{LabelSelector: kube.ParentBuildLabel + "=moby"}); len(all.Items) != 1 {
+		t.Errorf("expected the build to be retried once, got %d retries", len(all.Items))
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// supersede terminates the older builds of a project for the same ref as a
+// new build, if the project supersedes builds. Builds whose worker has
+// finished, and the builds of projects with the same ID in other namespaces,
+// are left alone.
+//
+// It returns a copy of the new build that records the builds it superseded,
+// to be saved along with its status.
+func (c *Controller) supersede(build, project *v1.Secret) (*v1.Secret, error) {
+	ref := string(build.Data["commit_ref"])
+	if string(project.Data["supersedeBuilds"]) != "true" || ref == "" {
+		return build, nil
+	}
+	bid := build.Labels["build"]
+
+	builds := []*v1.Secret{}
+	for _, obj := range c.indexer.List() {
+		builds = append(builds, obj.(*v1.Secret))
+	}
+	sort.Slice(builds, func(i, j int) bool { return kube.CreatedBefore(builds[i], builds[j]) })
+
+	superseded := []string{}
+	for _, older := range builds {
+		if older.Namespace != build.Namespace ||
+			older.Name == build.Name ||
+			older.Labels["project"] != build.Labels["project"] ||
+			string(older.Data["commit_ref"]) != ref ||
+			!kube.CreatedBefore(older, build) {
+			continue
+		}
+		obid := older.Labels["build"]
+		switch older.Labels["status"] {
+		case kube.StatusSuperseded:
+			// A previous attempt to sync the build may have superseded it.
+			if older.Annotations[kube.SupersededByAnnotation] == bid {
+				superseded = append(superseded, obid)
+			}
+			continue
+		case "", kube.StatusQueued:
+		case "accepted":
+			if c.workerFinished(older) {
+				continue
+			}
+		default:
+			continue
+		}
+		err := kube.SupersedeBuild(c.clientset, older.Namespace, obid, bid)
+		if err == storage.ErrBuildFinished {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Build %s supersedes build %s of %s", bid, obid, ref)
+		superseded = append(superseded, obid)
+	}
+	if len(superseded) == 0 {
+		return build, nil
+	}
+
+	build = build.DeepCopy()
+	if build.Annotations == nil {
+		build.Annotations = map[string]string{}
+	}
+	build.Annotations[kube.SupersedesAnnotation] = strings.Join(superseded, ",")
+	return build, nil
+}
+
+// workerFinished returns true if the worker of an accepted build has
+// terminated or does not exist anymore.
+func (c *Controller) workerFinished(build *v1.Secret) bool {
+	obj, exists, err := c.workerStore.GetByKey(build.Namespace + "/" + build.Name)
+	if err != nil || !exists {
+		return true
+	}
+	return finished(obj.(*v1.Pod))
+}
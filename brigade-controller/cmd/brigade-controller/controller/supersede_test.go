@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestSupersede(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault})
+	project := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{Name: "ahab", Namespace: v1.NamespaceDefault},
+		Data:       map[string][]byte{"supersedeBuilds": []byte("true")},
+	}
+
+	created := time.Now()
+	build := func(name, ref, status string, phase v1.PodPhase) *v1.Secret {
+		created = created.Add(time.Second)
+		labels := map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": name}
+		s := &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: v1.NamespaceDefault, Labels: labels, CreationTimestamp: meta.NewTime(created)},
+			Data:       map[string][]byte{"commit_ref": []byte(ref)},
+		}
+		if status != "" {
+			s.Labels["status"] = status
+		}
+		client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), s, meta.CreateOptions{})
+		controller.indexer.Add(s)
+		if phase != "" {
+			started := meta.NewTime(created)
+			pod := &v1.Pod{
+				ObjectMeta: meta.ObjectMeta{Name: name, Namespace: v1.NamespaceDefault, Labels: labels},
+				Status:     v1.PodStatus{Phase: phase, StartTime: &started},
+			}
+			client.CoreV1().Pods(v1.NamespaceDefault).Create(context.TODO(), pod, meta.CreateOptions{})
+			controller.workerStore.Add(pod)
+		}
+		return s
+	}
+
+	build("finished", "refs/heads/master", "accepted", v1.PodSucceeded)
+	build("running", "refs/heads/master", "accepted", v1.PodRunning)
+	build("queued", "refs/heads/master", kube.StatusQueued, "")
+	build("other", "refs/heads/dev", kube.StatusQueued, "")
+	// A project with the same ID in another namespace is left alone.
+	elsewhere := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:              "elsewhere",
+			Namespace:         "pequod",
+			Labels:            map[string]string{"heritage": "brigade", "component": "build", "project": "ahab", "build": "elsewhere", "status": kube.StatusQueued},
+			CreationTimestamp: meta.NewTime(created),
+		},
+		Data: map[string][]byte{"commit_ref": []byte("refs/heads/master")},
+	}
+	client.CoreV1().Secrets("pequod").Create(context.TODO(), elsewhere, meta.CreateOptions{})
+	controller.indexer.Add(elsewhere)
+	newest := build("newest", "refs/heads/master", "", "")
+
+	updated, err := controller.supersede(newest, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if supersedes := updated.Annotations[kube.SupersedesAnnotation]; supersedes != "running,queued" {
+		t.Errorf("expected the new build to supersede running,queued, got %q", supersedes)
+	}
+	for name, status := range map[string]string{
+		"finished": "accepted",
+		"running":  kube.StatusSuperseded,
+		"queued":   kube.StatusSuperseded,
+		"other":    kube.StatusQueued,
+	} {
+		s, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), name, meta.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Labels["status"] != status {
+			t.Errorf("expected build %s to be %s, got %s", name, status, s.Labels["status"])
+		}
+		if status == kube.StatusSuperseded && s.Annotations[kube.SupersededByAnnotation] != "newest" {
+			t.Errorf("expected build %s to be superseded by newest, got %q", name, s.Annotations[kube.SupersededByAnnotation])
+		}
+	}
+	if s, _ := client.CoreV1().Secrets("pequod").Get(context.TODO(), "elsewhere", meta.GetOptions{}); s.Labels["status"] != kube.StatusQueued {
+		t.Errorf("expected the build of the project in another namespace to be queued, got %s", s.Labels["status"])
+	}
+
+	project.Data["supersedeBuilds"] = []byte("false")
+	if updated, _ := controller.supersede(newest, project); updated != newest {
+		t.Error("expected builds not to be superseded unless the project opts in")
+	}
+}
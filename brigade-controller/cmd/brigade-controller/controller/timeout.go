@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// timeoutCheckInterval is how often running workers are checked for timeouts.
+const timeoutCheckInterval = 30 * time.Second
+
+// enforceTimeouts terminates the builds whose worker has been running for
+// longer than the build timeout of their project.
+func (c *Controller) enforceTimeouts() {
+	podClient := c.clientset.CoreV1().Pods(c.namespaceSet().Watch())
+	workers, err := podClient.List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "heritage=brigade,component=build",
+	})
+	if err != nil {
+		log.Printf("enforceTimeouts: could not list workers: %s", err)
+		return
+	}
+
+	timeouts := map[string]time.Duration{}
+	for _, pod := range workers.Items {
+		if pod.Status.StartTime == nil || finished(&pod) || !c.watched(&pod) {
+			continue
+		}
+		if _, ok := pod.Annotations[kube.CancelledAnnotation]; ok {
+			continue
+		}
+		if _, ok := pod.Annotations[kube.TimedOutAnnotation]; ok {
+			continue
+		}
+		// A project lives in the namespace of its builds.
+		key := pod.Namespace + "/" + pod.Labels["project"]
+		timeout, ok := timeouts[key]
+		if !ok {
+			timeout = c.buildTimeout(pod.Namespace, pod.Labels["project"])
+			timeouts[key] = timeout
+		}
+		if timeout == 0 || time.Since(pod.Status.StartTime.Time) < timeout {
+			continue
+		}
+		bid := pod.Labels["build"]
+		log.Printf("Build %s has been running for longer than %s, terminating it", bid, timeout)
+		// A build that was cancelled or superseded may still be running
+		// until its pods terminate.
+		if err := kube.TimeOutBuild(c.clientset, pod.Namespace, bid); err != nil && err != storage.ErrBuildFinished {
+			log.Printf("enforceTimeouts: could not terminate build %s: %s", bid, err)
+		}
+	}
+}
+
+// buildTimeout returns the build timeout of a project, which defaults to the
+// build timeout of the controller.
+func (c *Controller) buildTimeout(namespace, pid string) time.Duration {
+	project, err := c.clientset.CoreV1().Secrets(namespace).Get(context.TODO(), pid, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("enforceTimeouts: could not get project %s: %s", pid, err)
+		return c.BuildTimeout
+	}
+	timeout, ok := project.Data["buildTimeout"]
+	if !ok || len(timeout) == 0 {
+		return c.BuildTimeout
+	}
+	d, err := time.ParseDuration(string(timeout))
+	if err != nil {
+		log.Printf("enforceTimeouts: project %s has an invalid buildTimeout %q: %s", pid, timeout, err)
+		return c.BuildTimeout
+	}
+	return d
+}
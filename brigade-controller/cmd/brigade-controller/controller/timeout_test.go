@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestEnforceTimeouts(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	controller := NewController(client, &Config{Namespace: v1.NamespaceDefault, BuildTimeout: time.Hour})
+
+	started := meta.NewTime(time.Now().Add(-10 * time.Minute))
+	for _, p := range []struct{ project, build, timeout string }{
+		{"ahab", "queequeg", "5m"},
+		{"starbuck", "stubb", ""},
+	} {
+		project := v1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: p.project, Namespace: v1.NamespaceDefault},
+			Data:       map[string][]byte{"buildTimeout": []byte(p.timeout)},
+		}
+		labels := map[string]string{"heritage": "brigade", "component": "build", "project": p.project, "build": p.build}
+		build := v1.Secret{ObjectMeta: meta.ObjectMeta{Name: p.build, Namespace: v1.NamespaceDefault, Labels: labels}}
+		worker := v1.Pod{
+			ObjectMeta: meta.ObjectMeta{Name: p.build, Namespace: v1.NamespaceDefault, Labels: labels},
+			Status:     v1.PodStatus{Phase: v1.PodRunning, StartTime: &started},
+		}
+		client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), &project, meta.CreateOptions{})
+		client.CoreV1().Secrets(v1.NamespaceDefault).Create(context.TODO(), &build, meta.CreateOptions{})
+		client.CoreV1().Pods(v1.NamespaceDefault).Create(context.TODO(), &worker, meta.CreateOptions{})
+	}
+
+	controller.enforceTimeouts()
+
+	for build, timedOut := range map[string]bool{"queequeg": true, "stubb": false} {
+		pod, err := client.CoreV1().Pods(v1.NamespaceDefault).Get(context.TODO(), build, meta.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := pod.Annotations[kube.TimedOutAnnotation]; ok != timedOut {
+			t.Errorf("expected worker %s to be timed out: %t", build, timedOut)
+		}
+		secret, err := client.CoreV1().Secrets(v1.NamespaceDefault).Get(context.TODO(), build, meta.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (secret.Labels["status"] == kube.StatusTimedOut) != timedOut {
+			t.Errorf("expected build %s to be timed out: %t", build, timedOut)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// The timing of leader election, as used by the Kubernetes controller manager.
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// leaderElectionConfig configures the election of the replica of the
+// controller that runs.
+type leaderElectionConfig struct {
+	enabled bool
+	lease   string
+}
+
+// runAsLeader calls run once this replica holds the lease of the controller,
+// so that only one replica starts workers at a time. The process exits if the
+// lease is lost, so that a new leader never runs alongside an old one.
+//
+// If leader election is disabled, run is called right away.
+func runAsLeader(clientset kubernetes.Interface, namespace string, config leaderElectionConfig, run func(stop <-chan struct{})) {
+	if !config.enabled {
+		run(make(chan struct{}))
+		return
+	}
+
+	id, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("error getting the leader election identity (%s)", err)
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: config.lease, Namespace: namespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: id},
+	}
+	log.Printf("Waiting to acquire lease %s/%s as %s", namespace, config.lease, id)
+	leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("Acquired lease %s/%s", namespace, config.lease)
+				run(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				log.Fatalf("Lost lease %s/%s", namespace, config.lease)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					log.Printf("%s is the leader", identity)
+				}
+			},
+		},
+	})
+}
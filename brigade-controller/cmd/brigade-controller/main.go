@@ -4,11 +4,20 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/brigadecore/brigade/brigade-controller/cmd/brigade-controller/controller"
+	"github.com/brigadecore/brigade/pkg/metrics"
+	"github.com/brigadecore/brigade/pkg/storage/history"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -18,14 +27,21 @@ func init() {
 
 func main() {
 	var (
-		kubeconfig string
-		master     string
-		ctrConfig  controller.Config
+		kubeconfig  string
+		master      string
+		ctrConfig   controller.Config
+		historyDB   historyConfig
+		election    leaderElectionConfig
+		threadiness int
+		nsConfig    namespaces.Config
+		metricsAddr string
+		traceConfig tracing.Config
 	)
 
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	flag.StringVar(&master, "master", "", "master url")
 	flag.StringVar(&ctrConfig.Namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
 	flag.StringVar(&ctrConfig.WorkerImage, "worker-image", defaultWorkerImage(), "kubernetes worker image")
 	flag.StringVar(&ctrConfig.WorkerCommand, "worker-command", defaultWorkerCommand(), "kubernetes worker command")
 	flag.StringVar(&ctrConfig.WorkerPullPolicy, "worker-pull-policy", defaultWorkerPullPolicy(), "kubernetes worker image pull policy")
@@ -38,8 +54,27 @@ func main() {
 	flag.StringVar(&ctrConfig.WorkerLimitsMemory, "worker-limits-memory", "", "kubernetes worker memory limits")
 	flag.StringVar(&ctrConfig.DefaultBuildStorageClass, "default-build-storage-class", defaultBuildStorageClass(), "default storage class to use for shared build storage")
 	flag.StringVar(&ctrConfig.DefaultCacheStorageClass, "default-cache-storage-class", defaultCacheStorageClass(), "default storage class to use for caching jobs")
+	flag.DurationVar(&ctrConfig.BuildTimeout, "build-timeout", defaultBuildTimeout(), "maximum duration of builds of projects that do not set their own, if not given builds do not time out")
+	flag.IntVar(&ctrConfig.MaxConcurrentBuilds, "max-concurrent-builds", defaultMaxConcurrentBuilds(), "maximum number of builds of all projects that run at the same time, if not given there is no limit")
+	flag.IntVar(&threadiness, "threadiness", defaultThreadiness(), "number of builds that are synced at the same time")
+	flag.BoolVar(&election.enabled, "leader-elect", defaultLeaderElect(), "elect a leader among the replicas of the controller, so that only one of them starts workers at a time")
+	flag.StringVar(&election.lease, "leader-elect-lease", defaultLeaderElectLease(), "name of the lease used for leader election, in the controller's namespace")
+	flag.StringVar(&metricsAddr, "metrics-address", defaultMetricsAddress(), "address to serve prometheus metrics on, if empty metrics are not served")
+	traceConfig.AddFlags(flag.CommandLine)
+	flag.StringVar(&historyDB.driver, "history-db-driver", defaultHistoryDBDriver(), "database/sql driver of the build history database: postgres or sqlite")
+	flag.StringVar(&historyDB.dsn, "history-db-dsn", os.Getenv("BRIGADE_HISTORY_DB_DSN"), "data source name of the build history database, if not given no history is recorded")
 	flag.Parse()
 
+	if threadiness < 1 {
+		log.Fatalf("--threadiness must be at least 1, got %d", threadiness)
+	}
+
+	stopTracing, err := tracing.Start("brigade-controller", traceConfig)
+	if err != nil {
+		log.Fatalf("error configuring tracing (%s)", err)
+	}
+	defer stopTracing()
+
 	if ctrConfig.ProjectServiceAccountRegex == "" {
 		// No regex was given so only allow the default project service account
 		ctrConfig.ProjectServiceAccountRegex = ctrConfig.ProjectServiceAccount
@@ -57,16 +92,122 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if ctrConfig.Namespaces, err = namespaces.NewSet(clientset, ctrConfig.Namespace, nsConfig); err != nil {
+		log.Fatal(err)
+	}
+
 	controller := controller.NewController(clientset, &ctrConfig)
-	log.Printf("Listening in namespace %q for new events", ctrConfig.Namespace)
+	if ctrConfig.Namespaces.Watch() == ctrConfig.Namespace {
+		log.Printf("Listening in namespace %q for new events", ctrConfig.Namespace)
+	} else {
+		log.Printf("Listening in namespace %q and the namespaces of projects for new events", ctrConfig.Namespace)
+	}
 
-	// Now let's start the controller
-	stop := make(chan struct{})
-	defer close(stop)
-	go controller.Run(1, stop)
+	if metricsAddr != "" {
+		go func() {
+			log.Fatal(metrics.ListenAndServe(metricsAddr))
+		}()
+		log.Printf("Serving metrics on %s%s", metricsAddr, metrics.Path)
+	}
+
+	runAsLeader(clientset, ctrConfig.Namespace, election, func(stop <-chan struct{}) {
+		// Now let's start the controller
+		go controller.Run(threadiness, stop)
+
+		handlers := []cache.ResourceEventHandler{}
+		if metricsAddr != "" {
+			// Build durations are only observed by the leader, so that replicas
+			// do not export them twice.
+			handlers = append(handlers, metrics.NewBuildDurationHandler())
+		}
+		if historyDB.dsn != "" {
+			db, err := history.Open(historyDB.driver, historyDB.dsn)
+			if err != nil {
+				log.Fatalf("error opening build history database (%s)", err)
+			}
+			defer db.Close()
+			handlers = append(handlers, kube.NewBuildRecordHandler(db.Record))
+			log.Printf("Recording build history to %s database", historyDB.driver)
+		}
+		if len(handlers) > 0 {
+			// The cache is only kept for its informers, which replay every build,
+			// worker and job into the handlers before following their changes.
+			apicache.NewForNamespaces(clientset, ctrConfig.Namespaces, kube.DefaultCacheResyncPeriod, handlers...)
+		}
+
+		<-stop
+	})
+}
 
-	// Wait forever
-	select {}
+// historyConfig configures the build history database.
+type historyConfig struct {
+	driver string
+	dsn    string
+}
+
+func defaultHistoryDBDriver() string {
+	if driver, ok := os.LookupEnv("BRIGADE_HISTORY_DB_DRIVER"); ok {
+		return driver
+	}
+	return history.DefaultDriver
+}
+
+func defaultBuildTimeout() time.Duration {
+	if timeout, ok := os.LookupEnv("BRIGADE_BUILD_TIMEOUT"); ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("invalid BRIGADE_BUILD_TIMEOUT %q: %s", timeout, err)
+		}
+		return d
+	}
+	return 0
+}
+
+func defaultMaxConcurrentBuilds() int {
+	if max, ok := os.LookupEnv("BRIGADE_MAX_CONCURRENT_BUILDS"); ok {
+		n, err := strconv.Atoi(max)
+		if err != nil {
+			log.Fatalf("invalid BRIGADE_MAX_CONCURRENT_BUILDS %q: %s", max, err)
+		}
+		return n
+	}
+	return 0
+}
+
+func defaultThreadiness() int {
+	if n, ok := os.LookupEnv("BRIGADE_CONTROLLER_THREADINESS"); ok {
+		threadiness, err := strconv.Atoi(n)
+		if err != nil || threadiness < 1 {
+			log.Fatalf("invalid BRIGADE_CONTROLLER_THREADINESS %q", n)
+		}
+		return threadiness
+	}
+	return 1
+}
+
+func defaultLeaderElect() bool {
+	if elect, ok := os.LookupEnv("BRIGADE_LEADER_ELECT"); ok {
+		b, err := strconv.ParseBool(elect)
+		if err != nil {
+			log.Fatalf("invalid BRIGADE_LEADER_ELECT %q: %s", elect, err)
+		}
+		return b
+	}
+	return false
+}
+
+func defaultLeaderElectLease() string {
+	if lease, ok := os.LookupEnv("BRIGADE_LEADER_ELECT_LEASE"); ok {
+		return lease
+	}
+	return "brigade-controller"
+}
+
+func defaultMetricsAddress() string {
+	if addr, ok := os.LookupEnv("BRIGADE_METRICS_ADDRESS"); ok {
+		return addr
+	}
+	return ":9090"
 }
 
 func defaultWorkerImage() string {
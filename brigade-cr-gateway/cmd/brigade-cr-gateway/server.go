@@ -10,21 +10,29 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 
+	"github.com/brigadecore/brigade/pkg/metrics"
 	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
 	"github.com/brigadecore/brigade/pkg/webhook"
 )
 
 var (
-	kubeconfig string
-	master     string
-	namespace  string
+	kubeconfig  string
+	master      string
+	namespace   string
+	nsConfig    namespaces.Config
+	traceConfig tracing.Config
 )
 
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	flag.StringVar(&master, "master", "", "master url")
 	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
+	traceConfig.AddFlags(flag.CommandLine)
 }
 
 func main() {
@@ -35,11 +43,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	stopTracing, err := tracing.Start("brigade-cr-gateway", traceConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
 	if namespace == "" {
 		namespace = v1.NamespaceDefault
 	}
 
-	store := kube.New(clientset, namespace)
+	nsSet, err := namespaces.NewSet(clientset, namespace, nsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := kube.NewForNamespaces(clientset, nsSet, apicache.NewForNamespaces(clientset, nsSet, kube.DefaultCacheResyncPeriod))
 
 	router := newRouter(store)
 	router.Run(":8000")
@@ -72,6 +90,7 @@ func newRouter(store storage.Store) *gin.Engine {
 	}
 
 	router.GET("/healthz", healthz)
+	router.GET(metrics.Path, gin.WrapH(metrics.Handler()))
 
 	return router
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/brigadecore/brigade/brigade-cron-gateway/cmd/brigade-cron-gateway/scheduler"
+	"github.com/brigadecore/brigade/pkg/metrics"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
+)
+
+func init() {
+	log.SetFlags(log.Lshortfile)
+}
+
+func main() {
+	var (
+		kubeconfig  string
+		master      string
+		namespace   string
+		state       string
+		interval    time.Duration
+		nsConfig    namespaces.Config
+		metricsAddr string
+		traceConfig tracing.Config
+	)
+
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
+	flag.StringVar(&master, "master", "", "master url")
+	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
+	flag.StringVar(&state, "state-configmap", defaultStateConfigMap(), "name of the configmap that holds the times that schedules were last due, in the gateway's namespace")
+	flag.DurationVar(&interval, "interval", 15*time.Second, "how often schedules are checked")
+	flag.StringVar(&metricsAddr, "metrics-address", defaultMetricsAddress(), "address to serve prometheus metrics on, if empty metrics are not served")
+	traceConfig.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	if interval <= 0 {
+		log.Fatalf("--interval must be positive, got %s", interval)
+	}
+
+	stopTracing, err := tracing.Start("brigade-cron-gateway", traceConfig)
+	if err != nil {
+		log.Fatalf("error configuring tracing (%s)", err)
+	}
+	defer stopTracing()
+
+	clientset, err := kube.GetClient(master, kubeconfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	nsSet, err := namespaces.NewSet(clientset, namespace, nsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := kube.NewForNamespaces(clientset, nsSet, apicache.NewForNamespaces(clientset, nsSet, kube.DefaultCacheResyncPeriod))
+
+	if metricsAddr != "" {
+		go func() {
+			log.Fatal(metrics.ListenAndServe(metricsAddr))
+		}()
+		log.Printf("Serving metrics on %s%s", metricsAddr, metrics.Path)
+	}
+
+	log.Printf("Checking the schedules of projects every %s", interval)
+	stop := make(chan struct{})
+	defer close(stop)
+	scheduler.New(store, clientset, namespace, state).Run(interval, stop)
+}
+
+func defaultNamespace() string {
+	if ns, ok := os.LookupEnv("BRIGADE_NAMESPACE"); ok {
+		return ns
+	}
+	return v1.NamespaceDefault
+}
+
+func defaultStateConfigMap() string {
+	if name, ok := os.LookupEnv("BRIGADE_CRON_STATE_CONFIGMAP"); ok {
+		return name
+	}
+	return "brigade-cron-gateway"
+}
+
+func defaultMetricsAddress() string {
+	if addr, ok := os.LookupEnv("BRIGADE_METRICS_ADDRESS"); ok {
+		return addr
+	}
+	return ":9090"
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The reasons that due builds of schedules are skipped for.
+const (
+	reasonInvalidSchedule  = "invalid_schedule"
+	reasonDeadlineExceeded = "deadline_exceeded"
+	reasonConcurrency      = "concurrency_forbidden"
+)
+
+var (
+	buildsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "cron",
+		Name:      "builds_created_total",
+		Help:      "Number of builds created by schedules, by project.",
+	}, []string{"project"})
+	buildsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "cron",
+		Name:      "builds_skipped_total",
+		Help:      "Number of due builds of schedules that were not created, by project and reason.",
+	}, []string{"project", "reason"})
+	runsMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "cron",
+		Name:      "runs_missed_total",
+		Help:      "Number of times schedules were due while the gateway was not running, which were not caught up, by project.",
+	}, []string{"project"})
+)
+
+func init() {
+	prometheus.MustRegister(buildsCreated, buildsSkipped, runsMissed)
+}
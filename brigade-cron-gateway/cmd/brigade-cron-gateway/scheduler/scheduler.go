@@ -0,0 +1,284 @@
+// Package scheduler creates the builds of the cron schedules of projects.
+//
+// The time that each schedule was last due is kept in a ConfigMap, so that the
+// scheduler can catch up on the builds it missed while it was not running.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/tracing"
+)
+
+// DefaultEventType is the event type of the builds of schedules that do not
+// set one.
+const DefaultEventType = "cron"
+
+// Scheduler creates the builds of the schedules of all projects when they are
+// due.
+type Scheduler struct {
+	store     storage.Store
+	client    kubernetes.Interface
+	namespace string
+	// state is the name of the ConfigMap that holds the times that schedules
+	// were last due.
+	state string
+}
+
+// New creates a new Scheduler, which keeps its state in the ConfigMap state of
+// namespace.
+func New(store storage.Store, client kubernetes.Interface, namespace, state string) *Scheduler {
+	return &Scheduler{
+		store:     store,
+		client:    client,
+		namespace: namespace,
+		state:     state,
+	}
+}
+
+// Run checks the schedules every interval until stop is closed.
+func (s *Scheduler) Run(interval time.Duration, stop <-chan struct{}) {
+	wait.Until(func() {
+		if err := s.Tick(time.Now()); err != nil {
+			log.Printf("error checking schedules: %s", err)
+		}
+	}, interval, stop)
+}
+
+// Tick creates the builds of the schedules that were due since they were last
+// checked, up to now.
+//
+// Schedules that are new are not due before their first time after now. If a
+// schedule was due more than once, because the scheduler was not running, a
+// single build is created for the latest of those times, unless it is later
+// than the starting deadline of the schedule.
+//
+// The time a schedule was due is saved before its build is created, so that a
+// scheduler which stops in between does not create the build again.
+func (s *Scheduler) Tick(now time.Time) error {
+	now = now.UTC()
+	state, exists, err := s.getState()
+	if err != nil {
+		return err
+	}
+	projects, err := s.store.GetProjects()
+	if err != nil {
+		return err
+	}
+
+	next := make(map[string]string, len(state.Data))
+	for _, proj := range projects {
+		for _, sched := range proj.Schedules {
+			key := proj.ID + "." + sched.Name
+			last, err := time.Parse(time.RFC3339, state.Data[key])
+			if err != nil {
+				next[key] = now.Format(time.RFC3339)
+				continue
+			}
+			next[key] = state.Data[key]
+
+			if err := sched.Validate(); err != nil {
+				log.Printf("skipping schedule %s of project %s: %s", sched.Name, proj.ID, err)
+				buildsSkipped.WithLabelValues(proj.ID, reasonInvalidSchedule).Inc()
+				continue
+			}
+			due, missed := dueTime(sched, last, now)
+			if due.IsZero() {
+				continue
+			}
+			if missed > 0 {
+				log.Printf("schedule %s of project %s missed %d times before %s", sched.Name, proj.ID, missed, due.Format(time.RFC3339))
+				runsMissed.WithLabelValues(proj.ID).Add(float64(missed))
+			}
+			if state.Data == nil {
+				state.Data = map[string]string{}
+			}
+			state.Data[key] = due.Format(time.RFC3339)
+			if state, err = s.saveState(state, exists); err != nil {
+				return err
+			}
+			exists = true
+			if err := s.schedule(proj, sched, due, now); err != nil {
+				// The build is created again when the schedule is next checked.
+				log.Printf("error creating build of schedule %s of project %s: %s", sched.Name, proj.ID, err)
+				state.Data[key] = next[key]
+				continue
+			}
+			next[key] = state.Data[key]
+		}
+	}
+
+	state.Data = next
+	_, err = s.saveState(state, exists)
+	return err
+}
+
+// dueTime returns the latest time that a schedule was due after last, up to
+// now, and the number of times before it that the schedule was due. The time
+// is zero if the schedule was not due.
+func dueTime(sched brigade.Schedule, last, now time.Time) (time.Time, int) {
+	// The schedule is valid.
+	spec, _ := sched.Spec()
+	var due time.Time
+	missed := -1
+	for t := spec.Next(last); !t.IsZero() && !t.After(now); t = spec.Next(t) {
+		due = t
+		missed++
+	}
+	if missed < 0 {
+		missed = 0
+	}
+	return due, missed
+}
+
+// schedule creates the build of a schedule that was due at a time, according
+// to its starting deadline and concurrency policy.
+func (s *Scheduler) schedule(proj *brigade.Project, sched brigade.Schedule, due, now time.Time) error {
+	if sched.StartingDeadline != "" {
+		// The schedule is valid.
+		deadline, _ := time.ParseDuration(sched.StartingDeadline)
+		if now.Sub(due) > deadline {
+			log.Printf("skipping build of schedule %s of project %s due at %s, its starting deadline of %s has passed", sched.Name, proj.ID, due.Format(time.RFC3339), sched.StartingDeadline)
+			buildsSkipped.WithLabelValues(proj.ID, reasonDeadlineExceeded).Inc()
+			return nil
+		}
+	}
+
+	if sched.ConcurrencyPolicy == brigade.ConcurrencyForbid || sched.ConcurrencyPolicy == brigade.ConcurrencyReplace {
+		active, err := s.activeBuilds(proj, sched)
+		if err != nil {
+			return err
+		}
+		if len(active) > 0 && sched.ConcurrencyPolicy == brigade.ConcurrencyForbid {
+			log.Printf("skipping build of schedule %s of project %s due at %s, build %s has not finished", sched.Name, proj.ID, due.Format(time.RFC3339), active[0].ID)
+			buildsSkipped.WithLabelValues(proj.ID, reasonConcurrency).Inc()
+			return nil
+		}
+		for _, b := range active {
+			if err := s.store.CancelBuild(b.ID); err != nil && err != storage.ErrBuildFinished {
+				return fmt.Errorf("error cancelling build %s: %s", b.ID, err)
+			}
+			log.Printf("cancelled build %s of schedule %s of project %s", b.ID, sched.Name, proj.ID)
+		}
+	}
+
+	eventType := sched.EventType
+	if eventType == "" {
+		eventType = DefaultEventType
+	}
+	ref := sched.Ref
+	if ref == "" {
+		ref = "master"
+	}
+	b := &brigade.Build{
+		ProjectID:  proj.ID,
+		Type:       eventType,
+		Provider:   brigade.ScheduleProvider,
+		ShortTitle: sched.Name,
+		LongTitle:  fmt.Sprintf("Schedule %s (%s) due at %s", sched.Name, sched.Cron, due.Format(time.RFC3339)),
+		Revision:   &brigade.Revision{Ref: ref},
+		Payload:    []byte(sched.Payload),
+	}
+	return s.createBuild(b)
+}
+
+// createBuild creates a build in the store, and counts it if it was created.
+// The build starts a new trace.
+func (s *Scheduler) createBuild(b *brigade.Build) error {
+	ctx, span := tracing.Tracer().Start(context.Background(), "schedule build", trace.WithAttributes(
+		label.String("brigade.project", b.ProjectID),
+		label.String("brigade.event_type", b.Type),
+		label.String("brigade.provider", b.Provider),
+		label.String("brigade.schedule", b.ShortTitle),
+	))
+	defer span.End()
+	b.Traceparent = tracing.Traceparent(ctx)
+	if err := s.store.CreateBuild(b); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(label.String("brigade.build", b.ID))
+	buildsCreated.WithLabelValues(b.ProjectID).Inc()
+	log.Printf("created build %s of schedule %s of project %s", b.ID, b.ShortTitle, b.ProjectID)
+	return nil
+}
+
+// activeBuilds returns the builds of a schedule that have not finished.
+func (s *Scheduler) activeBuilds(proj *brigade.Project, sched brigade.Schedule) ([]*brigade.Build, error) {
+	builds, err := s.store.GetProjectBuilds(proj)
+	if err != nil {
+		return nil, err
+	}
+	active := []*brigade.Build{}
+	for _, b := range builds {
+		if b.Provider != brigade.ScheduleProvider || b.ShortTitle != sched.Name {
+			continue
+		}
+		if b.Worker == nil {
+			if !started(b) {
+				active = append(active, b)
+			}
+			continue
+		}
+		switch b.Worker.Status {
+		case brigade.JobPending, brigade.JobQueued, brigade.JobRunning:
+			active = append(active, b)
+		}
+	}
+	return active, nil
+}
+
+// started returns true if the controller created the worker of a build, or
+// failed to. Builds have no worker until the controller has accepted them, and
+// once their worker was deleted.
+func started(b *brigade.Build) bool {
+	for _, c := range b.Conditions {
+		if (c.Type == brigade.BuildWorkerCreated || c.Type == brigade.BuildFailed) && c.Status == brigade.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// getState returns the ConfigMap that holds the times that schedules were last
+// due, or a new one if it does not exist yet, and whether it exists.
+func (s *Scheduler) getState() (*v1.ConfigMap, bool, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(context.TODO(), s.state, meta.GetOptions{})
+	if errors.IsNotFound(err) {
+		return &v1.ConfigMap{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      s.state,
+				Namespace: s.namespace,
+				Labels: map[string]string{
+					"heritage":  "brigade",
+					"component": "cron-gateway",
+				},
+			},
+		}, false, nil
+	}
+	return cm, err == nil, err
+}
+
+// saveState creates or updates the ConfigMap that holds the times that
+// schedules were last due, and returns the saved ConfigMap.
+func (s *Scheduler) saveState(cm *v1.ConfigMap, exists bool) (*v1.ConfigMap, error) {
+	if !exists {
+		return s.client.CoreV1().ConfigMaps(s.namespace).Create(context.TODO(), cm, meta.CreateOptions{})
+	}
+	return s.client.CoreV1().ConfigMaps(s.namespace).Update(context.TODO(), cm, meta.UpdateOptions{})
+}
@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+// cancelStore records the builds that are cancelled.
+type cancelStore struct {
+	*mock.Store
+	cancelled []string
+	// onCreate is called with the builds that are created.
+	onCreate func(*brigade.Build)
+}
+
+func (s *cancelStore) CancelBuild(bid string) error {
+	s.cancelled = append(s.cancelled, bid)
+	return nil
+}
+
+func (s *cancelStore) CreateBuild(b *brigade.Build) error {
+	if s.onCreate != nil {
+		s.onCreate(b)
+	}
+	return s.Store.CreateBuild(b)
+}
+
+func newTestScheduler(schedules ...brigade.Schedule) (*Scheduler, *cancelStore) {
+	store := &cancelStore{Store: &mock.Store{
+		ProjectList: []*brigade.Project{{ID: "project1", Schedules: schedules}},
+	}}
+	return New(store, fake.NewSimpleClientset(), v1.NamespaceDefault, "brigade-cron-gateway"), store
+}
+
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func getState(t *testing.T, s *Scheduler) map[string]string {
+	t.Helper()
+	cm, err := s.client.CoreV1().ConfigMaps(v1.NamespaceDefault).Get(context.TODO(), "brigade-cron-gateway", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cm.Data
+}
+
+func TestTick(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{
+		Name:      "nightly",
+		Cron:      "0 3 * * *",
+		EventType: "nightly",
+		Payload:   `{"full": true}`,
+		Ref:       "refs/heads/main",
+	})
+
+	// New schedules are not due before their first time after now.
+	if err := s.Tick(mustParse(t, "2021-01-01T12:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 0 {
+		t.Fatalf("expected no builds, got %d", len(store.Builds))
+	}
+	if err := s.Tick(mustParse(t, "2021-01-02T02:59:59Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 0 {
+		t.Fatalf("expected no builds, got %d", len(store.Builds))
+	}
+
+	if err := s.Tick(mustParse(t, "2021-01-02T03:00:10Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(store.Builds))
+	}
+	b := store.Builds[0]
+	if b.ProjectID != "project1" {
+		t.Errorf("expected project project1, got %s", b.ProjectID)
+	}
+	if b.Provider != "cron" {
+		t.Errorf("expected provider cron, got %s", b.Provider)
+	}
+	if b.Type != "nightly" {
+		t.Errorf("expected event type nightly, got %s", b.Type)
+	}
+	if b.ShortTitle != "nightly" {
+		t.Errorf("expected short title nightly, got %s", b.ShortTitle)
+	}
+	if b.Revision == nil || b.Revision.Ref != "refs/heads/main" {
+		t.Errorf("expected ref refs/heads/main, got %v", b.Revision)
+	}
+	if string(b.Payload) != `{"full": true}` {
+		t.Errorf("unexpected payload %q", b.Payload)
+	}
+	if b.Traceparent == "" {
+		t.Error("expected build to have a traceparent")
+	}
+	if got := getState(t, s)["project1.nightly"]; got != "2021-01-02T03:00:00Z" {
+		t.Errorf("expected schedule to be last due at 2021-01-02T03:00:00Z, got %q", got)
+	}
+
+	// The schedule is not due again on the same day.
+	if err := s.Tick(mustParse(t, "2021-01-02T03:00:25Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(store.Builds))
+	}
+}
+
+func TestTick_Defaults(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+	if err := s.Tick(mustParse(t, "2021-01-01T13:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(store.Builds))
+	}
+	if b := store.Builds[0]; b.Type != "cron" || b.Revision.Ref != "master" {
+		t.Errorf("expected event type cron and ref master, got %s and %s", b.Type, b.Revision.Ref)
+	}
+}
+
+func TestTick_MissedRuns(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "0 * * * *"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+
+	// The gateway was not running for five hours.
+	if err := s.Tick(mustParse(t, "2021-01-01T17:30:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected a single build for the missed runs, got %d", len(store.Builds))
+	}
+	if expected := "Schedule hourly (0 * * * *) due at 2021-01-01T17:00:00Z"; store.Builds[0].LongTitle != expected {
+		t.Errorf("expected long title %q, got %q", expected, store.Builds[0].LongTitle)
+	}
+}
+
+func TestTick_StartingDeadline(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "0 * * * *", StartingDeadline: "10m"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+
+	if err := s.Tick(mustParse(t, "2021-01-01T13:15:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 0 {
+		t.Fatalf("expected the late build to be skipped, got %d builds", len(store.Builds))
+	}
+	if got := getState(t, s)["project1.hourly"]; got != "2021-01-01T13:00:00Z" {
+		t.Errorf("expected the skipped run to be recorded, got %q", got)
+	}
+
+	if err := s.Tick(mustParse(t, "2021-01-01T14:05:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(store.Builds))
+	}
+}
+
+func TestTick_ConcurrencyPolicy(t *testing.T) {
+	running := func() *brigade.Build {
+		return &brigade.Build{
+			ID:         "build1",
+			ProjectID:  "project1",
+			Provider:   "cron",
+			ShortTitle: "hourly",
+			Worker:     &brigade.Worker{Status: brigade.JobRunning},
+		}
+	}
+	tests := []struct {
+		policy    brigade.ConcurrencyPolicy
+		builds    int
+		cancelled int
+	}{
+		{brigade.ConcurrencyAllow, 2, 0},
+		{brigade.ConcurrencyForbid, 1, 0},
+		{brigade.ConcurrencyReplace, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly", ConcurrencyPolicy: tt.policy})
+			s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+			store.Builds = []*brigade.Build{running()}
+
+			if err := s.Tick(mustParse(t, "2021-01-01T13:00:00Z")); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.Builds) != tt.builds {
+				t.Errorf("expected %d builds, got %d", tt.builds, len(store.Builds))
+			}
+			if len(store.cancelled) != tt.cancelled {
+				t.Errorf("expected %d cancelled builds, got %v", tt.cancelled, store.cancelled)
+			}
+		})
+	}
+}
+
+func TestTick_FinishedBuildsAreNotActive(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly", ConcurrencyPolicy: brigade.ConcurrencyForbid})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+	store.Builds = []*brigade.Build{{
+		ID:         "build1",
+		Provider:   "cron",
+		ShortTitle: "hourly",
+		Worker:     &brigade.Worker{Status: brigade.JobSucceeded},
+	}}
+
+	if err := s.Tick(mustParse(t, "2021-01-01T13:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 2 {
+		t.Errorf("expected 2 builds, got %d", len(store.Builds))
+	}
+}
+
+func TestTick_StateSavedBeforeBuild(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+
+	var saved string
+	store.onCreate = func(*brigade.Build) { saved = getState(t, s)["project1.hourly"] }
+	if err := s.Tick(mustParse(t, "2021-01-01T13:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if saved != "2021-01-01T13:00:00Z" {
+		t.Errorf("expected the due time to be saved before the build is created, got %q", saved)
+	}
+}
+
+func TestTick_UnstartedBuilds(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []brigade.BuildCondition
+		builds     int
+	}{
+		{"not accepted", nil, 1},
+		{"worker deleted", []brigade.BuildCondition{{Type: brigade.BuildWorkerCreated, Status: brigade.ConditionTrue}}, 2},
+		{"failed", []brigade.BuildCondition{{Type: brigade.BuildFailed, Status: brigade.ConditionTrue}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly", ConcurrencyPolicy: brigade.ConcurrencyForbid})
+			s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+			store.Builds = []*brigade.Build{{ID: "build1", Provider: "cron", ShortTitle: "hourly", Conditions: tt.conditions}}
+
+			if err := s.Tick(mustParse(t, "2021-01-01T13:00:00Z")); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.Builds) != tt.builds {
+				t.Errorf("expected %d builds, got %d", tt.builds, len(store.Builds))
+			}
+		})
+	}
+}
+
+func TestTick_RemovedSchedules(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "hourly", Cron: "@hourly"}, brigade.Schedule{Name: "daily", Cron: "@daily"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+	if state := getState(t, s); len(state) != 2 {
+		t.Fatalf("expected state of 2 schedules, got %v", state)
+	}
+
+	store.ProjectList[0].Schedules = store.ProjectList[0].Schedules[:1]
+	s.Tick(mustParse(t, "2021-01-01T12:31:00Z"))
+	if state := getState(t, s); len(state) != 1 || state["project1.hourly"] == "" {
+		t.Errorf("expected state of schedule hourly only, got %v", state)
+	}
+}
+
+func TestTick_InvalidSchedule(t *testing.T) {
+	s, store := newTestScheduler(brigade.Schedule{Name: "broken", Cron: "not a cron expression"})
+	s.Tick(mustParse(t, "2021-01-01T12:30:00Z"))
+	if err := s.Tick(mustParse(t, "2021-01-02T12:30:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Builds) != 0 {
+		t.Errorf("expected no builds, got %d", len(store.Builds))
+	}
+}
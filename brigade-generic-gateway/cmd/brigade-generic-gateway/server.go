@@ -10,21 +10,29 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 
+	"github.com/brigadecore/brigade/pkg/metrics"
 	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
 	"github.com/brigadecore/brigade/pkg/webhook"
 )
 
 var (
-	kubeconfig string
-	master     string
-	namespace  string
+	kubeconfig  string
+	master      string
+	namespace   string
+	nsConfig    namespaces.Config
+	traceConfig tracing.Config
 )
 
 func init() {
 	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
 	flag.StringVar(&master, "master", "", "master url")
 	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
+	traceConfig.AddFlags(flag.CommandLine)
 }
 
 func main() {
@@ -35,11 +43,21 @@ func main() {
 		log.Fatal(err)
 	}
 
+	stopTracing, err := tracing.Start("brigade-generic-gateway", traceConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
 	if namespace == "" {
 		namespace = v1.NamespaceDefault
 	}
 
-	store := kube.New(clientset, namespace)
+	nsSet, err := namespaces.NewSet(clientset, namespace, nsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := kube.NewForNamespaces(clientset, nsSet, apicache.NewForNamespaces(clientset, nsSet, kube.DefaultCacheResyncPeriod))
 
 	router := newRouter(store)
 	router.Run(":8000")
@@ -60,7 +78,25 @@ func newRouter(store storage.Store) *gin.Engine {
 		events.POST("/:projectID/:secret", handler)
 	}
 
+	// GitLab and Bitbucket events name their project in the route, like the
+	// Docker Hub events of the brigade-cr-gateway, and are authenticated with
+	// the shared secret of the project.
+	vcsHandlers := map[string]gin.HandlerFunc{
+		"/events/gitlab":    webhook.NewGitlabHook(store),
+		"/events/bitbucket": webhook.NewBitbucketHook(store),
+	}
+
+	for endpoint, handler := range vcsHandlers {
+		events := router.Group(endpoint)
+		events.Use(gin.Logger())
+		// Of the form /events/gitlab/brigade-123456789
+		events.POST("/:org", handler)
+		// Of the form /events/gitlab/brigadecore/empty-testbed
+		events.POST("/:org/:repo", handler)
+	}
+
 	router.GET("/healthz", healthz)
+	router.GET(metrics.Path, gin.WrapH(metrics.Handler()))
 	return router
 }
 
@@ -75,4 +75,19 @@ func TestNewRouter(t *testing.T) {
 		}
 	}
 
+	// The project of GitLab and Bitbucket events is named in the route.
+	for _, route := range []string{
+		"/events/gitlab/brigade-4625a05cf6914e556aa254cb2af234203744de2f",
+		"/events/gitlab/brigadecore/empty-testbed",
+		"/events/bitbucket/brigade-4625a05cf6914e556aa254cb2af234203744de2f",
+		"/events/bitbucket/brigadecore/empty-testbed",
+	} {
+		res, err = http.Post(ts.URL+route, "application/json", bytes.NewBufferString("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode == http.StatusNotFound {
+			t.Errorf("Expected route %s to exist", route)
+		}
+	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/brigadecore/brigade/pkg/metrics"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+	"github.com/brigadecore/brigade/pkg/tracing"
+	"github.com/brigadecore/brigade/pkg/webhook"
+)
+
+var (
+	kubeconfig  string
+	master      string
+	namespace   string
+	nsConfig    namespaces.Config
+	traceConfig tracing.Config
+)
+
+func init() {
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
+	flag.StringVar(&master, "master", "", "master url")
+	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	nsConfig.AddFlags(flag.CommandLine)
+	traceConfig.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+
+	clientset, err := kube.GetClient(master, kubeconfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stopTracing, err := tracing.Start("brigade-github-gateway", traceConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopTracing()
+
+	if namespace == "" {
+		namespace = v1.NamespaceDefault
+	}
+
+	nsSet, err := namespaces.NewSet(clientset, namespace, nsConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store := kube.NewForNamespaces(clientset, nsSet, apicache.NewForNamespaces(clientset, nsSet, kube.DefaultCacheResyncPeriod))
+
+	router := newRouter(store)
+	router.Run(":7744")
+}
+
+func newRouter(store storage.Store) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	events := router.Group("/events")
+	{
+		events.Use(gin.Logger())
+		// The project of an event is the one named after the full name of its
+		// repository, such as brigadecore/empty-testbed.
+		events.POST("/github", webhook.NewGithubHook(store))
+	}
+
+	router.GET("/healthz", healthz)
+	router.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+
+	return router
+}
+
+func healthz(c *gin.Context) {
+	c.String(http.StatusOK, http.StatusText(http.StatusOK))
+}
+
+func defaultNamespace() string {
+	if ns, ok := os.LookupEnv("BRIGADE_NAMESPACE"); ok {
+		return ns
+	}
+	return v1.NamespaceDefault
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+	"github.com/brigadecore/brigade/pkg/webhook"
+)
+
+func TestNewRouter(t *testing.T) {
+	s := mock.New()
+	s.ProjectList[0].Name = "baxterthehacker/public-repo"
+	s.ProjectList[0].ID = brigade.ProjectID(s.ProjectList[0].Name)
+	r := newRouter(s)
+
+	if r == nil {
+		t.Fail()
+	}
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 {
+		t.Fatalf("Unexpected status on healthz: %s", res.Status)
+	}
+
+	body, err := ioutil.ReadFile("./testdata/github-push-payload.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature is checked against the shared secret of the project.
+	for signature, expected := range map[string]int{
+		webhook.SHA256HMAC([]byte(s.ProjectList[0].SharedSecret), body): http.StatusOK,
+		webhook.SHA256HMAC([]byte("not the shared secret"), body):       http.StatusForbidden,
+	} {
+		req, err := http.NewRequest("POST", ts.URL+"/events/github", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != expected {
+			t.Errorf("Expected status %d, got: %s", expected, res.Status)
+		}
+	}
+}
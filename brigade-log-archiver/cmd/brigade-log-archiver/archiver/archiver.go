@@ -0,0 +1,234 @@
+package archiver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/brigadecore/brigade/pkg/logarchive"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// ArchivedAnnotation is set on a pod once its logs have been archived.
+const ArchivedAnnotation = "brigade.sh/logs-archived"
+
+// Archiver watches worker and job pods and archives their logs once they have terminated.
+type Archiver struct {
+	namespace string
+	sink      logarchive.Sink
+	index     *logarchive.Index
+	indexer   cache.Indexer
+	queue     workqueue.RateLimitingInterface
+	informer  cache.Controller
+
+	clientset kubernetes.Interface
+	// openLogs opens the log of a pod. It is replaced in tests, as the fake
+	// clientset cannot stream logs.
+	openLogs func(pod *v1.Pod, opts *v1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// New creates a new Archiver.
+func New(clientset kubernetes.Interface, namespace string, sink logarchive.Sink) *Archiver {
+	a := &Archiver{
+		namespace: namespace,
+		sink:      sink,
+		index:     logarchive.NewIndex(sink),
+		clientset: clientset,
+		queue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	a.openLogs = a.streamPodLogs
+	a.createIndexerInformer()
+	return a
+}
+
+func (a *Archiver) createIndexerInformer() {
+	selector := "heritage=brigade,component in (build, job)"
+	enqueue := func(obj interface{}) {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || !needsArchiving(pod) {
+			return
+		}
+		if key, err := cache.MetaNamespaceKeyFunc(obj); err == nil {
+			a.queue.Add(key)
+		}
+	}
+	a.indexer, a.informer = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = selector
+				return a.clientset.CoreV1().Pods(a.namespace).List(context.TODO(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = selector
+				return a.clientset.CoreV1().Pods(a.namespace).Watch(context.TODO(), options)
+			},
+		},
+		&v1.Pod{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: enqueue,
+			UpdateFunc: func(_, newObj interface{}) {
+				enqueue(newObj)
+			},
+		},
+		cache.Indexers{},
+	)
+}
+
+// needsArchiving returns true if a pod has terminated and its logs have not been archived yet.
+func needsArchiving(pod *v1.Pod) bool {
+	if pod.Annotations[ArchivedAnnotation] == "true" {
+		return false
+	}
+	return pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+}
+
+func (a *Archiver) processNextItem() bool {
+	key, quit := a.queue.Get()
+	if quit {
+		return false
+	}
+	defer a.queue.Done(key)
+
+	err := a.sync(key.(string))
+	a.handleErr(err, key)
+	return true
+}
+
+// HasSynced returns true if the archiver has synced.
+func (a *Archiver) HasSynced() bool {
+	return a.informer.HasSynced()
+}
+
+func (a *Archiver) sync(key string) error {
+	obj, exists, err := a.indexer.GetByKey(key)
+	if err != nil {
+		log.Printf("Fetching object with key %s from store failed with %v", key, err)
+		return err
+	}
+	if !exists {
+		// Without its pod, the logs are gone already.
+		return nil
+	}
+	pod := obj.(*v1.Pod)
+	if !needsArchiving(pod) {
+		return nil
+	}
+	return a.archive(pod)
+}
+
+// archive copies the logs of every container of a pod into the sink, records
+// the worker or job of the pod in the index of the archive, and then marks the
+// pod as archived.
+func (a *Archiver) archive(pod *v1.Pod) error {
+	project, build := pod.Labels["project"], pod.Labels["build"]
+	containers := []string{""}
+	if pod.Labels["component"] == "build" {
+		for _, c := range pod.Spec.InitContainers {
+			if c.Name == storage.InitContainer {
+				containers = append(containers, c.Name)
+			}
+		}
+	}
+
+	for _, container := range containers {
+		key := logarchive.Key(project, build, pod.Name, container)
+		if err := a.archiveContainer(pod, container, key); err != nil {
+			return fmt.Errorf("archiving logs of pod %s to %s: %s", pod.Name, key, err)
+		}
+		log.Printf("Archived logs of pod %s to %s", pod.Name, key)
+	}
+	if err := a.indexPod(pod); err != nil {
+		return fmt.Errorf("indexing pod %s: %s", pod.Name, err)
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:"true"}}}`, ArchivedAnnotation)
+	_, err := a.clientset.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// indexPod records the worker or job of a pod, so that it can be found once
+// the pod is gone.
+func (a *Archiver) indexPod(pod *v1.Pod) error {
+	if pod.Labels["component"] == "build" {
+		return a.index.PutWorker(kube.NewWorkerFromPod(*pod))
+	}
+	return a.index.PutJob(kube.NewJobFromPod(*pod))
+}
+
+func (a *Archiver) archiveContainer(pod *v1.Pod, container, key string) error {
+	opts := &v1.PodLogOptions{Timestamps: true}
+	if container != "" {
+		opts.Container = container
+	} else if len(pod.Spec.Containers) > 0 {
+		opts.Container = pod.Spec.Containers[0].Name
+	}
+	r, err := a.openLogs(pod, opts)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return a.sink.Put(key, r)
+}
+
+func (a *Archiver) streamPodLogs(pod *v1.Pod, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+	return a.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(context.TODO())
+}
+
+// handleErr checks if an error happened and makes sure we will retry later.
+func (a *Archiver) handleErr(err error, key interface{}) {
+	if err == nil {
+		a.queue.Forget(key)
+		return
+	}
+
+	if a.queue.NumRequeues(key) < 5 {
+		log.Printf("Error archiving pod %v: %v", key, err)
+		a.queue.AddRateLimited(key)
+		return
+	}
+
+	a.queue.Forget(key)
+	utilruntime.HandleError(err)
+	log.Printf("Dropping pod %q out of the queue: %v", key, err)
+}
+
+// Run executes the archiver.
+func (a *Archiver) Run(threadiness int, stopCh chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer a.queue.ShutDown()
+	log.Print("Starting log archiver")
+
+	go a.informer.Run(stopCh)
+
+	if !cache.WaitForCacheSync(stopCh, a.HasSynced) {
+		utilruntime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
+		return
+	}
+
+	for i := 0; i < threadiness; i++ {
+		go wait.Until(a.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	log.Print("Stopping log archiver")
+}
+
+func (a *Archiver) runWorker() {
+	for a.processNextItem() {
+	}
+}
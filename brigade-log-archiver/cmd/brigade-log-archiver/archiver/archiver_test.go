@@ -0,0 +1,102 @@
+package archiver
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/logarchive"
+)
+
+type memorySink map[string]string
+
+func (s memorySink) Put(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	s[key] = string(data)
+	return err
+}
+
+func (s memorySink) Get(key string) (io.ReadCloser, error) {
+	log, ok := s[key]
+	if !ok {
+		return nil, logarchive.ErrNotFound
+	}
+	return ioutil.NopCloser(strings.NewReader(log)), nil
+}
+
+func TestArchiveWorkerPod(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "brigade-worker-build1",
+			Namespace: v1.NamespaceDefault,
+			Labels: map[string]string{
+				"heritage":  "brigade",
+				"component": "build",
+				"build":     "build1",
+				"project":   "project1",
+			},
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "vcs-sidecar"}},
+			Containers:     []v1.Container{{Name: "brigade-runner"}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning, StartTime: &metav1.Time{}},
+	}
+	client := fake.NewSimpleClientset(pod)
+	sink := memorySink{}
+	a := New(client, v1.NamespaceDefault, sink)
+	a.openLogs = func(pod *v1.Pod, opts *v1.PodLogOptions) (io.ReadCloser, error) {
+		if !opts.Timestamps {
+			t.Error("expected logs to be archived with timestamps")
+		}
+		return ioutil.NopCloser(strings.NewReader(opts.Container + " log")), nil
+	}
+
+	a.indexer.Add(pod)
+	if err := a.sync("default/brigade-worker-build1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink) != 0 {
+		t.Fatalf("expected the logs of a running pod not to be archived, got %v", sink)
+	}
+
+	pod.Status.Phase = v1.PodSucceeded
+	a.indexer.Update(pod)
+	if err := a.sync("default/brigade-worker-build1"); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"project1/build1/brigade-worker-build1.log":             "brigade-runner log",
+		"project1/build1/brigade-worker-build1.vcs-sidecar.log": "vcs-sidecar log",
+	}
+	for key, log := range expect {
+		if sink[key] != log {
+			t.Errorf("expected %q to be archived to %s, got %v", log, key, sink)
+		}
+	}
+
+	w, err := logarchive.NewIndex(sink).Worker("build1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ID != "brigade-worker-build1" || w.ProjectID != "project1" || w.Status != "Succeeded" {
+		t.Errorf("unexpected indexed worker %+v", w)
+	}
+
+	archived, err := client.CoreV1().Pods(v1.NamespaceDefault).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.Annotations[ArchivedAnnotation] != "true" {
+		t.Errorf("expected the pod to be marked as archived, got %v", archived.Annotations)
+	}
+	if needsArchiving(archived) {
+		t.Error("expected an archived pod not to be archived again")
+	}
+}
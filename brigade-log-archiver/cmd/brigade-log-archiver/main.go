@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/brigadecore/brigade/brigade-log-archiver/cmd/brigade-log-archiver/archiver"
+	"github.com/brigadecore/brigade/pkg/logarchive"
+)
+
+func init() {
+	log.SetFlags(log.Lshortfile)
+}
+
+func main() {
+	var (
+		kubeconfig  string
+		master      string
+		namespace   string
+		threadiness int
+		sinkConfig  logarchive.SinkConfig
+	)
+
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
+	flag.StringVar(&master, "master", "", "master url")
+	flag.StringVar(&namespace, "namespace", defaultNamespace(), "kubernetes namespace")
+	flag.IntVar(&threadiness, "threadiness", 2, "number of pods whose logs are archived concurrently")
+	sinkConfig.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	sink, err := logarchive.NewSink(sinkConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sink == nil {
+		log.Fatal("a log archive is required, set --log-archive to file or s3")
+	}
+
+	// creates the connection
+	config, err := clientcmd.BuildConfigFromFlags(master, kubeconfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// creates the clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := archiver.New(clientset, namespace, sink)
+	log.Printf("Archiving logs of pods in namespace %q to %s", namespace, sinkConfig.Type)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go a.Run(threadiness, stop)
+
+	// Wait forever
+	select {}
+}
+
+func defaultNamespace() string {
+	if ns, ok := os.LookupEnv("BRIGADE_NAMESPACE"); ok {
+		return ns
+	}
+	return v1.NamespaceDefault
+}
@@ -107,9 +107,14 @@ func (v *Vacuum) Run() error {
 
 func (v *Vacuum) deleteBuild(bid string) error {
 	store := kube.New(v.client, v.namespace)
-	return store.DeleteBuild(bid, storage.DeleteBuildOptions{
+	err := store.DeleteBuild(bid, storage.DeleteBuildOptions{
 		SkipRunningBuilds: v.skipRunningBuilds,
 	})
+	// Running builds are left for a later run.
+	if err == storage.ErrBuildRunning {
+		return nil
+	}
+	return err
 }
 
 // ByCreation sorts secrets by their creation timestamp.
@@ -344,6 +344,14 @@ export class JobRunner implements jobs.JobRunner {
       }
     }
 
+    // Jobs continue the trace of the build, unless they set their own.
+    if (process.env.TRACEPARENT && !("TRACEPARENT" in job.env)) {
+      envVars.push({
+        name: "TRACEPARENT",
+        value: process.env.TRACEPARENT
+      } as kubernetes.V1EnvVar);
+    }
+
     this.runner.spec.containers[0].env = envVars;
 
     let mountPath = job.mountPath || this.options.mountPath;
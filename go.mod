@@ -29,18 +29,23 @@ require (
 	github.com/gosuri/uitable v0.0.4
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/imdario/mergo v0.3.8 // indirect
-	github.com/json-iterator/go v1.1.9 // indirect
 	github.com/leodido/go-urn v1.2.0 // indirect
+	github.com/lib/pq v1.10.9
 	github.com/lucasb-eyer/go-colorful v1.0.3 // indirect
 	github.com/mailru/easyjson v0.7.1 // indirect
-	github.com/mattn/go-isatty v0.0.12 // indirect
 	github.com/mattn/go-runewidth v0.0.8 // indirect
 	github.com/oklog/ulid v1.3.1
+	github.com/prometheus/client_golang v1.5.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/rivo/tview v0.0.0-20180728193050-6614b16d9037
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/slok/brigadeterm v0.11.1
 	github.com/spf13/cobra v1.0.0
+	go.opentelemetry.io/otel v0.15.0
+	go.opentelemetry.io/otel/exporters/otlp v0.15.0
+	go.opentelemetry.io/otel/sdk v0.15.0
+	golang.org/x/net v0.0.0-20201021035429-f5854403a974
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
-	golang.org/x/sys v0.0.0-20200219091948-cb0a6d8edb6c // indirect
 	golang.org/x/time v0.0.0-20191024005414-555d28b269f0 // indirect
 	gopkg.in/AlecAivazis/survey.v1 v1.8.8
 	gopkg.in/gin-gonic/gin.v1 v1.1.5-0.20170702092826-d459835d2b07
@@ -50,4 +55,5 @@ require (
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v2.0.0-alpha.0.0.20181016174657-85ed251159e4+incompatible
 	k8s.io/kube-openapi v0.0.0-20200204173128-addea2498afe // indirect
+	modernc.org/sqlite v1.14.0
 )
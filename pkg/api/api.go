@@ -7,11 +7,28 @@ import (
 // API represents the rest api handlers.
 type API struct {
 	store storage.Store
+	authz Authorizer
+	// websocketOrigins are the origins of other sites whose pages may open
+	// WebSockets to the API.
+	websocketOrigins []string
 }
 
-// New creates a new api handler.
+// New creates a new api handler which allows access to every project.
 func New(s storage.Store) API {
-	return API{store: s}
+	return NewWithAuthorizer(s, AllowAll)
+}
+
+// NewWithAuthorizer creates a new api handler which checks project access with authz.
+func NewWithAuthorizer(s storage.Store, authz Authorizer) API {
+	return API{store: s, authz: authz}
+}
+
+// WithWebSocketOrigins returns a copy of the api handler whose WebSockets may
+// be opened by pages of the given origins, such as https://dashboard.example.com,
+// as well as by pages of the API server itself. The origin "*" allows any site.
+func (api API) WithWebSocketOrigins(origins []string) API {
+	api.websocketOrigins = origins
+	return api
 }
 
 // Project returns a handler for projects.
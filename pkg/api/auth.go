@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+// WebSocketTokenProtocolPrefix prefixes the base64url encoded bearer token a
+// WebSocket client sends as a subprotocol, as browsers cannot set the
+// Authorization header of a WebSocket handshake. Such clients must offer another
+// subprotocol as well, which the server selects.
+const WebSocketTokenProtocolPrefix = "base64url.bearer.authorization.brigade.sh."
+
+// userAttribute is the request attribute under which the authenticated user is stored.
+const userAttribute = "brigade.user"
+
+// ErrUnauthenticated indicates that a token could not be authenticated.
+var ErrUnauthenticated = errors.New("token could not be authenticated")
+
+// User is an authenticated caller of the API.
+type User struct {
+	// Name is the unique name of the user.
+	Name string `json:"name"`
+	// Groups are the groups the user is a member of.
+	Groups []string `json:"groups"`
+}
+
+// Verb is the kind of access requested on a project.
+type Verb string
+
+const (
+	// VerbRead allows reading a project, its builds, jobs and logs.
+	VerbRead Verb = "read"
+	// VerbWrite allows changing a project and creating or deleting its builds.
+	VerbWrite Verb = "write"
+)
+
+// Authenticator turns a bearer token into a User.
+type Authenticator interface {
+	// Authenticate returns the user owning the token, or ErrUnauthenticated.
+	Authenticate(token string) (*User, error)
+}
+
+// Authorizer decides whether a user may access a project.
+type Authorizer interface {
+	// Authorize returns true if the user may perform verb on the project.
+	// The user is nil when no authentication filter is installed.
+	Authorize(user *User, projectID string, verb Verb) bool
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as Authorizers.
+type AuthorizerFunc func(user *User, projectID string, verb Verb) bool
+
+// Authorize calls f(user, projectID, verb).
+func (f AuthorizerFunc) Authorize(user *User, projectID string, verb Verb) bool {
+	return f(user, projectID, verb)
+}
+
+// AllowAll is an Authorizer that grants every request.
+var AllowAll Authorizer = AuthorizerFunc(func(*User, string, Verb) bool { return true })
+
+// AuthFilter returns a filter that authenticates the bearer token of each request
+// and stores the resulting User on the request.
+func AuthFilter(authn Authenticator) restful.FilterFunction {
+	return func(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+		token := bearerToken(req.Request)
+		if token == "" {
+			resp.AddHeader("WWW-Authenticate", "Bearer")
+			resp.WriteErrorString(http.StatusUnauthorized, "Authorization required.")
+			return
+		}
+		user, err := authn.Authenticate(token)
+		if err != nil {
+			resp.AddHeader("WWW-Authenticate", "Bearer")
+			resp.WriteErrorString(http.StatusUnauthorized, "Invalid token.")
+			return
+		}
+		req.SetAttribute(userAttribute, user)
+		chain.ProcessFilter(req, resp)
+	}
+}
+
+// UserFromRequest returns the authenticated user of a request, or nil.
+func UserFromRequest(req *restful.Request) *User {
+	user, _ := req.Attribute(userAttribute).(*User)
+	return user
+}
+
+// bearerToken returns the bearer token of the Authorization header of a
+// request, or that of its subprotocols if it is a WebSocket handshake.
+func bearerToken(r *http.Request) string {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if auth == "" {
+		if !isWebsocketUpgrade(r) {
+			return ""
+		}
+		return websocketBearerToken(r)
+	}
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// isWebsocketUpgrade returns true if the request is a WebSocket handshake.
+func isWebsocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, h := range r.Header[http.CanonicalHeaderKey("Connection")] {
+		for _, token := range strings.Split(h, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func websocketBearerToken(r *http.Request) string {
+	for _, protocol := range websocketProtocols(r) {
+		if !strings.HasPrefix(protocol, WebSocketTokenProtocolPrefix) {
+			continue
+		}
+		token, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimPrefix(protocol, WebSocketTokenProtocolPrefix), "="))
+		if err != nil {
+			return ""
+		}
+		return string(token)
+	}
+	return ""
+}
+
+func websocketProtocols(r *http.Request) []string {
+	protocols := []string{}
+	for _, h := range r.Header[http.CanonicalHeaderKey("Sec-WebSocket-Protocol")] {
+		for _, p := range strings.Split(h, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+// selectWebsocketProtocol selects the first subprotocol offered by a WebSocket
+// client which does not carry a bearer token, so that the token is not echoed
+// back to the client.
+func selectWebsocketProtocol(protocols []string) []string {
+	for _, p := range protocols {
+		if !strings.HasPrefix(p, WebSocketTokenProtocolPrefix) {
+			return []string{p}
+		}
+	}
+	return nil
+}
+
+// authorized checks that the caller may perform verb on the project, and writes
+// a 403 response if it may not.
+func authorized(authz Authorizer, request *restful.Request, response *restful.Response, projectID string, verb Verb) bool {
+	if allowed(authz, UserFromRequest(request), projectID, verb) {
+		return true
+	}
+	response.WriteErrorString(http.StatusForbidden, "Access to the project is forbidden.")
+	return false
+}
+
+func allowed(authz Authorizer, user *User, projectID string, verb Verb) bool {
+	if authz == nil {
+		return true
+	}
+	return authz.Authorize(user, projectID, verb)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	authnv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+const testTokens = `# token,user,uid,groups
+abc123,alice,1,"team-a,devs"
+def456,bob,2
+`
+
+const testPolicy = `
+rules:
+- groups: ["team-a"]
+  projects: ["project-id"]
+  verbs: ["write"]
+- users: ["bob"]
+  projects: ["*"]
+  verbs: ["read"]
+`
+
+func TestTokenFileAuthenticator(t *testing.T) {
+	authn, err := newTokenFileAuthenticator(strings.NewReader(testTokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := authn.Authenticate("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" || len(user.Groups) != 2 || user.Groups[1] != "devs" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if _, err := authn.Authenticate("nope"); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+
+	if _, err := newTokenFileAuthenticator(strings.NewReader("lonelytoken\n")); err == nil {
+		t.Error("expected an error for a token without a user")
+	}
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authnv1.TokenReview)
+		if review.Spec.Token == "good" {
+			review.Status.Authenticated = true
+			review.Status.User = authnv1.UserInfo{Username: "system:serviceaccount:default:ci", Groups: []string{"system:serviceaccounts"}}
+		}
+		return true, review, nil
+	})
+
+	authn := NewTokenReviewAuthenticator(client)
+	user, err := authn.Authenticate("good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "system:serviceaccount:default:ci" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if _, err := authn.Authenticate("bad"); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestPolicyAuthorizer(t *testing.T) {
+	policy, err := newPolicyAuthorizer(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &User{Name: "alice", Groups: []string{"team-a"}}
+	bob := &User{Name: "bob"}
+
+	tests := []struct {
+		user    *User
+		project string
+		verb    Verb
+		allowed bool
+	}{
+		{alice, brigade.ProjectID("project-id"), VerbRead, true},
+		{alice, brigade.ProjectID("project-id"), VerbWrite, true},
+		{alice, "brigade-other", VerbRead, false},
+		{bob, "brigade-other", VerbRead, true},
+		{bob, "brigade-other", VerbWrite, false},
+		{nil, "brigade-other", VerbRead, false},
+	}
+	for _, tt := range tests {
+		if got := policy.Authorize(tt.user, tt.project, tt.verb); got != tt.allowed {
+			t.Errorf("Authorize(%v, %s, %s): expected %t, got %t", tt.user, tt.project, tt.verb, tt.allowed, got)
+		}
+	}
+}
+
+func TestAuthFilter(t *testing.T) {
+	authn, err := newTokenFileAuthenticator(strings.NewReader(testTokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen *User
+	ws := new(restful.WebService)
+	ws.Filter(AuthFilter(authn))
+	ws.Route(ws.GET("/").To(func(req *restful.Request, resp *restful.Response) {
+		seen = UserFromRequest(req)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	rw := httptest.NewRecorder()
+	container.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	if rw.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d without a token, got %d", http.StatusUnauthorized, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	container.ServeHTTP(rw, req)
+	if rw.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d with a bad token, got %d", http.StatusUnauthorized, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer def456")
+	container.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Errorf("expected %d with a good token, got %d", http.StatusOK, rw.Code)
+	}
+	if seen == nil || seen.Name != "bob" {
+		t.Errorf("expected bob to be the authenticated user, got %+v", seen)
+	}
+
+	// Tokens in subprotocols are only accepted on WebSocket handshakes.
+	protocols := "brigade.logs, " + WebSocketTokenProtocolPrefix + base64.RawURLEncoding.EncodeToString([]byte("def456"))
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", protocols)
+	container.ServeHTTP(rw, req)
+	if rw.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d with a subprotocol token on a plain request, got %d", http.StatusUnauthorized, rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Sec-WebSocket-Protocol", protocols)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	container.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Errorf("expected %d with a subprotocol token on a handshake, got %d", http.StatusOK, rw.Code)
+	}
+}
+
+func TestHandlersEnforceAuthorization(t *testing.T) {
+	policy, err := newPolicyAuthorizer(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockAPI := NewWithAuthorizer(mock.New(), policy)
+
+	request := func(user *User, method string) (*restful.Request, *restful.Response, *httptest.ResponseRecorder) {
+		rw := httptest.NewRecorder()
+		req := restful.NewRequest(httptest.NewRequest(method, "/?a=b", nil))
+		req.PathParameters()["id"] = mock.StubProject.ID
+		if user != nil {
+			req.SetAttribute(userAttribute, user)
+		}
+		resp := restful.NewResponse(rw)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		return req, resp, rw
+	}
+
+	mallory := &User{Name: "mallory"}
+	bob := &User{Name: "bob"}
+
+	req, resp, rw := request(mallory, "GET")
+	mockAPI.Project().Get(req, resp)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("Project.Get: expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	req, resp, rw = request(mallory, "GET")
+	mockAPI.Project().List(req, resp)
+	if strings.TrimSpace(rw.Body.String()) != "[]" {
+		t.Errorf("Project.List: expected no projects, got %s", rw.Body.String())
+	}
+
+	req, resp, rw = request(mallory, "GET")
+	mockAPI.Build().Get(req, resp)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("Build.Get: expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	req, resp, rw = request(mallory, "GET")
+	mockAPI.Job().Logs(req, resp)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("Job.Logs: expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	req, resp, rw = request(bob, "GET")
+	mockAPI.Build().Get(req, resp)
+	if rw.Code != http.StatusOK {
+		t.Errorf("Build.Get: expected %d, got %d", http.StatusOK, rw.Code)
+	}
+
+	req, resp, rw = request(bob, "DELETE")
+	mockAPI.Build().Delete(req, resp)
+	if rw.Code != http.StatusForbidden {
+		t.Errorf("Build.Delete: expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+}
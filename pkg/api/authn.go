@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// tokenFileAuthenticator authenticates tokens listed in a static file.
+type tokenFileAuthenticator struct {
+	tokens map[string]*User
+}
+
+// NewTokenFileAuthenticator loads a static token file.
+//
+// The file uses the same CSV format as the Kubernetes API server's
+// --token-auth-file: token,user,uid,"group1,group2". The uid and groups
+// columns are optional.
+func NewTokenFileAuthenticator(path string) (Authenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return newTokenFileAuthenticator(f)
+}
+
+func newTokenFileAuthenticator(r io.Reader) (Authenticator, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := map[string]*User{}
+	for i, record := range records {
+		if len(record) < 2 || record[0] == "" || record[1] == "" {
+			return nil, fmt.Errorf("token file line %d: a token and a user name are required", i+1)
+		}
+		user := &User{Name: record[1]}
+		if len(record) > 3 && record[3] != "" {
+			for _, g := range strings.Split(record[3], ",") {
+				user.Groups = append(user.Groups, strings.TrimSpace(g))
+			}
+		}
+		tokens[record[0]] = user
+	}
+	return &tokenFileAuthenticator{tokens: tokens}, nil
+}
+
+// Authenticate looks the token up in the static token file.
+func (a *tokenFileAuthenticator) Authenticate(token string) (*User, error) {
+	for t, user := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return user, nil
+		}
+	}
+	return nil, ErrUnauthenticated
+}
+
+// tokenReviewAuthenticator authenticates tokens using the Kubernetes TokenReview API.
+type tokenReviewAuthenticator struct {
+	client    kubernetes.Interface
+	audiences []string
+}
+
+// NewTokenReviewAuthenticator returns an Authenticator which validates tokens
+// (such as service account tokens) against the Kubernetes API server.
+func NewTokenReviewAuthenticator(client kubernetes.Interface, audiences ...string) Authenticator {
+	return &tokenReviewAuthenticator{client: client, audiences: audiences}
+}
+
+// Authenticate submits a TokenReview for the token.
+func (a *tokenReviewAuthenticator) Authenticate(token string) (*User, error) {
+	review := &authnv1.TokenReview{
+		Spec: authnv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: a.audiences,
+		},
+	}
+	result, err := a.client.AuthenticationV1().TokenReviews().Create(context.TODO(), review, meta.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Status.Authenticated {
+		return nil, ErrUnauthenticated
+	}
+	return &User{
+		Name:   result.Status.User.Username,
+		Groups: result.Status.User.Groups,
+	}, nil
+}
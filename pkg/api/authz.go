@@ -0,0 +1,124 @@
+package api
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// wildcard matches any user, group or project in a policy rule.
+const wildcard = "*"
+
+// PolicyRule grants users and groups access to projects.
+type PolicyRule struct {
+	// Users are the user names the rule applies to.
+	Users []string `yaml:"users"`
+	// Groups are the groups the rule applies to.
+	Groups []string `yaml:"groups"`
+	// Projects are the names or IDs of the projects the rule grants access to.
+	Projects []string `yaml:"projects"`
+	// Verbs are the kinds of access granted. Write access implies read access.
+	Verbs []Verb `yaml:"verbs"`
+}
+
+// Policy is a set of rules granting access to projects.
+//
+// A request is authorized if any rule matches the user (by name or group),
+// the project and the verb.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// NewPolicyAuthorizer loads a YAML (or JSON) policy file.
+//
+// For example:
+//
+//	rules:
+//	- groups: ["team-a"]
+//	  projects: ["org/service-a", "org/service-b"]
+//	  verbs: ["read", "write"]
+//	- groups: ["system:authenticated"]
+//	  projects: ["*"]
+//	  verbs: ["read"]
+func NewPolicyAuthorizer(path string) (Authorizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return newPolicyAuthorizer(f)
+}
+
+func newPolicyAuthorizer(r io.Reader) (*Policy, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	// Projects may be named either by name or by ID, so normalize them all to IDs.
+	for i := range policy.Rules {
+		for j, p := range policy.Rules[i].Projects {
+			if p != wildcard {
+				policy.Rules[i].Projects[j] = brigade.ProjectID(p)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// Authorize grants access if a rule of the policy matches.
+func (p *Policy) Authorize(user *User, projectID string, verb Verb) bool {
+	if user == nil {
+		return false
+	}
+	for _, rule := range p.Rules {
+		if rule.matchesUser(user) && rule.matchesProject(projectID) && rule.matchesVerb(verb) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) matchesUser(user *User) bool {
+	for _, u := range r.Users {
+		if u == wildcard || u == user.Name {
+			return true
+		}
+	}
+	for _, g := range r.Groups {
+		if g == wildcard {
+			return true
+		}
+		for _, ug := range user.Groups {
+			if g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) matchesProject(projectID string) bool {
+	for _, p := range r.Projects {
+		if p == wildcard || p == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) matchesVerb(verb Verb) bool {
+	for _, v := range r.Verbs {
+		if v == verb || (v == VerbWrite && verb == VerbRead) {
+			return true
+		}
+	}
+	return false
+}
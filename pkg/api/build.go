@@ -1,17 +1,51 @@
 package api
 
 import (
-	"io"
 	"net/http"
 
 	restful "github.com/emicklei/go-restful"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 // Build represents the build api handlers.
 type Build struct {
-	store storage.Store
+	store            storage.Store
+	authz            Authorizer
+	websocketOrigins []string
+}
+
+// List creates a new gin handler for the GET /builds endpoint
+//
+// Builds of projects the caller may not read are removed from each page, so a
+// page may hold fewer builds than the requested limit.
+func (api Build) List(request *restful.Request, response *restful.Response) {
+	opts, err := buildListOptions(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	list, err := api.store.ListBuilds(opts)
+	if err == storage.ErrInvalidContinue {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Builds found.")
+		return
+	}
+	user := UserFromRequest(request)
+	builds := []*brigade.Build{}
+	for _, b := range list.Items {
+		if allowed(api.authz, user, b.ProjectID, VerbRead) {
+			builds = append(builds, b)
+		}
+	}
+	if list.Continue != "" {
+		response.AddHeader(ContinueHeader, list.Continue)
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, builds)
 }
 
 // Get creates a new gin handler for the GET /build/:id endpoint
@@ -23,6 +57,9 @@ func (api Build) Get(request *restful.Request, response *restful.Response) {
 		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
 		return
 	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbRead) {
+		return
+	}
 	response.WriteEntity(build)
 }
 
@@ -34,6 +71,9 @@ func (api Build) Jobs(request *restful.Request, response *restful.Response) {
 		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
 		return
 	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbRead) {
+		return
+	}
 	jobs, err := api.store.GetBuildJobs(build)
 	if err != nil {
 		response.WriteErrorString(http.StatusNotFound, "Build Jobs could not be found.")
@@ -43,24 +83,57 @@ func (api Build) Jobs(request *restful.Request, response *restful.Response) {
 
 }
 
-// Logs creates a new gin handler for the GET /build/:id/logs endpoint
-func (api Build) Logs(request *restful.Request, response *restful.Response) {
+// Lineage creates a new gin handler for the GET /build/:id/lineage endpoint
+//
+// The lineage holds the builds that the build reruns and the builds that
+// rerun them, oldest first.
+func (api Build) Lineage(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 	build, err := api.store.GetBuild(id)
 	if err != nil {
 		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
 		return
 	}
-	if request.QueryParameter("stream") == "true" {
-		logReader, err := api.store.GetWorkerLogStream(build.Worker)
+	if !authorized(api.authz, request, response, build.ProjectID, VerbRead) {
+		return
+	}
+	// The build exists, so the store failed to read its lineage.
+	builds, err := api.store.GetBuildLineage(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build Lineage could not be read.")
+		return
+	}
+	response.WriteEntity(builds)
+}
+
+// Logs creates a new gin handler for the GET /build/:id/logs endpoint
+//
+// With follow=true the logs are streamed until the worker terminates or the
+// client goes away.
+func (api Build) Logs(request *restful.Request, response *restful.Response) {
+	build, ok := api.buildWithWorker(request, response)
+	if !ok {
+		return
+	}
+	opts, err := logOptions(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.QueryParameter("stream") == "true" || opts.Follow {
+		logReader, err := api.store.GetWorkerLogStreamWithOptions(build.Worker, opts)
 		if err != nil {
 			response.WriteErrorString(http.StatusNotFound, "Build Logs could not be found.")
 			return
 		}
-		defer logReader.Close()
-		io.Copy(response.ResponseWriter, logReader)
+		streamLogs(request, response, logReader)
 	} else {
-		logs, err := api.store.GetWorkerLog(build.Worker)
+		var logs string
+		if opts == (storage.LogOptions{}) {
+			logs, err = api.store.GetWorkerLog(build.Worker)
+		} else {
+			logs, err = readLogs(api.store.GetWorkerLogStreamWithOptions(build.Worker, opts))
+		}
 		if err != nil {
 			response.WriteErrorString(http.StatusNotFound, "Build Logs could not be found.")
 			return
@@ -71,3 +144,116 @@ func (api Build) Logs(request *restful.Request, response *restful.Response) {
 		response.WriteEntity(logs)
 	}
 }
+
+// LogsWebSocket creates a new gin handler for the GET /build/:id/logs/ws endpoint
+//
+// The logs are sent as one WebSocket message per line, and followed unless
+// follow=false.
+func (api Build) LogsWebSocket(request *restful.Request, response *restful.Response) {
+	build, ok := api.buildWithWorker(request, response)
+	if !ok {
+		return
+	}
+	opts, err := websocketLogOptions(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	logReader, err := api.store.GetWorkerLogStreamWithOptions(build.Worker, opts)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build Logs could not be found.")
+		return
+	}
+	websocketLogs(request, response, logReader, api.websocketOrigins)
+}
+
+// MergedLogs creates a new gin handler for the GET /build/:id/logs/all endpoint
+//
+// The init container, worker and job logs are interleaved in the order they
+// were written, and every line is prefixed with its source.
+func (api Build) MergedLogs(request *restful.Request, response *restful.Response) {
+	build, ok := api.buildWithWorker(request, response)
+	if !ok {
+		return
+	}
+	logReader, err := storage.GetMergedBuildLogStream(api.store, build, request.QueryParameter("timestamps") == "true")
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build Logs could not be found.")
+		return
+	}
+	streamLogs(request, response, logReader)
+}
+
+// buildWithWorker gets the build of the request for reading its logs. It
+// writes an error response and returns false if the build or its worker
+// could not be found, or the caller may not read it.
+func (api Build) buildWithWorker(request *restful.Request, response *restful.Response) (*brigade.Build, bool) {
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return nil, false
+	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbRead) {
+		return nil, false
+	}
+	if build.Worker == nil {
+		response.WriteErrorString(http.StatusNotFound, "Build Logs could not be found.")
+		return nil, false
+	}
+	return build, true
+}
+
+// Delete creates a new gin handler for the DELETE /build/:id endpoint
+//
+// Running builds are not deleted unless the force query parameter is set to
+// true.
+func (api Build) Delete(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbWrite) {
+		return
+	}
+	opts := storage.DeleteBuildOptions{
+		SkipRunningBuilds: request.QueryParameter("force") != "true",
+	}
+	err = api.store.DeleteBuild(id, opts)
+	if err == storage.ErrBuildRunning {
+		response.WriteErrorString(http.StatusConflict, "Build is still running, set force=true to delete it.")
+		return
+	}
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be deleted.")
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// Cancel creates a new gin handler for the POST /build/:id/cancel endpoint
+//
+// Builds that have already finished can not be cancelled.
+func (api Build) Cancel(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbWrite) {
+		return
+	}
+	err = api.store.CancelBuild(id)
+	if err == storage.ErrBuildFinished {
+		response.WriteErrorString(http.StatusConflict, "Build has already finished.")
+		return
+	}
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be cancelled.")
+		return
+	}
+	response.WriteHeader(http.StatusAccepted)
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +11,8 @@ import (
 
 	restful "github.com/emicklei/go-restful"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/mock"
 )
 
@@ -60,3 +64,93 @@ func TestBuildLogs(t *testing.T) {
 	}
 
 }
+
+func TestBuildDelete(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest("DELETE", "/?force=true", nil)
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+	respo := restful.NewResponse(rw)
+
+	mockAPI.Build().Delete(req, respo)
+	if rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d, got %d", http.StatusNoContent, rw.Code)
+	}
+
+	// A running build is not deleted without force.
+	store.DeleteBuildErr = storage.ErrBuildRunning
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("DELETE", "/", nil)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+
+	mockAPI.Build().Delete(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, rw.Code)
+	}
+}
+
+func TestBuildCancel(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest("POST", "/", nil)
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+	respo := restful.NewResponse(rw)
+
+	mockAPI.Build().Cancel(req, respo)
+	if rw.Code != http.StatusAccepted {
+		t.Errorf("expected %d, got %d", http.StatusAccepted, rw.Code)
+	}
+
+	// Builds that have finished, or were already terminated, are a conflict.
+	store.CancelBuildErr = storage.ErrBuildFinished
+	rw = httptest.NewRecorder()
+	mockAPI.Build().Cancel(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, rw.Code)
+	}
+
+	store.CancelBuildErr = errors.New("boom")
+	rw = httptest.NewRecorder()
+	mockAPI.Build().Cancel(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, rw.Code)
+	}
+}
+
+func TestBuildLineage(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest("GET", "/", nil)
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = mock.StubBuild2.ID
+	respo := restful.NewResponse(rw)
+	respo.SetRequestAccepts("application/json")
+
+	mockAPI.Build().Lineage(req, respo)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rw.Code)
+	}
+	builds := []*brigade.Build{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &builds); err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 2 || builds[0].ID != mock.StubBuild1.ID || builds[1].ID != mock.StubBuild2.ID {
+		t.Errorf("expected the mock builds, got %v", builds)
+	}
+	// The build exists, so failing to read its lineage is a server error.
+	store.GetBuildLineageErr = errors.New("boom")
+	rw = httptest.NewRecorder()
+	mockAPI.Build().Lineage(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, rw.Code)
+	}
+}
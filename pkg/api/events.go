@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+const (
+	// DefaultEventHistorySize is the number of events kept for clients resuming a stream.
+	DefaultEventHistorySize = 1000
+	// eventBufferSize is the number of events buffered per subscriber before it is disconnected.
+	eventBufferSize = 64
+	// eventHeartbeatInterval is how often a comment is sent to keep idle streams open.
+	eventHeartbeatInterval = 15 * time.Second
+)
+
+// EventBroker keeps a bounded history of build events and fans them out to subscribers.
+//
+// Events are identified by their IDs, which publishers set to values that
+// increase across restarts, such as the resource versions of the objects that
+// changed.
+type EventBroker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	historySize int
+	history     []*brigade.BuildEvent
+	subscribers map[chan *brigade.BuildEvent]struct{}
+}
+
+// NewEventBroker creates a broker which remembers the last historySize events.
+func NewEventBroker(historySize int) *EventBroker {
+	return &EventBroker{
+		historySize: historySize,
+		subscribers: map[chan *brigade.BuildEvent]struct{}{},
+	}
+}
+
+// Publish delivers the event to every subscriber. Events without an ID are
+// given the ID after the highest one published.
+//
+// A subscriber which is too slow to keep up is disconnected. It can resume
+// from the last event it received.
+func (b *EventBroker) Publish(e *brigade.BuildEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if e.ID == 0 {
+		e.ID = b.lastID + 1
+	}
+	if e.ID > b.lastID {
+		b.lastID = e.ID
+	}
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the remembered events with IDs after lastID, ordered by
+// ID, and a channel receiving every event published from now on. The channel is closed
+// when cancel is called or the subscriber falls behind.
+func (b *EventBroker) Subscribe(lastID uint64) (backlog []*brigade.BuildEvent, events <-chan *brigade.BuildEvent, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range b.history {
+		if e.ID > lastID {
+			backlog = append(backlog, e)
+		}
+	}
+	// Events of different objects may be published out of order.
+	sort.SliceStable(backlog, func(i, j int) bool { return backlog[i].ID < backlog[j].ID })
+
+	ch := make(chan *brigade.BuildEvent, eventBufferSize)
+	b.subscribers[ch] = struct{}{}
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// Events represents the server-sent events api handlers.
+type Events struct {
+	store  storage.Store
+	authz  Authorizer
+	broker *EventBroker
+}
+
+// Events returns a handler for streams of build events published by broker.
+func (api API) Events(broker *EventBroker) Events {
+	return Events{store: api.store, authz: api.authz, broker: broker}
+}
+
+// Stream creates a new gin handler for the GET /events endpoint
+func (api Events) Stream(request *restful.Request, response *restful.Response) {
+	api.stream(request, response, func(*brigade.BuildEvent) bool { return true })
+}
+
+// Project creates a new gin handler for the GET /project/:id/events endpoint
+func (api Events) Project(request *restful.Request, response *restful.Response) {
+	pid := brigade.ProjectID(request.PathParameter("id"))
+	if !authorized(api.authz, request, response, pid, VerbRead) {
+		return
+	}
+	api.stream(request, response, func(e *brigade.BuildEvent) bool { return e.ProjectID == pid })
+}
+
+// Build creates a new gin handler for the GET /build/:id/events endpoint
+func (api Events) Build(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	build, err := api.store.GetBuild(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Build could not be found.")
+		return
+	}
+	if !authorized(api.authz, request, response, build.ProjectID, VerbRead) {
+		return
+	}
+	api.stream(request, response, func(e *brigade.BuildEvent) bool { return e.BuildID == build.ID })
+}
+
+// stream writes matching events to the response as server-sent events until
+// the client goes away.
+//
+// Clients resume a stream by sending the ID of the last event they saw in the
+// Last-Event-ID header (or the lastEventId query parameter). Events are only
+// remembered for a while, so a client that was gone for long may miss some.
+func (api Events) stream(request *restful.Request, response *restful.Response, match func(*brigade.BuildEvent) bool) {
+	var lastID uint64
+	if last := request.HeaderParameter("Last-Event-ID"); last != "" {
+		lastID, _ = strconv.ParseUint(last, 10, 64)
+	} else if last := request.QueryParameter("lastEventId"); last != "" {
+		lastID, _ = strconv.ParseUint(last, 10, 64)
+	}
+
+	backlog, events, cancel := api.broker.Subscribe(lastID)
+	defer cancel()
+
+	response.AddHeader("Content-Type", "text/event-stream")
+	response.AddHeader("Cache-Control", "no-cache")
+	response.AddHeader("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+
+	user := UserFromRequest(request)
+	send := func(e *brigade.BuildEvent) error {
+		if !match(e) || !allowed(api.authz, user, e.ProjectID, VerbRead) {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(response, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		return err
+	}
+
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	response.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+			response.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			response.Flush()
+		case <-request.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestEventBroker(t *testing.T) {
+	broker := NewEventBroker(2)
+	for i := 0; i < 3; i++ {
+		broker.Publish(&brigade.BuildEvent{Type: brigade.BuildCreated})
+	}
+
+	// Only the last two events are remembered.
+	backlog, events, cancel := broker.Subscribe(0)
+	if len(backlog) != 2 || backlog[0].ID != 2 || backlog[1].ID != 3 {
+		t.Fatalf("unexpected backlog %+v", backlog)
+	}
+
+	broker.Publish(&brigade.BuildEvent{Type: brigade.JobPhaseChanged})
+	if e := <-events; e.ID != 4 {
+		t.Errorf("expected event 4, got %d", e.ID)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("expected the channel to be closed after cancel")
+	}
+
+	// Resuming after the last event seen returns nothing from history.
+	backlog, _, cancel = broker.Subscribe(4)
+	defer cancel()
+	if len(backlog) != 0 {
+		t.Errorf("expected an empty backlog, got %+v", backlog)
+	}
+}
+
+func TestEventBrokerPublisherIDs(t *testing.T) {
+	broker := NewEventBroker(DefaultEventHistorySize)
+	broker.Publish(&brigade.BuildEvent{ID: 120, Type: brigade.WorkerPhaseChanged})
+	broker.Publish(&brigade.BuildEvent{ID: 118, Type: brigade.BuildCreated})
+	broker.Publish(&brigade.BuildEvent{ID: 125, Type: brigade.JobPhaseChanged})
+
+	// The IDs of the publisher are kept, and the backlog is ordered by them.
+	backlog, _, cancel := broker.Subscribe(118)
+	defer cancel()
+	if len(backlog) != 2 || backlog[0].ID != 120 || backlog[1].ID != 125 {
+		t.Fatalf("unexpected backlog %+v", backlog)
+	}
+
+	// Events without an ID follow the highest one.
+	e := &brigade.BuildEvent{Type: brigade.BuildCreated}
+	broker.Publish(e)
+	if e.ID != 126 {
+		t.Errorf("expected event 126, got %d", e.ID)
+	}
+}
+
+func TestEventBrokerDisconnectsSlowSubscribers(t *testing.T) {
+	broker := NewEventBroker(DefaultEventHistorySize)
+	_, events, cancel := broker.Subscribe(0)
+	defer cancel()
+
+	for i := 0; i <= eventBufferSize; i++ {
+		broker.Publish(&brigade.BuildEvent{Type: brigade.BuildCreated})
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != eventBufferSize {
+		t.Errorf("expected %d buffered events before disconnect, got %d", eventBufferSize, n)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	broker := NewEventBroker(DefaultEventHistorySize)
+	broker.Publish(&brigade.BuildEvent{Type: brigade.BuildCreated, ProjectID: "project-id", BuildID: "build-id1"})
+	broker.Publish(&brigade.BuildEvent{Type: brigade.BuildCreated, ProjectID: "other", BuildID: "build-id3"})
+	broker.Publish(&brigade.BuildEvent{Type: brigade.WorkerPhaseChanged, ProjectID: "project-id", BuildID: "build-id1"})
+
+	events := New(mock.New()).Events(broker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	httpRequest := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	httpRequest.Header.Set("Last-Event-ID", "1")
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "build-id1"
+	rw := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		events.Build(req, restful.NewResponse(rw))
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	if ct := rw.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	body := rw.Body.String()
+	if strings.Contains(body, "id: 1\n") || strings.Contains(body, "id: 2\n") {
+		t.Errorf("expected events before Last-Event-ID and of other builds to be skipped, got %q", body)
+	}
+	if !strings.Contains(body, "id: 3\nevent: worker_phase_changed\ndata: {") {
+		t.Errorf("expected the worker event to be streamed, got %q", body)
+	}
+}
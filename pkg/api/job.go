@@ -1,9 +1,9 @@
 package api
 
 import (
-	"io"
 	"net/http"
 
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage"
 
 	restful "github.com/emicklei/go-restful"
@@ -11,38 +11,48 @@ import (
 
 // Job represents the job api handlers.
 type Job struct {
-	store storage.Store
+	store            storage.Store
+	authz            Authorizer
+	websocketOrigins []string
 }
 
 // Get creates a new gin handler for the GET /job/:id endpoint
 func (api Job) Get(request *restful.Request, response *restful.Response) {
-	id := request.PathParameter("id")
-	job, err := api.store.GetJob(id)
-	if err != nil {
-		response.WriteErrorString(http.StatusNotFound, "Job could not be found.")
+	job, ok := api.job(request, response)
+	if !ok {
 		return
 	}
 	response.WriteEntity(job)
 }
 
 // Logs creates a new gin handler for the GET /job/:id/logs endpoint
+//
+// With follow=true the logs are streamed until the job terminates or the
+// client goes away.
 func (api Job) Logs(request *restful.Request, response *restful.Response) {
-	id := request.PathParameter("id")
-	job, err := api.store.GetJob(id)
+	job, ok := api.job(request, response)
+	if !ok {
+		return
+	}
+	opts, err := logOptions(request)
 	if err != nil {
-		response.WriteErrorString(http.StatusNotFound, "Job could not be found.")
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
 		return
 	}
-	if request.QueryParameter("stream") == "true" {
-		logReader, err := api.store.GetJobLogStream(job)
+	if request.QueryParameter("stream") == "true" || opts.Follow {
+		logReader, err := api.store.GetJobLogStreamWithOptions(job, opts)
 		if err != nil {
 			response.WriteErrorString(http.StatusNotFound, "Job could not be found.")
 			return
 		}
-		defer logReader.Close()
-		io.Copy(response.ResponseWriter, logReader)
+		streamLogs(request, response, logReader)
 	} else {
-		logs, err := api.store.GetJobLog(job)
+		var logs string
+		if opts == (storage.LogOptions{}) {
+			logs, err = api.store.GetJobLog(job)
+		} else {
+			logs, err = readLogs(api.store.GetJobLogStreamWithOptions(job, opts))
+		}
 		if err != nil {
 			response.WriteErrorString(http.StatusNotFound, "Job Logs could not be found.")
 			return
@@ -53,3 +63,40 @@ func (api Job) Logs(request *restful.Request, response *restful.Response) {
 		response.WriteEntity(logs)
 	}
 }
+
+// LogsWebSocket creates a new gin handler for the GET /job/:id/logs/ws endpoint
+//
+// The logs are sent as one WebSocket message per line, and followed unless
+// follow=false.
+func (api Job) LogsWebSocket(request *restful.Request, response *restful.Response) {
+	job, ok := api.job(request, response)
+	if !ok {
+		return
+	}
+	opts, err := websocketLogOptions(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	logReader, err := api.store.GetJobLogStreamWithOptions(job, opts)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Job Logs could not be found.")
+		return
+	}
+	websocketLogs(request, response, logReader, api.websocketOrigins)
+}
+
+// job gets the job of the request. It writes an error response and returns
+// false if the job could not be found or the caller may not read it.
+func (api Job) job(request *restful.Request, response *restful.Response) (*brigade.Job, bool) {
+	id := request.PathParameter("id")
+	job, err := api.store.GetJob(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "Job could not be found.")
+		return nil, false
+	}
+	if !authorized(api.authz, request, response, job.ProjectID, VerbRead) {
+		return nil, false
+	}
+	return job, true
+}
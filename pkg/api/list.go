@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// ContinueHeader is the response header holding the token for the next page of a list.
+const ContinueHeader = "X-Continue"
+
+// buildListOptions reads the pagination, filter and sort query parameters of a build listing.
+func buildListOptions(request *restful.Request) (storage.BuildListOptions, error) {
+	opts := storage.BuildListOptions{
+		Continue: request.QueryParameter("continue"),
+		Type:     request.QueryParameter("type"),
+		Provider: request.QueryParameter("provider"),
+		Commit:   request.QueryParameter("commit"),
+		Ref:      request.QueryParameter("ref"),
+	}
+
+	if limit := request.QueryParameter("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid limit %q", limit)
+		}
+		opts.Limit = n
+	}
+
+	for _, param := range request.QueryParameters("status") {
+		for _, status := range strings.Split(param, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				opts.Status = append(opts.Status, brigade.JobStatus(status))
+			}
+		}
+	}
+
+	for name, t := range map[string]*time.Time{"since": &opts.Since, "until": &opts.Until} {
+		if v := request.QueryParameter(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q, expected an RFC 3339 time", name, v)
+			}
+			*t = parsed
+		}
+	}
+
+	switch order := request.QueryParameter("order"); order {
+	case "", "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		return opts, fmt.Errorf("invalid order %q, expected asc or desc", order)
+	}
+
+	return opts, nil
+}
+
+// AddBuildListParams documents the pagination, filter and sort query parameters of a build listing route.
+func AddBuildListParams(ws *restful.WebService, rb *restful.RouteBuilder) *restful.RouteBuilder {
+	return rb.
+		Param(ws.QueryParameter("limit", "maximum number of builds to return, 0 for all").DataType("integer")).
+		Param(ws.QueryParameter("continue", "token from the "+ContinueHeader+" header of the previous page").DataType("string")).
+		Param(ws.QueryParameter("status", "comma-separated worker statuses to match").DataType("string")).
+		Param(ws.QueryParameter("type", "event type to match").DataType("string")).
+		Param(ws.QueryParameter("provider", "event provider to match").DataType("string")).
+		Param(ws.QueryParameter("commit", "commit prefix to match").DataType("string")).
+		Param(ws.QueryParameter("ref", "ref to match").DataType("string")).
+		Param(ws.QueryParameter("since", "match builds started at or after this RFC 3339 time").DataType("string")).
+		Param(ws.QueryParameter("until", "match builds started before this RFC 3339 time").DataType("string")).
+		Param(ws.QueryParameter("order", "sort by start time, asc or desc").DataType("string").DefaultValue("desc"))
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+	"golang.org/x/net/websocket"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// logBufferSize is the size of the chunks in which streamed logs are copied.
+const logBufferSize = 32 * 1024
+
+// AddLogParams documents the query parameters understood by the logs endpoints on a route.
+func AddLogParams(ws *restful.WebService, rb *restful.RouteBuilder) *restful.RouteBuilder {
+	return rb.
+		Param(ws.QueryParameter("stream", "return the logs as plain text instead of a JSON string").DataType("boolean")).
+		Param(ws.QueryParameter("follow", "keep the stream open and send new log lines as they are written; implies stream").DataType("boolean")).
+		Param(ws.QueryParameter("tailLines", "number of lines from the end of the log to return").DataType("integer")).
+		Param(ws.QueryParameter("sinceSeconds", "only return log lines written in the last sinceSeconds seconds").DataType("integer")).
+		Param(ws.QueryParameter("timestamps", "prefix every log line with an RFC 3339 timestamp").DataType("boolean"))
+}
+
+// logOptions parses the follow, tailLines, sinceSeconds and timestamps query parameters.
+func logOptions(request *restful.Request) (storage.LogOptions, error) {
+	opts := storage.LogOptions{
+		Follow:     request.QueryParameter("follow") == "true",
+		Timestamps: request.QueryParameter("timestamps") == "true",
+	}
+	for name, v := range map[string]*int64{
+		"tailLines":    &opts.TailLines,
+		"sinceSeconds": &opts.SinceSeconds,
+	} {
+		s := request.QueryParameter(name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("Invalid %s %q.", name, s)
+		}
+		*v = n
+	}
+	return opts, nil
+}
+
+// readLogs reads a whole log stream into a string.
+func readLogs(logs io.ReadCloser, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+	buf := new(bytes.Buffer)
+	_, err = io.Copy(buf, logs)
+	return buf.String(), err
+}
+
+// streamLogs writes logs to the response as plain text, flushing every chunk so
+// that followed logs reach the client as soon as they are written.
+//
+// The log stream is closed when the client goes away.
+func streamLogs(request *restful.Request, response *restful.Response, logs io.ReadCloser) {
+	defer logs.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-request.Request.Context().Done():
+			logs.Close()
+		case <-done:
+		}
+	}()
+
+	response.AddHeader("Content-Type", "text/plain; charset=utf-8")
+	response.AddHeader("X-Content-Type-Options", "nosniff")
+	// Keep reverse proxies such as nginx from buffering followed logs.
+	response.AddHeader("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	buf := make([]byte, logBufferSize)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, werr := response.Write(buf[:n]); werr != nil {
+				return
+			}
+			response.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// websocketLogOptions parses the log query parameters of a WebSocket request.
+// Unlike plain HTTP, logs are followed unless follow is set to false.
+func websocketLogOptions(request *restful.Request) (storage.LogOptions, error) {
+	opts, err := logOptions(request)
+	opts.Follow = request.QueryParameter("follow") != "false"
+	return opts, err
+}
+
+// websocketLogs upgrades the request to a WebSocket and sends logs to the
+// client, one text message per log line.
+//
+// Handshakes from pages of other sites than the API server and the allowed
+// origins are rejected (see checkWebsocketOrigin).
+//
+// The log stream is closed when the client closes the connection.
+func websocketLogs(request *restful.Request, response *restful.Response, logs io.ReadCloser, origins []string) {
+	// The handler is not called if the handshake fails.
+	defer logs.Close()
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			if err := checkWebsocketOrigin(config, req, origins); err != nil {
+				return err
+			}
+			config.Protocol = selectWebsocketProtocol(config.Protocol)
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			defer logs.Close()
+			go func() {
+				// Clients do not send anything, so a read only returns once
+				// the connection is closed.
+				io.Copy(ioutil.Discard, conn)
+				logs.Close()
+			}()
+
+			r := bufio.NewReaderSize(logs, logBufferSize)
+			for {
+				line, err := r.ReadString('\n')
+				if line != "" {
+					if werr := websocket.Message.Send(conn, strings.TrimSuffix(line, "\n")); werr != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(response.ResponseWriter, request.Request)
+}
+
+// checkWebsocketOrigin returns an error unless the WebSocket handshake comes
+// from a client that is not a browser, which does not send an Origin header,
+// from a page of the API server itself, or from a page of one of the allowed
+// origins.
+func checkWebsocketOrigin(config *websocket.Config, req *http.Request, allowed []string) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin == nil || origin.Host == req.Host {
+		return nil
+	}
+	for _, o := range allowed {
+		if o == wildcard || strings.EqualFold(strings.TrimSuffix(o, "/"), origin.Scheme+"://"+origin.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
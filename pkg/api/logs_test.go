@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	"golang.org/x/net/websocket"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+func TestLogsFollow(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest("GET", "/?follow=true&tailLines=10&sinceSeconds=60&timestamps=true", nil))
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+	mockAPI.Build().Logs(req, restful.NewResponse(rw))
+
+	if rw.Body.String() != mock.StubLogData {
+		t.Errorf("expected %q, got %q", mock.StubLogData, rw.Body.String())
+	}
+	if !rw.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if ct := rw.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected a plain text response, got %q", ct)
+	}
+	expect := storage.LogOptions{Follow: true, TailLines: 10, SinceSeconds: 60, Timestamps: true}
+	if store.LastLogOptions != expect {
+		t.Errorf("expected options %+v, got %+v", expect, store.LastLogOptions)
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/?follow=true", nil))
+	req.PathParameters()["id"] = mock.StubJob.ID
+	mockAPI.Job().Logs(req, restful.NewResponse(rw))
+	if rw.Body.String() != mock.StubLogData {
+		t.Errorf("expected %q, got %q", mock.StubLogData, rw.Body.String())
+	}
+	if !store.LastLogOptions.Follow {
+		t.Error("expected the job logs to be followed")
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/?tailLines=-1", nil))
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+	mockAPI.Build().Logs(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a negative tailLines, got %d", http.StatusBadRequest, rw.Code)
+	}
+}
+
+func TestLogsWebSocket(t *testing.T) {
+	store := mock.New()
+	store.LogData = "one\ntwo\n"
+	authn, err := newTokenFileAuthenticator(strings.NewReader(testTokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ws := new(restful.WebService)
+	ws.Filter(AuthFilter(authn))
+	ws.Route(ws.GET("/build/{id}/logs/ws").To(New(store).Build().LogsWebSocket))
+	ws.Route(ws.GET("/job/{id}/logs/ws").To(New(store).WithWebSocketOrigins([]string{"https://dashboard.example.com"}).Job().LogsWebSocket))
+	container := restful.NewContainer()
+	container.Add(ws)
+	server := httptest.NewServer(container)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/build/" + mock.StubBuild1.ID + "/logs/ws"
+	if _, err := websocket.Dial(url, "", server.URL); err == nil {
+		t.Error("expected the handshake to fail without a token")
+	}
+
+	token := WebSocketTokenProtocolPrefix + base64.RawURLEncoding.EncodeToString([]byte("abc123"))
+	config, err := websocket.NewConfig(url, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{"brigade.logs", token}
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, expect := range []string{"one", "two"} {
+		var line string
+		if err := websocket.Message.Receive(conn, &line); err != nil {
+			t.Fatal(err)
+		}
+		if line != expect {
+			t.Errorf("expected %q, got %q", expect, line)
+		}
+	}
+	if !store.LastLogOptions.Follow {
+		t.Error("expected WebSocket logs to be followed by default")
+	}
+
+	// Pages of other sites may not open WebSockets unless their origin is allowed.
+	config, err = websocket.NewConfig(url, "https://evil.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{"brigade.logs", token}
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Error("expected the handshake to fail for another origin")
+	}
+	config, err = websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/job/"+mock.StubJob.ID+"/logs/ws", "https://dashboard.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{"brigade.logs", token}
+	conn, err = websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("expected the handshake to succeed for an allowed origin: %s", err)
+	}
+	conn.Close()
+}
+
+func TestMergedLogs(t *testing.T) {
+	store := mock.New()
+	store.LogData = "2020-01-01T00:00:01Z cloning\nstill cloning\n2020-01-01T00:00:03Z done\n"
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest("GET", "/?a=b", nil))
+	req.PathParameters()["id"] = mock.StubBuild1.ID
+	mockAPI.Build().MergedLogs(req, restful.NewResponse(rw))
+
+	// The mock returns the same log for every source, so lines written at the
+	// same time keep the order of init, worker and jobs.
+	expect := `[init] cloning
+[init] still cloning
+[worker] cloning
+[worker] still cloning
+[job-id] cloning
+[job-id] still cloning
+[init] done
+[worker] done
+[job-id] done
+`
+	if rw.Body.String() != expect {
+		t.Errorf("expected %q, got %q", expect, rw.Body.String())
+	}
+	if !store.LastLogOptions.Timestamps {
+		t.Error("expected the logs to be read with timestamps")
+	}
+}
@@ -12,7 +12,9 @@ import (
 
 // Project represents the project api handlers.
 type Project struct {
-	store storage.Store
+	store            storage.Store
+	authz            Authorizer
+	websocketOrigins []string
 }
 
 // List creates a new gin handler for the GET /projects endpoint
@@ -22,7 +24,19 @@ func (api Project) List(request *restful.Request, response *restful.Response) {
 		response.WriteErrorString(http.StatusNotFound, "No Projects found.")
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusOK, projects)
+	response.WriteHeaderAndEntity(http.StatusOK, api.readableProjects(request, projects))
+}
+
+// readableProjects filters out the projects the caller may not read.
+func (api Project) readableProjects(request *restful.Request, projects []*brigade.Project) []*brigade.Project {
+	user := UserFromRequest(request)
+	res := []*brigade.Project{}
+	for _, p := range projects {
+		if allowed(api.authz, user, p.ID, VerbRead) {
+			res = append(res, p)
+		}
+	}
+	return res
 }
 
 // ProjectBuildSummary is a project plus the latest build data
@@ -38,7 +52,7 @@ func (api Project) ListWithLatestBuild(request *restful.Request, response *restf
 		response.WriteErrorString(http.StatusNotFound, "No Projects found.")
 		return
 	}
-	res := api.getBuildSummariesForProjects(projects)
+	res := api.getBuildSummariesForProjects(api.readableProjects(request, projects))
 
 	response.WriteHeaderAndEntity(http.StatusOK, res)
 }
@@ -70,6 +84,9 @@ func (api Project) Get(request *restful.Request, response *restful.Response) {
 		response.WriteErrorString(http.StatusNotFound, "No Project found.")
 		return
 	}
+	if !authorized(api.authz, request, response, proj.ID, VerbRead) {
+		return
+	}
 	response.WriteHeaderAndEntity(http.StatusOK, proj)
 }
 
@@ -81,10 +98,155 @@ func (api Project) Builds(request *restful.Request, response *restful.Response)
 		response.WriteErrorString(http.StatusNotFound, "No Project found.")
 		return
 	}
-	builds, err := api.store.GetProjectBuilds(proj)
+	if !authorized(api.authz, request, response, proj.ID, VerbRead) {
+		return
+	}
+	opts, err := buildListOptions(request)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	list, err := api.store.ListProjectBuilds(proj, opts)
+	if err == storage.ErrInvalidContinue {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		response.WriteErrorString(http.StatusNotFound, "No Project Builds found.")
 		return
 	}
-	response.WriteHeaderAndEntity(http.StatusOK, builds)
+	if list.Continue != "" {
+		response.AddHeader(ContinueHeader, list.Continue)
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, list.Items)
+}
+
+// Create creates a new gin handler for the POST /project/:id endpoint
+func (api Project) Create(request *restful.Request, response *restful.Response) {
+	proj := new(brigade.Project)
+	if err := request.ReadEntity(proj); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "Project could not be read.")
+		return
+	}
+	id := brigade.ProjectID(request.PathParameter("id"))
+	if proj.Name == "" {
+		proj.Name = request.PathParameter("id")
+	}
+	// A project of another namespace than the home namespace may be created
+	// at its qualified name, which makes its ID unique across namespaces.
+	if proj.ID == "" && proj.Kubernetes.Namespace != "" && id == brigade.NamespacedProjectID(proj.Kubernetes.Namespace, proj.Name) {
+		proj.ID = id
+	}
+	if proj.ID == "" {
+		proj.ID = brigade.ProjectID(proj.Name)
+	}
+	if proj.ID != id {
+		response.WriteErrorString(http.StatusBadRequest, "Project ID does not match the request path.")
+		return
+	}
+	if !authorized(api.authz, request, response, id, VerbWrite) {
+		return
+	}
+	if err := brigade.ValidateBuildTimeout(proj.BuildTimeout); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	err := api.store.CreateProject(proj)
+	if err == storage.ErrProjectExists {
+		response.WriteErrorString(http.StatusConflict, "Project already exists.")
+		return
+	}
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Project could not be created.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, proj)
+}
+
+// Replace creates a new gin handler for the PUT /project/:id endpoint
+func (api Project) Replace(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	existing, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	if !authorized(api.authz, request, response, existing.ID, VerbWrite) {
+		return
+	}
+	proj := new(brigade.Project)
+	if err := request.ReadEntity(proj); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "Project could not be read.")
+		return
+	}
+	if proj.ID != "" && proj.ID != existing.ID {
+		response.WriteErrorString(http.StatusBadRequest, "Project ID does not match the request path.")
+		return
+	}
+	proj.ID = existing.ID
+	if proj.Name == "" {
+		proj.Name = existing.Name
+	}
+	if err := brigade.ValidateBuildTimeout(proj.BuildTimeout); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := api.store.ReplaceProject(proj); err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Project could not be replaced.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, proj)
+}
+
+// Delete creates a new gin handler for the DELETE /project/:id endpoint
+func (api Project) Delete(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	proj, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	if !authorized(api.authz, request, response, proj.ID, VerbWrite) {
+		return
+	}
+	if err := api.store.DeleteProject(proj.ID); err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Project could not be deleted.")
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// CreateBuild creates a new gin handler for the POST /project/:id/builds endpoint
+func (api Project) CreateBuild(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	proj, err := api.store.GetProject(id)
+	if err != nil {
+		response.WriteErrorString(http.StatusNotFound, "No Project found.")
+		return
+	}
+	if !authorized(api.authz, request, response, proj.ID, VerbWrite) {
+		return
+	}
+	build := new(brigade.Build)
+	if err := request.ReadEntity(build); err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "Build could not be read.")
+		return
+	}
+	if build.ProjectID != "" && build.ProjectID != proj.ID {
+		response.WriteErrorString(http.StatusBadRequest, "Build project ID does not match the request path.")
+		return
+	}
+	build.ProjectID = proj.ID
+	// The build ID is always assigned by storage and a worker never exists
+	// for a build that is yet to be created.
+	build.ID = ""
+	build.Worker = nil
+	if build.Revision == nil {
+		build.Revision = &brigade.Revision{}
+	}
+	if err := api.store.CreateBuild(build); err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "Build could not be created.")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, build)
 }
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	restful "github.com/emicklei/go-restful"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage/mock"
 )
 
@@ -21,3 +27,205 @@ func TestGetBuildSummariesForProjects(t *testing.T) {
 		t.Fatal("wrong BuildID in getBuildSummariesForProjects")
 	}
 }
+
+func TestProjectCreateReplaceDelete(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	// Create
+	rw := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "org/repo"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "org/repo"
+	respo := restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Create(req, respo)
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rw.Code)
+	}
+	if len(store.ProjectList) != 2 {
+		t.Fatal("project was not created")
+	}
+	id := brigade.ProjectID("org/repo")
+	if store.ProjectList[1].ID != id {
+		t.Errorf("expected project ID %q, got %q", id, store.ProjectList[1].ID)
+	}
+
+	// Create a project that already exists
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "org/repo"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "org/repo"
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Create(req, respo)
+	if rw.Code != http.StatusConflict {
+		t.Fatalf("expected %d, got %d", http.StatusConflict, rw.Code)
+	}
+	if len(store.ProjectList) != 2 {
+		t.Fatal("project was created twice")
+	}
+
+	// Create with a mismatched ID
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "other/repo"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "org/repo"
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Create(req, respo)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Create a project of another namespace at its qualified name
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "org/repo", "kubernetes": {"namespace": "team"}}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "team/org/repo"
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Create(req, respo)
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rw.Code)
+	}
+	if qualified := brigade.NamespacedProjectID("team", "org/repo"); store.ProjectList[2].ID != qualified {
+		t.Errorf("expected project ID %q, got %q", qualified, store.ProjectList[2].ID)
+	}
+	store.ProjectList = store.ProjectList[:2]
+
+	// Create with an invalid build timeout
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "org/other", "buildTimeout": "an hour"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = "org/other"
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Create(req, respo)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Replace with an invalid build timeout
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("PUT", "/", strings.NewReader(`{"buildTimeout": "-1h"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = id
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Replace(req, respo)
+	if rw.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Replace
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("PUT", "/", strings.NewReader(`{"defaultScript": "console.log('hi')"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = id
+	respo = restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Replace(req, respo)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rw.Code)
+	}
+	if p := store.ProjectList[1]; p.DefaultScript != "console.log('hi')" || p.Name != "org/repo" {
+		t.Errorf("project was not replaced: %+v", p)
+	}
+
+	// Delete
+	rw = httptest.NewRecorder()
+	httpRequest = httptest.NewRequest("DELETE", "/", nil)
+	req = restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = id
+	respo = restful.NewResponse(rw)
+
+	mockAPI.Project().Delete(req, respo)
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d", http.StatusNoContent, rw.Code)
+	}
+	if len(store.ProjectList) != 1 {
+		t.Fatal("project was not deleted")
+	}
+
+	// Delete a project that does not exist
+	rw = httptest.NewRecorder()
+	mockAPI.Project().Delete(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d", http.StatusNotFound, rw.Code)
+	}
+}
+
+func TestProjectCreateBuild(t *testing.T) {
+	store := mock.New()
+	mockAPI := New(store)
+
+	rw := httptest.NewRecorder()
+	httpRequest := httptest.NewRequest("POST", "/", strings.NewReader(`{"id": "ignored", "type": "exec", "provider": "api"}`))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+	req := restful.NewRequest(httpRequest)
+	req.PathParameters()["id"] = mock.StubProject.ID
+	respo := restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().CreateBuild(req, respo)
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("expected %d, got %d", http.StatusCreated, rw.Code)
+	}
+	if len(store.Builds) != 3 {
+		t.Fatal("build was not created")
+	}
+	b := store.Builds[2]
+	if b.ProjectID != mock.StubProject.ID {
+		t.Errorf("expected project ID %q, got %q", mock.StubProject.ID, b.ProjectID)
+	}
+	if b.ID != "" {
+		t.Errorf("expected build ID to be assigned by storage, got %q", b.ID)
+	}
+	if b.Revision == nil {
+		t.Error("expected an empty revision to be set")
+	}
+}
+
+func TestProjectBuildsPaged(t *testing.T) {
+	mockAPI := New(mock.New())
+
+	rw := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest("GET", "/?limit=1", nil))
+	req.PathParameters()["id"] = mock.StubProject.ID
+	respo := restful.NewResponse(rw)
+	respo.SetRequestAccepts(restful.MIME_JSON)
+
+	mockAPI.Project().Builds(req, respo)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, rw.Code)
+	}
+	if rw.Header().Get(ContinueHeader) == "" {
+		t.Error("expected a continue token")
+	}
+	if !strings.Contains(rw.Body.String(), mock.StubBuild1.ID) || strings.Contains(rw.Body.String(), mock.StubBuild2.ID) {
+		t.Errorf("expected only the newest build, got %s", rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/?since=yesterday", nil))
+	req.PathParameters()["id"] = mock.StubProject.ID
+	mockAPI.Project().Builds(req, restful.NewResponse(rw))
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, rw.Code)
+	}
+}
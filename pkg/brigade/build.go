@@ -1,5 +1,7 @@
 package brigade
 
+import "time"
+
 // Build represents an invocation of an event in Brigade.
 //
 // Each build has a unique ID, and is tied to a project, as well as an event type.
@@ -45,6 +47,82 @@ type Build struct {
 	// LogLevel determines what level of logging from the Javascript
 	// to print to console.
 	LogLevel string `json:"log_level,omitempty"`
+	// Priority is the name of the Kubernetes PriorityClass of the worker. If
+	// empty, the default priority of the project is used.
+	Priority string `json:"priority,omitempty" yaml:",omitempty"`
+	// QueuePosition is the position of a queued build in the queue of builds
+	// waiting for a worker, starting at 1. It is 0 if the build is not queued.
+	QueuePosition int `json:"queue_position,omitempty" yaml:"queuePosition,omitempty"`
+	// SupersededBy is the ID of the newer build for the same ref that
+	// cancelled this build.
+	SupersededBy string `json:"superseded_by,omitempty" yaml:",omitempty"`
+	// Supersedes are the IDs of the older builds for the same ref that this
+	// build cancelled.
+	Supersedes []string `json:"supersedes,omitempty" yaml:",omitempty"`
+	// Attempt is the attempt number of the build. It is 1 for builds that do
+	// not retry another build.
+	Attempt int `json:"attempt,omitempty" yaml:",omitempty"`
+	// ParentBuildID is the ID of the build that this build reruns.
+	ParentBuildID string `json:"parent_build_id,omitempty" yaml:",omitempty"`
+	// RerunReason is why the build reruns its parent build, such as
+	// RerunRetry. It is empty if the build has no parent build.
+	RerunReason string `json:"rerun_reason,omitempty" yaml:",omitempty"`
+	// RetriedBy is the ID of the build that retries this build.
+	RetriedBy string `json:"retried_by,omitempty" yaml:",omitempty"`
+	// NotBefore is the earliest time that the worker of a retried build
+	// starts, after the backoff of the retry policy of its project.
+	NotBefore *time.Time `json:"not_before,omitempty" yaml:",omitempty"`
+	// Traceparent is the W3C traceparent of the trace of the build, started by
+	// whatever created the build. It is empty if the build is not traced.
+	Traceparent string `json:"traceparent,omitempty" yaml:",omitempty"`
+	// Conditions are what the controller observed while starting the worker of
+	// the build, such as why it failed to.
+	Conditions []BuildCondition `json:"conditions,omitempty" yaml:",omitempty"`
+}
+
+// The reasons that a build reruns its parent build for.
+const (
+	// RerunManual is the reason of builds that were rerun by a user, such as
+	// with brig rerun.
+	RerunManual = "manual"
+	// RerunRetry is the reason of builds that retry a failed build by the
+	// retry policy of its project.
+	RerunRetry = "retry"
+)
+
+// BuildConditionType is the type of a condition of a build.
+type BuildConditionType string
+
+const (
+	// BuildAccepted is true once the controller found the project of a build
+	// and admitted or queued it.
+	BuildAccepted BuildConditionType = "Accepted"
+	// BuildWorkerCreated is true once the worker pod of a build was created.
+	BuildWorkerCreated BuildConditionType = "WorkerCreated"
+	// BuildFailed is true if the controller failed to start the worker of a
+	// build. Its reason and message say why.
+	BuildFailed BuildConditionType = "Failed"
+)
+
+// Statuses of build conditions.
+const (
+	ConditionTrue  = "True"
+	ConditionFalse = "False"
+)
+
+// BuildCondition is an observation of the controller about a build, like the
+// conditions of Kubernetes objects.
+type BuildCondition struct {
+	// Type is the kind of the condition.
+	Type BuildConditionType `json:"type"`
+	// Status is either True or False.
+	Status string `json:"status"`
+	// Reason is a CamelCase reason for the last transition of the condition.
+	Reason string `json:"reason,omitempty" yaml:",omitempty"`
+	// Message explains the last transition of the condition to humans.
+	Message string `json:"message,omitempty" yaml:",omitempty"`
+	// LastTransitionTime is when the status of the condition last changed.
+	LastTransitionTime time.Time `json:"last_transition_time"`
 }
 
 // Revision describes a vcs revision.
@@ -0,0 +1,38 @@
+package brigade
+
+import "time"
+
+// BuildEventType is the kind of change a BuildEvent describes.
+type BuildEventType string
+
+// These are the valid types of build events.
+const (
+	// BuildCreated means that a new build was submitted.
+	BuildCreated BuildEventType = "build_created"
+	// WorkerPhaseChanged means that the worker of a build changed status.
+	WorkerPhaseChanged BuildEventType = "worker_phase_changed"
+	// JobPhaseChanged means that a job of a build changed status.
+	JobPhaseChanged BuildEventType = "job_phase_changed"
+)
+
+// BuildEvent describes a change in the state of a build, its worker or one of its jobs.
+type BuildEvent struct {
+	// ID is the resource version of the Kubernetes object whose change the
+	// event describes. It increases with every change, including across
+	// restarts of the API server, and can be used to resume a stream of events.
+	ID uint64 `json:"id"`
+	// Type is the kind of change.
+	Type BuildEventType `json:"type"`
+	// Time is when the change was observed.
+	Time time.Time `json:"time"`
+	// ProjectID is the computed name of the project (brigade-aeff2343a3234ff)
+	ProjectID string `json:"project_id"`
+	// BuildID is the ID of the build which changed.
+	BuildID string `json:"build_id"`
+	// Build is set for BuildCreated events. The script and payload are omitted.
+	Build *Build `json:"build,omitempty"`
+	// Worker is set for WorkerPhaseChanged events.
+	Worker *Worker `json:"worker,omitempty"`
+	// Job is set for JobPhaseChanged events.
+	Job *Job `json:"job,omitempty"`
+}
@@ -17,6 +17,9 @@ const (
 	// has not been started. This includes time before being bound to a node, as well as time spent
 	// pulling images onto the host.
 	JobPending JobStatus = "Pending"
+	// JobQueued means the build of the job is waiting for other builds to finish, because its
+	// project or the system is running as many builds as it may run concurrently.
+	JobQueued JobStatus = "Queued"
 	// JobRunning means the job has been bound to a node and all of the containers have been started.
 	// At least one container is still running or is in the process of being restarted.
 	JobRunning JobStatus = "Running"
@@ -26,6 +29,15 @@ const (
 	// JobFailed means that all containers in the job have terminated, and at least one container has
 	// terminated in a failure (exited with a non-zero exit code or was stopped by the system).
 	JobFailed JobStatus = "Failed"
+	// JobCancelled means that the build of the job was cancelled, and its containers were asked
+	// to terminate before they completed.
+	JobCancelled JobStatus = "Cancelled"
+	// JobTimedOut means that the build of the job ran for longer than its timeout, and its
+	// containers were asked to terminate before they completed.
+	JobTimedOut JobStatus = "TimedOut"
+	// JobSuperseded means that the build of the job was cancelled, because a newer build for the
+	// same ref was started.
+	JobSuperseded JobStatus = "Superseded"
 	// JobUnknown means that for some reason the state of the job could not be obtained, typically due
 	// to an error in communicating with the host of the job.
 	JobUnknown JobStatus = "Unknown"
@@ -37,6 +49,10 @@ type Job struct {
 	ID string `json:"id"`
 	// Name is the name for the job
 	Name string `json:"name"`
+	// BuildID is the ID of the build this job belongs to.
+	BuildID string `json:"build_id"`
+	// ProjectID is the computed name of the project (brigade-aeff2343a3234ff)
+	ProjectID string `json:"project_id"`
 	// Image is the execution environment running the job
 	Image string `json:"image"`
 	// CreationTime is a timestamp representing the server time when this object was
@@ -52,4 +68,10 @@ type Job struct {
 	ExitCode int32 `json:"exit_code"`
 	// Status is a textual representation of the job's running status
 	Status JobStatus `json:"status"`
+	// Reason is why the job is not running as expected, such as
+	// ImagePullBackOff, Unschedulable or OOMKilled. It is empty if nothing is
+	// wrong with it.
+	Reason string `json:"reason,omitempty" yaml:",omitempty"`
+	// Message explains Reason to humans.
+	Message string `json:"message,omitempty" yaml:",omitempty"`
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Project describes a Brigade project
@@ -54,6 +55,42 @@ type Project struct {
 	// globally configured command) usually issued.
 	WorkerCommand string `json:"workerCommand"`
 
+	// BuildTimeout is the maximum duration of a build, such as "1h30m". Builds
+	// whose worker runs for longer are terminated and marked as timed out. If
+	// empty, the controller's build timeout is used.
+	BuildTimeout string `json:"buildTimeout"`
+
+	// MaxConcurrentBuilds is the maximum number of builds of the project that
+	// run at the same time. Further builds are queued until running builds
+	// finish. 0 means no limit.
+	MaxConcurrentBuilds int `json:"maxConcurrentBuilds"`
+
+	// SupersedeBuilds cancels the pending and running builds for a ref when a
+	// newer build for the same ref is started.
+	SupersedeBuilds bool `json:"supersedeBuilds"`
+
+	// DefaultPriority is the name of the Kubernetes PriorityClass of the
+	// workers of builds that do not set their own priority.
+	DefaultPriority string `json:"defaultPriority"`
+
+	// AllowedPriorities is a comma-separated list of the names of the
+	// Kubernetes PriorityClasses that builds may set, such as through the
+	// priority of a webhook request. Builds that set any other priority get the
+	// default priority.
+	AllowedPriorities string `json:"allowedPriorities"`
+
+	// SchedulingWeight is the share of the queued builds of the project that
+	// are started, relative to the weights of other projects. 0 means 1.
+	SchedulingWeight int `json:"schedulingWeight"`
+
+	// Retry is the policy that the controller retries the failed builds of
+	// the project with.
+	Retry RetryPolicy `json:"retry"`
+
+	// Schedules are the cron schedules that brigade-cron-gateway creates
+	// builds of the project at.
+	Schedules []Schedule `json:"schedules"`
+
 	// BrigadejsPath contains the path for the Brigade.js file in the source repo
 	BrigadejsPath string `json:"brigadejsPath"`
 
@@ -80,6 +117,19 @@ func (s SecretsMap) MarshalJSON() ([]byte, error) {
 	return json.Marshal(dest)
 }
 
+// ValidateBuildTimeout returns an error if a build timeout is not empty and
+// not a duration such as "1h30m". A timeout of zero means builds do not time
+// out.
+func ValidateBuildTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d < 0 {
+		return fmt.Errorf("invalid build timeout %q, expected a duration such as 1h30m", timeout)
+	}
+	return nil
+}
+
 // ProjectID will encode a project name.
 func ProjectID(id string) string {
 	if strings.HasPrefix(id, "brigade-") {
@@ -88,6 +138,15 @@ func ProjectID(id string) string {
 	return "brigade-" + shortSHA(id)
 }
 
+// NamespacedProjectID returns the ID of a project named name that lives in a
+// Kubernetes namespace other than the home namespace of Brigade. It is the ID
+// of the name "<namespace>/<name>", so projects of the same name in different
+// namespaces have different IDs, and "<namespace>/<name>" can be used in
+// place of the ID like the name of a project of the home namespace can.
+func NamespacedProjectID(namespace, name string) string {
+	return ProjectID(namespace + "/" + name)
+}
+
 // shortSHA returns a 32-char SHA256 digest as a string.
 func shortSHA(input string) string {
 	sum := sha256.Sum256([]byte(input))
@@ -105,6 +164,12 @@ type Github struct {
 	// UploadURL is the upload URL to be used for GitHub enterprise.
 	// Typically, it is the same as the BaseURL.
 	UploadURL string `json:"uploadURL"`
+	// BuildUntrusted builds pull requests from forks, and comments, of authors
+	// who are not owners, members or collaborators of the repository. Their
+	// builds run scripts or payloads those authors control with the secrets
+	// of the project. The GitLab and Bitbucket gateways apply it to merge and
+	// pull requests from forks outside the namespace of the repository.
+	BuildUntrusted bool `json:"buildUntrusted"`
 }
 
 // Repo describes a Git repository.
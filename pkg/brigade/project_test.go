@@ -81,3 +81,16 @@ func TestProjectWorkerConfig(t *testing.T) {
 		t.Errorf("unexpected Project.Worker.PullPolicy: %s != Always", got.Worker.PullPolicy)
 	}
 }
+
+func TestValidateBuildTimeout(t *testing.T) {
+	for _, timeout := range []string{"", "0", "90s", "1h30m"} {
+		if err := ValidateBuildTimeout(timeout); err != nil {
+			t.Errorf("expected %q to be valid, got %s", timeout, err)
+		}
+	}
+	for _, timeout := range []string{"1 hour", "90", "-1h"} {
+		if err := ValidateBuildTimeout(timeout); err == nil {
+			t.Errorf("expected %q to be invalid", timeout)
+		}
+	}
+}
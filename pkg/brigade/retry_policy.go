@@ -0,0 +1,65 @@
+package brigade
+
+import "time"
+
+// maxRetryDelay is the longest that a retried build waits before it starts.
+const maxRetryDelay = time.Hour
+
+// RetryPolicy describes when and how the failed builds of a project are
+// retried. A retried build is a new build with the same event, whose
+// ParentBuildID is the ID of the build it retries.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a build runs at most, counting the
+	// first attempt. 0 and 1 mean builds are not retried.
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff is how long the first retry of a build waits before it starts,
+	// such as "30s". Further retries wait twice as long as the one before, up
+	// to an hour. If empty, or not a valid duration, retries start right away.
+	Backoff string `json:"backoff"`
+	// ExitCodes are the exit codes of the worker that a build is retried for.
+	ExitCodes []int32 `json:"exitCodes"`
+	// Reasons are the reasons of failed workers that a build is retried for,
+	// such as Evicted or OOMKilled.
+	//
+	// If neither ExitCodes nor Reasons are given, builds are retried whatever
+	// their worker failed for.
+	Reasons []string `json:"reasons"`
+}
+
+// Retries returns true if a build whose worker failed with an exit code and
+// reason is retried after the given attempt, which starts at 1.
+func (p RetryPolicy) Retries(attempt int, exitCode int32, reason string) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	if len(p.ExitCodes) == 0 && len(p.Reasons) == 0 {
+		return true
+	}
+	for _, c := range p.ExitCodes {
+		if c == exitCode {
+			return true
+		}
+	}
+	for _, r := range p.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns how long the retry of a build waits after the given attempt
+// before it starts.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	backoff, err := time.ParseDuration(p.Backoff)
+	if err != nil || backoff <= 0 || attempt < 1 {
+		return 0
+	}
+	for i := 1; i < attempt && backoff < maxRetryDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return backoff
+}
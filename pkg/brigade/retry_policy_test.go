@@ -0,0 +1,49 @@
+package brigade
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		exitCode int32
+		reason   string
+		expect   bool
+	}{
+		{"no policy", RetryPolicy{}, 1, 1, "", false},
+		{"any failure", RetryPolicy{MaxAttempts: 3}, 2, 1, "", true},
+		{"attempts exhausted", RetryPolicy{MaxAttempts: 3}, 3, 1, "", false},
+		{"exit code", RetryPolicy{MaxAttempts: 2, ExitCodes: []int32{137}}, 1, 137, "", true},
+		{"other exit code", RetryPolicy{MaxAttempts: 2, ExitCodes: []int32{137}}, 1, 1, "", false},
+		{"reason", RetryPolicy{MaxAttempts: 2, ExitCodes: []int32{137}, Reasons: []string{"Evicted"}}, 1, 0, "Evicted", true},
+		{"other reason", RetryPolicy{MaxAttempts: 2, Reasons: []string{"Evicted"}}, 1, 1, "OOMKilled", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Retries(tt.attempt, tt.exitCode, tt.reason); got != tt.expect {
+				t.Errorf("expected %t, got %t", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Backoff: "30s"}
+	for attempt, expect := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		10: time.Hour,
+	} {
+		if got := p.Delay(attempt); got != expect {
+			t.Errorf("expected a delay of %s after attempt %d, got %s", expect, attempt, got)
+		}
+	}
+	if got := (RetryPolicy{Backoff: "soon"}).Delay(1); got != 0 {
+		t.Errorf("expected no delay for an invalid backoff, got %s", got)
+	}
+}
@@ -0,0 +1,83 @@
+package brigade
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ConcurrencyPolicy is what a schedule does when it is due while a build it
+// started earlier has not finished yet.
+type ConcurrencyPolicy string
+
+// These are the valid concurrency policies of schedules.
+const (
+	// ConcurrencyAllow starts a new build alongside the unfinished ones.
+	ConcurrencyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyForbid skips the new build.
+	ConcurrencyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyReplace cancels the unfinished builds and starts a new one.
+	ConcurrencyReplace ConcurrencyPolicy = "Replace"
+)
+
+// scheduleName matches valid schedule names.
+var scheduleName = regexp.MustCompile(`^[a-zA-Z0-9][-._a-zA-Z0-9]*$`)
+
+// ScheduleProvider is the provider of the builds created by schedules.
+const ScheduleProvider = "cron"
+
+// Schedule describes builds of a project that brigade-cron-gateway creates at
+// the times of a cron expression.
+type Schedule struct {
+	// Name identifies the schedule in its project. It may only contain
+	// letters, digits, '-', '_' and '.'. It is the short title of the builds
+	// of the schedule.
+	Name string `json:"name"`
+	// Cron is the standard cron expression of the times the schedule is due,
+	// such as "0 3 * * *" or "@hourly". Times are in UTC.
+	Cron string `json:"cron"`
+	// EventType is the event type of the builds of the schedule. It defaults
+	// to "cron".
+	EventType string `json:"eventType,omitempty"`
+	// Payload is the payload of the builds of the schedule.
+	Payload string `json:"payload,omitempty"`
+	// Ref is the VCS ref that the builds of the schedule check out, such as
+	// refs/heads/master.
+	Ref string `json:"ref,omitempty"`
+	// ConcurrencyPolicy is what the schedule does if an earlier build of it
+	// has not finished yet. It defaults to Allow.
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// StartingDeadline is how late a build of the schedule may start, such as
+	// "10m", when the gateway could not create it on time. Builds that are
+	// later are skipped. If empty, a missed build always starts when the
+	// gateway catches up. Only the latest of several missed builds starts.
+	StartingDeadline string `json:"startingDeadline,omitempty"`
+}
+
+// Validate returns an error if the schedule is not valid.
+func (s Schedule) Validate() error {
+	if !scheduleName.MatchString(s.Name) {
+		return fmt.Errorf("invalid schedule name %q, names may only contain letters, digits, '-', '_' and '.'", s.Name)
+	}
+	if _, err := s.Spec(); err != nil {
+		return fmt.Errorf("invalid cron expression %q of schedule %s: %s", s.Cron, s.Name, err)
+	}
+	switch s.ConcurrencyPolicy {
+	case "", ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+	default:
+		return fmt.Errorf("invalid concurrency policy %q of schedule %s, expected one of %s, %s or %s", s.ConcurrencyPolicy, s.Name, ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace)
+	}
+	if s.StartingDeadline != "" {
+		if d, err := time.ParseDuration(s.StartingDeadline); err != nil || d <= 0 {
+			return fmt.Errorf("invalid starting deadline %q of schedule %s, expected a positive duration such as 10m", s.StartingDeadline, s.Name)
+		}
+	}
+	return nil
+}
+
+// Spec parses the cron expression of the schedule.
+func (s Schedule) Spec() (cron.Schedule, error) {
+	return cron.ParseStandard(s.Cron)
+}
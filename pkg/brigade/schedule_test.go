@@ -0,0 +1,32 @@
+package brigade
+
+import "testing"
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		valid    bool
+	}{
+		{"minimal", Schedule{Name: "nightly", Cron: "0 3 * * *"}, true},
+		{"descriptor", Schedule{Name: "hourly.check", Cron: "@hourly"}, true},
+		{"full", Schedule{Name: "weekly_1", Cron: "0 0 * * 0", ConcurrencyPolicy: ConcurrencyReplace, StartingDeadline: "30m"}, true},
+		{"no name", Schedule{Cron: "@hourly"}, false},
+		{"invalid name", Schedule{Name: "every hour", Cron: "@hourly"}, false},
+		{"invalid cron", Schedule{Name: "nightly", Cron: "0 3 * *"}, false},
+		{"invalid concurrency policy", Schedule{Name: "nightly", Cron: "@daily", ConcurrencyPolicy: "Sometimes"}, false},
+		{"invalid starting deadline", Schedule{Name: "nightly", Cron: "@daily", StartingDeadline: "soon"}, false},
+		{"negative starting deadline", Schedule{Name: "nightly", Cron: "@daily", StartingDeadline: "-1m"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expected schedule to be valid, got %s", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected schedule to be invalid")
+			}
+		})
+	}
+}
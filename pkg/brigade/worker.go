@@ -21,4 +21,10 @@ type Worker struct {
 	ExitCode int32 `json:"exit_code"`
 	// Status is a textual representation of the job's running status
 	Status JobStatus `json:"status"`
+	// Reason is why the worker is not running as expected, such as
+	// ImagePullBackOff, Unschedulable or OOMKilled. It is empty if nothing is
+	// wrong with it.
+	Reason string `json:"reason,omitempty" yaml:",omitempty"`
+	// Message explains Reason to humans.
+	Message string `json:"message,omitempty" yaml:",omitempty"`
 }
@@ -0,0 +1,113 @@
+package logarchive
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/api"
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+func TestAPIReadsArchivedLogsOfDeletedBuilds(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	kubeStore := kube.New(client, v1.NamespaceDefault)
+	b := &brigade.Build{ProjectID: "brigade-project1", Type: "push", Provider: "github", Revision: &brigade.Revision{}}
+	if err := kubeStore.CreateBuild(b); err != nil {
+		t.Fatal(err)
+	}
+
+	pod := func(name, component string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: v1.NamespaceDefault,
+				Labels: map[string]string{
+					"heritage":  "brigade",
+					"component": component,
+					"build":     b.ID,
+					"project":   b.ProjectID,
+					"jobname":   "test",
+				},
+			},
+			Spec:   v1.PodSpec{Containers: []v1.Container{{Name: component, Image: "alpine"}}},
+			Status: v1.PodStatus{Phase: v1.PodSucceeded, StartTime: &metav1.Time{}},
+		}
+	}
+	workerPod, jobPod := pod("brigade-worker-"+b.ID, "build"), pod("test-"+b.ID, "job")
+	pods := client.CoreV1().Pods(v1.NamespaceDefault)
+	for _, p := range []*v1.Pod{workerPod, jobPod} {
+		if _, err := pods.Create(context.TODO(), p, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Archive the logs the way the brigade-log-archiver does, then delete the pods.
+	sink := memorySink{}
+	index := NewIndex(sink)
+	worker, job := kube.NewWorkerFromPod(*workerPod), kube.NewJobFromPod(*jobPod)
+	if err := index.PutWorker(worker); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.PutJob(job); err != nil {
+		t.Fatal(err)
+	}
+	sink.Put(WorkerKey(worker, ""), strings.NewReader("2020-01-01T00:00:00Z worker log\n"))
+	sink.Put(JobKey(job), strings.NewReader("2020-01-01T00:00:00Z job log\n"))
+	for _, p := range []*v1.Pod{workerPod, jobPod} {
+		if err := pods.Delete(context.TODO(), p.Name, metav1.DeleteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Vacuuming a build deletes its secret too.
+	if err := client.CoreV1().Secrets(v1.NamespaceDefault).Delete(context.TODO(), "brigade-worker-"+b.ID, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake clientset cannot stream logs, which fails for deleted pods anyway.
+	a := api.New(NewStore(goneStore{kubeStore}, sink))
+	response := func(rw *httptest.ResponseRecorder) *restful.Response {
+		resp := restful.NewResponse(rw)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		return resp
+	}
+
+	rw := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest("GET", "/", nil))
+	req.PathParameters()["id"] = b.ID
+	a.Build().Logs(req, response(rw))
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), "worker log") {
+		t.Errorf("expected the archived worker log, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/", nil))
+	req.PathParameters()["id"] = b.ID
+	a.Build().MergedLogs(req, response(rw))
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), "worker log") || !strings.Contains(rw.Body.String(), "job log") {
+		t.Errorf("expected the archived worker and job logs, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/", nil))
+	req.PathParameters()["id"] = job.ID
+	a.Job().Logs(req, response(rw))
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), "job log") {
+		t.Errorf("expected the archived job log, got %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest("GET", "/", nil))
+	req.PathParameters()["id"] = b.ID
+	a.Build().Jobs(req, response(rw))
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), job.ID) {
+		t.Errorf("expected the archived job of the build, got %d: %s", rw.Code, rw.Body.String())
+	}
+}
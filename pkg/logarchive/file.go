@@ -0,0 +1,72 @@
+package logarchive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileSink archives logs as files in a directory.
+type fileSink struct {
+	dir string
+}
+
+// NewFileSink creates a Sink which stores logs as files below dir, which is
+// typically the mount point of a persistent volume.
+func NewFileSink(dir string) (Sink, error) {
+	if dir == "" {
+		return nil, errors.New("log archive directory is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileSink{dir: dir}, nil
+}
+
+func (s *fileSink) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", errors.New("invalid log archive key " + key)
+	}
+	return p, nil
+}
+
+// Put writes the log to a temporary file first, so that a log is never read
+// while it is only partly written.
+func (s *fileSink) Put(key string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *fileSink) Get(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
@@ -0,0 +1,40 @@
+package logarchive
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logarchive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink, err := NewFileSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sink.Get("project/build/pod.log"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := sink.Put("project/build/pod.log", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+	log, err := readAll(sink.Get("project/build/pod.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != "hello" {
+		t.Errorf("expected %q, got %q", "hello", log)
+	}
+
+	if err := sink.Put("../escape.log", strings.NewReader("nope")); err == nil {
+		t.Error("expected an error for a key outside the archive")
+	}
+}
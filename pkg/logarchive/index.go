@@ -0,0 +1,107 @@
+package logarchive
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"sync"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// Index records the workers and jobs whose logs are archived, so that they
+// can still be found once their pods are gone. It is kept in the Sink next to
+// the logs.
+type Index struct {
+	sink Sink
+	// mu serializes updates of the job lists of builds.
+	mu sync.Mutex
+}
+
+// NewIndex creates an Index kept in sink.
+func NewIndex(sink Sink) *Index {
+	return &Index{sink: sink}
+}
+
+func workerIndexKey(buildID string) string {
+	return path.Join("index", "builds", buildID, "worker.json")
+}
+
+func buildJobsIndexKey(buildID string) string {
+	return path.Join("index", "builds", buildID, "jobs.json")
+}
+
+func jobIndexKey(jobID string) string {
+	return path.Join("index", "jobs", jobID+".json")
+}
+
+// PutWorker records the worker of a build.
+func (i *Index) PutWorker(w *brigade.Worker) error {
+	return i.put(workerIndexKey(w.BuildID), w)
+}
+
+// PutJob records a job, and adds it to the jobs of its build.
+func (i *Index) PutJob(j *brigade.Job) error {
+	if err := i.put(jobIndexKey(j.ID), j); err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	jobs, err := i.BuildJobs(j.BuildID)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	replaced := false
+	for n, job := range jobs {
+		if job.ID == j.ID {
+			jobs[n], replaced = j, true
+		}
+	}
+	if !replaced {
+		jobs = append(jobs, j)
+	}
+	return i.put(buildJobsIndexKey(j.BuildID), jobs)
+}
+
+// Worker returns the recorded worker of a build. It returns ErrNotFound if
+// there is none.
+func (i *Index) Worker(buildID string) (*brigade.Worker, error) {
+	w := &brigade.Worker{}
+	return w, i.get(workerIndexKey(buildID), w)
+}
+
+// Job returns a recorded job. It returns ErrNotFound if there is none.
+func (i *Index) Job(id string) (*brigade.Job, error) {
+	j := &brigade.Job{}
+	return j, i.get(jobIndexKey(id), j)
+}
+
+// BuildJobs returns the recorded jobs of a build. It returns ErrNotFound if
+// there are none.
+func (i *Index) BuildJobs(buildID string) ([]*brigade.Job, error) {
+	jobs := []*brigade.Job{}
+	return jobs, i.get(buildJobsIndexKey(buildID), &jobs)
+}
+
+func (i *Index) put(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return i.sink.Put(key, bytes.NewReader(data))
+}
+
+func (i *Index) get(key string, v interface{}) error {
+	r, err := i.sink.Get(key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
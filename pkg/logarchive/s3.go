@@ -0,0 +1,203 @@
+package logarchive
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures a Sink storing logs in an S3-compatible object store,
+// such as Amazon S3 or MinIO.
+type S3Config struct {
+	// Endpoint is the URL of the object store. Buckets are addressed by path.
+	Endpoint string
+	// Region is the region of the bucket.
+	Region string
+	// Bucket is the name of the bucket. It must exist.
+	Bucket string
+	// Prefix is prepended to the key of every log.
+	Prefix string
+	// AccessKeyID and SecretAccessKey are the credentials requests are signed with.
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// s3Sink archives logs as objects in an S3 bucket.
+type s3Sink struct {
+	S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Sink creates a Sink which stores logs as objects in an S3 bucket.
+func NewS3Sink(c S3Config) (Sink, error) {
+	if c.Bucket == "" {
+		return nil, errors.New("log archive S3 bucket is required")
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return nil, errors.New("log archive S3 credentials are required")
+	}
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid log archive S3 endpoint %q", c.Endpoint)
+	}
+	return &s3Sink{
+		S3Config: c,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+func (s *s3Sink) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = "/" + path.Join(strings.TrimPrefix(u.Path, "/"), s.Bucket, s.Prefix, key)
+	return &u
+}
+
+// Put reads the whole log into memory, as S3 needs its length and checksum
+// before it is uploaded.
+func (s *s3Sink) Put(key string, r io.Reader) error {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key).String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := s.do(req, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Sink) Get(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do signs and sends a request. A response which is not successful is
+// returned as an error.
+func (s *s3Sink) do(req *http.Request, body []byte) (*http.Response, error) {
+	signV4(req, body, s.AccessKeyID, s.SecretAccessKey, s.Region, "s3", s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, bytes.TrimSpace(msg))
+}
+
+const (
+	amzDateFormat   = "20060102T150405Z"
+	amzShortDate    = "20060102"
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+)
+
+// signV4 signs a request with AWS Signature Version 4. The host and every
+// header already set on the request are signed.
+func signV4(req *http.Request, body []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	now = now.UTC()
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := strings.Join([]string{now.Format(amzShortDate), region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		now.Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{now.Format(amzShortDate), region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signV4Algorithm, accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalURI encodes every byte of a path except unreserved characters and slashes.
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	b := new(strings.Builder)
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
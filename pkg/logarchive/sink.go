@@ -0,0 +1,101 @@
+// Package logarchive keeps the logs of workers and jobs after their pods are gone.
+//
+// The brigade-log-archiver copies the logs of every worker and job pod which
+// has terminated into a Sink. A Store wrapped with NewStore reads logs from the
+// Sink when they cannot be read from the pod.
+package logarchive
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// ErrNotFound indicates that a log is not in the archive.
+var ErrNotFound = errors.New("log not found in archive")
+
+// Sink stores archived logs by key.
+type Sink interface {
+	// Put stores the log read from r under key, replacing any log stored before.
+	Put(key string, r io.Reader) error
+	// Get retrieves the log stored under key. It returns ErrNotFound if there is none.
+	Get(key string) (io.ReadCloser, error)
+}
+
+// Key returns the key under which the log of a container of a pod is archived.
+// The init container of a worker is the only container which is not named by
+// the pod alone.
+func Key(projectID, buildID, podName, container string) string {
+	name := podName
+	if container != "" {
+		name += "." + container
+	}
+	return path.Join(projectID, buildID, name+".log")
+}
+
+// WorkerKey returns the key under which the log of a container of a worker is
+// archived. The worker container is used if container is empty.
+func WorkerKey(w *brigade.Worker, container string) string {
+	return Key(w.ProjectID, w.BuildID, w.ID, container)
+}
+
+// JobKey returns the key under which the log of a job is archived.
+func JobKey(j *brigade.Job) string {
+	return Key(j.ProjectID, j.BuildID, j.ID, "")
+}
+
+// Sink types supported by NewSink.
+const (
+	SinkNone = "none"
+	SinkFile = "file"
+	SinkS3   = "s3"
+)
+
+// SinkConfig configures the Sink created by NewSink.
+type SinkConfig struct {
+	// Type is one of SinkNone, SinkFile or SinkS3.
+	Type string
+	// Dir is the directory of a file sink, such as the mount point of a persistent volume.
+	Dir string
+	// S3 configures an S3 sink.
+	S3 S3Config
+}
+
+// AddFlags adds flags for the sink configuration to fs. Their defaults are
+// read from the environment, and the S3 credentials can only be set there.
+func (c *SinkConfig) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Type, "log-archive", envOr("BRIGADE_LOG_ARCHIVE", SinkNone), "where logs are archived: none, file or s3")
+	fs.StringVar(&c.Dir, "log-archive-dir", os.Getenv("BRIGADE_LOG_ARCHIVE_DIR"), "directory of the log archive, used with --log-archive=file")
+	fs.StringVar(&c.S3.Endpoint, "log-archive-s3-endpoint", envOr("BRIGADE_LOG_ARCHIVE_S3_ENDPOINT", "https://s3.amazonaws.com"), "URL of the S3-compatible object store, used with --log-archive=s3")
+	fs.StringVar(&c.S3.Region, "log-archive-s3-region", envOr("BRIGADE_LOG_ARCHIVE_S3_REGION", "us-east-1"), "region of the S3 bucket")
+	fs.StringVar(&c.S3.Bucket, "log-archive-s3-bucket", os.Getenv("BRIGADE_LOG_ARCHIVE_S3_BUCKET"), "name of the S3 bucket")
+	fs.StringVar(&c.S3.Prefix, "log-archive-s3-prefix", os.Getenv("BRIGADE_LOG_ARCHIVE_S3_PREFIX"), "prefix of the keys of archived logs in the S3 bucket")
+	c.S3.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+	c.S3.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+}
+
+// NewSink creates the Sink described by c. It returns nil for SinkNone.
+func NewSink(c SinkConfig) (Sink, error) {
+	switch c.Type {
+	case SinkNone, "":
+		return nil, nil
+	case SinkFile:
+		return NewFileSink(c.Dir)
+	case SinkS3:
+		return NewS3Sink(c.S3)
+	default:
+		return nil, fmt.Errorf("unknown log archive %q", c.Type)
+	}
+}
+
+func envOr(name, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,203 @@
+package logarchive
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// store reads workers, jobs and their logs from a Sink when they cannot be
+// read from the wrapped Store.
+type store struct {
+	storage.Store
+	sink  Sink
+	index *Index
+}
+
+// NewStore wraps s so that workers, jobs and logs which can no longer be read
+// from s, because their pods have been deleted, are read from the archive
+// instead.
+//
+// Logs are archived with timestamps, which are removed unless requested.
+// TailLines and SinceSeconds are not applied to archived logs.
+func NewStore(s storage.Store, sink Sink) storage.Store {
+	return &store{Store: s, sink: sink, index: NewIndex(sink)}
+}
+
+// GetBuild falls back to the archived worker of a build whose worker pod is
+// gone. A build whose secret is gone too, such as after a vacuum, is made of
+// its archived worker only.
+func (s *store) GetBuild(id string) (*brigade.Build, error) {
+	b, err := s.Store.GetBuild(id)
+	if err == nil {
+		return b, nil
+	}
+	w, werr := s.index.Worker(id)
+	if werr != nil {
+		return b, err
+	}
+	if b == nil {
+		b = &brigade.Build{ID: id, ProjectID: w.ProjectID}
+	}
+	b.Worker = w
+	return b, nil
+}
+
+func (s *store) GetWorker(buildID string) (*brigade.Worker, error) {
+	w, err := s.Store.GetWorker(buildID)
+	if err == nil {
+		return w, nil
+	}
+	if w, werr := s.index.Worker(buildID); werr == nil {
+		return w, nil
+	}
+	return nil, err
+}
+
+func (s *store) GetJob(id string) (*brigade.Job, error) {
+	j, err := s.Store.GetJob(id)
+	if err == nil {
+		return j, nil
+	}
+	if j, jerr := s.index.Job(id); jerr == nil {
+		return j, nil
+	}
+	return nil, err
+}
+
+// GetBuildJobs adds the archived jobs of a build whose pods are gone.
+func (s *store) GetBuildJobs(build *brigade.Build) ([]*brigade.Job, error) {
+	jobs, err := s.Store.GetBuildJobs(build)
+	if err != nil {
+		return nil, err
+	}
+	archived, aerr := s.index.BuildJobs(build.ID)
+	if aerr != nil {
+		return jobs, nil
+	}
+	live := map[string]bool{}
+	for _, j := range jobs {
+		live[j.ID] = true
+	}
+	for _, j := range archived {
+		if !live[j.ID] {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+// archived returns the log stored under key, or err if there is none.
+func (s *store) archived(key string, timestamps bool, err error) (io.ReadCloser, error) {
+	r, aerr := s.sink.Get(key)
+	if aerr != nil {
+		return nil, err
+	}
+	if timestamps {
+		return r, nil
+	}
+	return stripTimestamps(r), nil
+}
+
+func (s *store) GetJobLog(job *brigade.Job) (string, error) {
+	log, err := s.Store.GetJobLog(job)
+	if err == nil {
+		return log, nil
+	}
+	return readAll(s.archived(JobKey(job), false, err))
+}
+
+func (s *store) GetJobLogStream(job *brigade.Job) (io.ReadCloser, error) {
+	return s.GetJobLogStreamWithOptions(job, storage.LogOptions{})
+}
+
+func (s *store) GetJobLogStreamFollow(job *brigade.Job) (io.ReadCloser, error) {
+	return s.GetJobLogStreamWithOptions(job, storage.LogOptions{Follow: true})
+}
+
+func (s *store) GetJobLogStreamWithOptions(job *brigade.Job, opts storage.LogOptions) (io.ReadCloser, error) {
+	r, err := s.Store.GetJobLogStreamWithOptions(job, opts)
+	if err == nil {
+		return r, nil
+	}
+	return s.archived(JobKey(job), opts.Timestamps, err)
+}
+
+func (s *store) GetWorkerLog(worker *brigade.Worker) (string, error) {
+	log, err := s.Store.GetWorkerLog(worker)
+	if err == nil {
+		return log, nil
+	}
+	return readAll(s.archived(WorkerKey(worker, ""), false, err))
+}
+
+func (s *store) GetWorkerInitLog(worker *brigade.Worker) (string, error) {
+	log, err := s.Store.GetWorkerInitLog(worker)
+	if err == nil {
+		return log, nil
+	}
+	return readAll(s.archived(WorkerKey(worker, storage.InitContainer), false, err))
+}
+
+func (s *store) GetWorkerLogStream(worker *brigade.Worker) (io.ReadCloser, error) {
+	return s.GetWorkerLogStreamWithOptions(worker, storage.LogOptions{})
+}
+
+func (s *store) GetWorkerLogStreamFollow(worker *brigade.Worker) (io.ReadCloser, error) {
+	return s.GetWorkerLogStreamWithOptions(worker, storage.LogOptions{Follow: true})
+}
+
+func (s *store) GetWorkerLogStreamWithOptions(worker *brigade.Worker, opts storage.LogOptions) (io.ReadCloser, error) {
+	r, err := s.Store.GetWorkerLogStreamWithOptions(worker, opts)
+	if err == nil {
+		return r, nil
+	}
+	if opts.Container != "" && opts.Container != storage.InitContainer {
+		return nil, err
+	}
+	return s.archived(WorkerKey(worker, opts.Container), opts.Timestamps, err)
+}
+
+func readAll(r io.ReadCloser, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	buf := new(bytes.Buffer)
+	_, err = io.Copy(buf, r)
+	return buf.String(), err
+}
+
+// stripTimestamps removes the RFC 3339 timestamp Kubernetes prefixes log lines with.
+func stripTimestamps(r io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer r.Close()
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if parts := strings.SplitN(line, " ", 2); len(parts) == 2 {
+				if _, perr := time.Parse(time.RFC3339Nano, parts[0]); perr == nil {
+					line = parts[1]
+				}
+			}
+			if _, werr := io.WriteString(pw, line); werr != nil {
+				return
+			}
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,94 @@
+package logarchive
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+var errPodGone = errors.New("pod not found")
+
+// goneStore is a store whose pods have all been deleted, so that none of
+// their logs can be read.
+type goneStore struct {
+	storage.Store
+}
+
+func (goneStore) GetJobLog(*brigade.Job) (string, error) { return "", errPodGone }
+func (goneStore) GetJobLogStreamWithOptions(*brigade.Job, storage.LogOptions) (io.ReadCloser, error) {
+	return nil, errPodGone
+}
+func (goneStore) GetWorkerLog(*brigade.Worker) (string, error) { return "", errPodGone }
+func (goneStore) GetWorkerLogStreamWithOptions(*brigade.Worker, storage.LogOptions) (io.ReadCloser, error) {
+	return nil, errPodGone
+}
+
+// memorySink is a Sink keeping logs in a map.
+type memorySink map[string]string
+
+func (s memorySink) Put(key string, r io.Reader) error {
+	log, err := readAll(ioutil.NopCloser(r), nil)
+	s[key] = log
+	return err
+}
+
+func (s memorySink) Get(key string) (io.ReadCloser, error) {
+	log, ok := s[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return ioutil.NopCloser(strings.NewReader(log)), nil
+}
+
+func TestStoreFallsBackToArchive(t *testing.T) {
+	sink := memorySink{
+		JobKey(mock.StubJob):            "2020-01-01T00:00:00Z job log\n",
+		WorkerKey(mock.StubWorker1, ""): "2020-01-01T00:00:00Z worker log\nno timestamp\n",
+	}
+	store := NewStore(goneStore{mock.New()}, sink)
+
+	log, err := store.GetJobLog(mock.StubJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != "job log\n" {
+		t.Errorf("expected the archived job log without timestamps, got %q", log)
+	}
+
+	log, err = store.GetWorkerLog(mock.StubWorker1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != "worker log\nno timestamp\n" {
+		t.Errorf("expected the archived worker log without timestamps, got %q", log)
+	}
+
+	log, err = readAll(store.GetWorkerLogStreamWithOptions(mock.StubWorker1, storage.LogOptions{Timestamps: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != sink[WorkerKey(mock.StubWorker1, "")] {
+		t.Errorf("expected the archived worker log with timestamps, got %q", log)
+	}
+
+	if _, err := store.GetWorkerLog(mock.StubWorker2); err != errPodGone {
+		t.Errorf("expected the error of the store for a log which is not archived, got %v", err)
+	}
+}
+
+func TestStorePrefersLiveLogs(t *testing.T) {
+	store := NewStore(mock.New(), memorySink{JobKey(mock.StubJob): "archived"})
+	log, err := store.GetJobLog(mock.StubJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log != mock.StubLogData {
+		t.Errorf("expected %q, got %q", mock.StubLogData, log)
+	}
+}
@@ -0,0 +1,49 @@
+package merge
+
+import "time"
+
+// Line is a timestamped line of text read from a named source.
+type Line struct {
+	Source string
+	Time   time.Time
+	Text   string
+}
+
+// Lines merges multiple channels of lines, each ordered by time, into one
+// channel ordered by time. Lines with equal times keep the order of the
+// channels they were read from.
+func Lines(chans ...<-chan Line) <-chan Line {
+	switch len(chans) {
+	case 0:
+		c := make(chan Line)
+		close(c)
+		return c
+	case 1:
+		return chans[0]
+	default:
+		m := len(chans) / 2
+		return mergeTwoLines(
+			Lines(chans[:m]...),
+			Lines(chans[m:]...))
+	}
+}
+
+func mergeTwoLines(a, b <-chan Line) <-chan Line {
+	c := make(chan Line)
+
+	go func() {
+		defer close(c)
+		va, oka := <-a
+		vb, okb := <-b
+		for oka || okb {
+			if oka && (!okb || !vb.Time.Before(va.Time)) {
+				c <- va
+				va, oka = <-a
+			} else {
+				c <- vb
+				vb, okb = <-b
+			}
+		}
+	}()
+	return c
+}
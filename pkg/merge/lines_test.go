@@ -0,0 +1,45 @@
+package merge
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func lineChan(source string, times ...int) <-chan Line {
+	c := make(chan Line, len(times))
+	for _, t := range times {
+		c <- Line{Source: source, Time: time.Unix(int64(t), 0)}
+	}
+	close(c)
+	return c
+}
+
+func TestMergeLines(t *testing.T) {
+	merged := Lines(
+		lineChan("a", 1, 4, 6),
+		lineChan("b", 2, 4),
+		lineChan("c"),
+		lineChan("d", 3, 7),
+	)
+
+	expect := []string{"a1", "b2", "d3", "a4", "b4", "a6", "d7"}
+	got := []string{}
+	for l := range merged {
+		got = append(got, fmt.Sprintf("%s%d", l.Source, l.Time.Unix()))
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Fatalf("expected %v, got %v", expect, got)
+		}
+	}
+}
+
+func TestMergeLinesNone(t *testing.T) {
+	if _, ok := <-Lines(); ok {
+		t.Fatal("merged chan expected to be closed")
+	}
+}
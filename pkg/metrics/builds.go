@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube"
+)
+
+// durationBuckets range from a second to a little over two hours, which
+// covers builds from a quick lint to a long integration suite.
+var durationBuckets = prometheus.ExponentialBuckets(1, 2, 14)
+
+var (
+	queueDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "brigade",
+		Subsystem: "build",
+		Name:      "queue_duration_seconds",
+		Help:      "Time from the creation of a build to the start of its worker, by project, event type and provider.",
+		Buckets:   durationBuckets,
+	}, []string{"project", "event_type", "provider"})
+	workerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "brigade",
+		Subsystem: "build",
+		Name:      "worker_duration_seconds",
+		Help:      "Time that workers ran for, by project, event type and provider.",
+		Buckets:   durationBuckets,
+	}, []string{"project", "event_type", "provider"})
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "brigade",
+		Subsystem: "build",
+		Name:      "job_duration_seconds",
+		Help:      "Time that jobs ran for, by project, event type, provider and job name.",
+		Buckets:   durationBuckets,
+	}, []string{"project", "event_type", "provider", "job"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDuration, workerDuration, jobDuration)
+}
+
+// buildInfo is what the duration metrics of a build are labelled with, and
+// when the build was created.
+type buildInfo struct {
+	created   time.Time
+	project   string
+	eventType string
+	provider  string
+}
+
+// buildDurationHandler observes the duration metrics of builds from changes
+// to build secrets and worker and job pods.
+type buildDurationHandler struct {
+	mu sync.Mutex
+	// builds are the build secrets seen so far, by build ID.
+	builds map[string]buildInfo
+	// since is the time before which pods that are added are ignored.
+	since time.Time
+}
+
+// NewBuildDurationHandler returns a handler for secret and pod informers which
+// observes how long builds wait for their worker to start, and how long their
+// worker and jobs run.
+//
+// A duration is observed when a pod starts or finishes, so that restarting
+// the handler does not observe the pods that already did again. Build
+// secrets that already exist are needed to label the pods of their builds,
+// so the handler should be registered before the informers start.
+func NewBuildDurationHandler() cache.ResourceEventHandler {
+	h := &buildDurationHandler{
+		builds: map[string]buildInfo{},
+		since:  time.Now().Truncate(time.Second),
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    h.onAdd,
+		UpdateFunc: h.onUpdate,
+		DeleteFunc: h.onDelete,
+	}
+}
+
+func (h *buildDurationHandler) onAdd(obj interface{}) {
+	switch o := obj.(type) {
+	case *v1.Secret:
+		h.buildChanged(o)
+	case *v1.Pod:
+		if o.CreationTimestamp.Time.Before(h.since) {
+			return
+		}
+		h.podChanged(nil, o)
+	}
+}
+
+func (h *buildDurationHandler) onUpdate(oldObj, newObj interface{}) {
+	switch o := newObj.(type) {
+	case *v1.Secret:
+		h.buildChanged(o)
+	case *v1.Pod:
+		if oldPod, ok := oldObj.(*v1.Pod); ok {
+			h.podChanged(oldPod, o)
+		}
+	}
+}
+
+func (h *buildDurationHandler) onDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if secret, ok := obj.(*v1.Secret); ok && isBuildSecret(secret) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.builds, secret.Labels["build"])
+	}
+}
+
+func (h *buildDurationHandler) buildChanged(secret *v1.Secret) {
+	if !isBuildSecret(secret) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.builds[secret.Labels["build"]] = buildInfo{
+		created:   secret.CreationTimestamp.Time,
+		project:   secret.Labels["project"],
+		eventType: string(secret.Data["event_type"]),
+		provider:  string(secret.Data["event_provider"]),
+	}
+}
+
+// build returns what the durations of a build are labelled with. Only the
+// project is known of a build whose secret has not been seen.
+func (h *buildDurationHandler) build(bid, project string) buildInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.builds[bid]
+	if !ok {
+		b.project = project
+	}
+	return b
+}
+
+// podChanged observes the durations that a worker or job pod reached since
+// its old state, which is nil for a pod that was just added.
+func (h *buildDurationHandler) podChanged(oldPod, newPod *v1.Pod) {
+	if newPod.Labels["heritage"] != "brigade" {
+		return
+	}
+	switch newPod.Labels["component"] {
+	case "build":
+		w := kube.NewWorkerFromPod(*newPod)
+		b := h.build(w.BuildID, w.ProjectID)
+		if !podStarted(oldPod) && !w.StartTime.IsZero() && !b.created.IsZero() {
+			queueDuration.WithLabelValues(b.project, b.eventType, b.provider).Observe(w.StartTime.Sub(b.created).Seconds())
+		}
+		if !podEnded(oldPod) && !w.EndTime.IsZero() && !w.StartTime.IsZero() {
+			workerDuration.WithLabelValues(b.project, b.eventType, b.provider).Observe(w.EndTime.Sub(w.StartTime).Seconds())
+		}
+	case "job":
+		if len(newPod.Spec.Containers) == 0 {
+			return
+		}
+		j := kube.NewJobFromPod(*newPod)
+		if !podEnded(oldPod) && !j.EndTime.IsZero() && !j.StartTime.IsZero() {
+			b := h.build(j.BuildID, j.ProjectID)
+			jobDuration.WithLabelValues(b.project, b.eventType, b.provider, j.Name).Observe(j.EndTime.Sub(j.StartTime).Seconds())
+		}
+	}
+}
+
+// podStarted returns true if a pod has a start time, as NewWorkerFromPod and
+// NewJobFromPod read it.
+func podStarted(pod *v1.Pod) bool {
+	return pod != nil && pod.Status.StartTime != nil && pod.Status.Phase != v1.PodPending && pod.Status.Phase != v1.PodUnknown
+}
+
+// podEnded returns true if the first container of a pod has terminated.
+func podEnded(pod *v1.Pod) bool {
+	return pod != nil && len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].State.Terminated != nil
+}
+
+func isBuildSecret(secret *v1.Secret) bool {
+	return secret.Labels["heritage"] == "brigade" && secret.Labels["component"] == "build"
+}
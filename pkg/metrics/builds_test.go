@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// observed returns the number and sum of the observations of a histogram.
+func observed(t *testing.T, o prometheus.Observer) (uint64, float64) {
+	m := &dto.Metric{}
+	if err := o.(prometheus.Histogram).Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.Histogram.GetSampleCount(), m.Histogram.GetSampleSum()
+}
+
+func TestBuildDurationHandler(t *testing.T) {
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	started := meta.NewTime(created.Add(30 * time.Second))
+	finished := meta.NewTime(created.Add(90 * time.Second))
+	labels := map[string]string{"heritage": "brigade", "project": "brigade-duration", "build": "01duration"}
+
+	build := &v1.Secret{
+		ObjectMeta: meta.ObjectMeta{
+			Name:              "brigade-worker-01duration",
+			CreationTimestamp: meta.NewTime(created),
+			Labels:            map[string]string{"heritage": "brigade", "component": "build", "project": "brigade-duration", "build": "01duration"},
+		},
+		Data: map[string][]byte{"event_type": []byte("push"), "event_provider": []byte("github")},
+	}
+	pending := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "brigade-worker-01duration", Labels: map[string]string{"component": "build"}},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	for k, v := range labels {
+		pending.Labels[k] = v
+	}
+	running := pending.DeepCopy()
+	running.Status.Phase = v1.PodRunning
+	running.Status.StartTime = &started
+	succeeded := running.DeepCopy()
+	succeeded.Status.Phase = v1.PodSucceeded
+	succeeded.Status.ContainerStatuses = []v1.ContainerStatus{{
+		State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{FinishedAt: finished}},
+	}}
+
+	job := &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "test-job-01duration", Labels: map[string]string{"component": "job", "jobname": "test"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Image: "alpine"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning, StartTime: &started},
+	}
+	for k, v := range labels {
+		job.Labels[k] = v
+	}
+	jobSucceeded := job.DeepCopy()
+	jobSucceeded.Status.Phase = v1.PodSucceeded
+	jobSucceeded.Status.ContainerStatuses = succeeded.Status.ContainerStatuses
+
+	h := NewBuildDurationHandler()
+	h.OnAdd(build)
+	// The pod was created before the handler, so only its changes count.
+	h.OnAdd(pending)
+	h.OnUpdate(pending, running)
+	h.OnUpdate(running, succeeded)
+	h.OnUpdate(succeeded, succeeded)
+	h.OnUpdate(job, jobSucceeded)
+	h.OnUpdate(jobSucceeded, jobSucceeded)
+
+	if n, sum := observed(t, queueDuration.WithLabelValues("brigade-duration", "push", "github")); n != 1 || sum != 30 {
+		t.Errorf("expected one queue duration of 30s, got %d totalling %vs", n, sum)
+	}
+	if n, sum := observed(t, workerDuration.WithLabelValues("brigade-duration", "push", "github")); n != 1 || sum != 60 {
+		t.Errorf("expected one worker duration of 60s, got %d totalling %vs", n, sum)
+	}
+	if n, sum := observed(t, jobDuration.WithLabelValues("brigade-duration", "push", "github", "test")); n != 1 || sum != 60 {
+		t.Errorf("expected one job duration of 60s, got %d totalling %vs", n, sum)
+	}
+}
@@ -0,0 +1,27 @@
+// Package metrics serves the Prometheus metrics of the Brigade services.
+//
+// Each service registers its own metrics with the default Prometheus
+// registry, next to the code they measure.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is the path of the metrics endpoint.
+const Path = "/metrics"
+
+// Handler returns the handler of the metrics endpoint.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ListenAndServe serves the metrics endpoint on addr, for services that have
+// no HTTP server of their own.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -13,13 +13,17 @@ import (
 	"time"
 
 	"github.com/Masterminds/kitt/progress"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube"
+	"github.com/brigadecore/brigade/pkg/tracing"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -53,11 +57,22 @@ type Runner struct {
 	NoProgress bool
 	Background bool
 	Verbose    bool
+	// Priority is the name of the PriorityClass of the builds sent.
+	Priority string
 }
 
 // SendBuild creates and runs a given Brigade build
 func (a *Runner) SendBuild(b *brigade.Build) error {
-	if err := a.store.CreateBuild(b); err != nil {
+	// The build starts a new trace, which the controller and worker continue.
+	ctx, span := tracing.Tracer().Start(context.Background(), "send build", trace.WithAttributes(
+		label.String("brigade.project", b.ProjectID),
+		label.String("brigade.event_type", b.Type),
+		label.String("brigade.provider", b.Provider),
+	))
+	b.Traceparent = tracing.Traceparent(ctx)
+	err := a.store.CreateBuild(b)
+	span.End()
+	if err != nil {
 		return err
 	}
 
@@ -81,14 +96,23 @@ func (a *Runner) SendBuild(b *brigade.Build) error {
 	// Now that everything is complete, get the pod status. If the pod failed, exit 1.
 	// Fortunately, the worker pod is marked "failed" if one of the jobs
 	// in the build fails and the error isn't handled with a .catch().
-	pod, err := a.kc.CoreV1().Pods(a.namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	worker, err := a.store.GetWorker(b.ID)
 	if err != nil {
 		return err
 	}
 
-	podPhase := pod.Status.Phase
-	if podPhase == v1.PodFailed || podPhase == v1.PodUnknown {
+	switch worker.Status {
+	case brigade.JobFailed, brigade.JobUnknown:
+		if worker.Reason != "" {
+			return NewBuildFailure("build failed: %s. (Build ID: %s)", reasonMessage(worker.Reason, worker.Message), b.ID)
+		}
 		return NewBuildFailure("build failed. (Build ID: %s)", b.ID)
+	case brigade.JobCancelled:
+		return NewBuildFailure("build was cancelled. (Build ID: %s)", b.ID)
+	case brigade.JobTimedOut:
+		return NewBuildFailure("build timed out. (Build ID: %s)", b.ID)
+	case brigade.JobSuperseded:
+		return NewBuildFailure("build was superseded by a newer build. (Build ID: %s)", b.ID)
 	}
 
 	return nil
@@ -116,26 +140,66 @@ func (a *Runner) SendScript(projectName string, data []byte, config []byte, even
 		Script:   data,
 		Config:   config,
 		LogLevel: logLevel,
+		Priority: a.Priority,
 	}
 	return a.SendBuild(b)
 }
 
-// waitForWorker waits until the worker has started.
+// workerStartFailures are the reasons that a worker pod gives for not
+// starting which are not resolved by waiting. A pod that is unschedulable
+// may still be scheduled once the cluster scales up, so the reason is only
+// reported if the worker does not start in time.
+var workerStartFailures = map[string]bool{
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"ErrImageNeverPull":          true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// workerStartTimeout is how long waitForWorker waits for the worker to start.
+var workerStartTimeout = 2 * time.Minute
+
+// waitForWorker waits until the worker has started. It fails as soon as the
+// worker pod or the build secret show that the worker will not start.
+//
+// Watches that the API server closes are established again.
 func (a *Runner) waitForWorker(buildID string) error {
 	opts := metav1.ListOptions{
 		LabelSelector: fmt.Sprintf("heritage=brigade,component=build,build=%s", buildID),
 	}
-	req, err := a.kc.CoreV1().Pods(a.namespace).Watch(context.TODO(), opts)
+	watchPods := func() (watch.Interface, error) {
+		return a.kc.CoreV1().Pods(a.namespace).Watch(context.TODO(), opts)
+	}
+	// The controller records why it could not create the worker on the build
+	// secret.
+	watchSecrets := func() (watch.Interface, error) {
+		return a.kc.CoreV1().Secrets(a.namespace).Watch(context.TODO(), opts)
+	}
+	podWatch, err := watchPods()
+	if err != nil {
+		return err
+	}
+	defer func() { podWatch.Stop() }()
+	secretWatch, err := watchSecrets()
 	if err != nil {
 		return err
 	}
-	res := req.ResultChan()
+	defer func() { secretWatch.Stop() }()
 
 	// Now we block until the Pod is ready
-	timeout := time.After(2 * time.Minute)
+	timeout := time.After(workerStartTimeout)
+	// reason is why the worker has not started yet, if it gave one.
+	reason := ""
 	for {
 		select {
-		case e := <-res:
+		case e, ok := <-podWatch.ResultChan():
+			if !ok {
+				if podWatch, err = watchPods(); err != nil {
+					return fmt.Errorf("could not watch the worker of build %s: %s", buildID, err)
+				}
+				continue
+			}
 			if a.Verbose {
 				d, _ := json.MarshalIndent(e.Object, "", "  ")
 				fmt.Fprintf(a.RunnerLogDestination, "Event: %s\n %s\n", e.Type, d)
@@ -143,29 +207,63 @@ func (a *Runner) waitForWorker(buildID string) error {
 			// If the pod is added or modified, check the phase and see if it is
 			// running or complete.
 			switch e.Type {
-			case "DELETED":
+			case watch.Deleted:
 				// This happens if a user directly kills the pod with kubectl.
 				return fmt.Errorf("worker %s was just deleted unexpectedly", buildID)
-			case "ADDED", "MODIFIED":
+			case watch.Added, watch.Modified:
 				pod := e.Object.(*v1.Pod)
+				worker := kube.NewWorkerFromPod(*pod)
 				switch pod.Status.Phase {
 				// Unhandled cases are Unknown and Pending, both of which should
 				// cause the loop to spin.
 				case "Running", "Succeeded":
-					req.Stop()
 					return nil
 				case "Failed":
-					req.Stop()
-					return fmt.Errorf("pod failed to schedule: %s", pod.Status.Reason)
+					return NewBuildFailure("worker failed to start: %s. (Build ID: %s)", reasonMessage(worker.Reason, worker.Message), buildID)
+				case "Pending":
+					if workerStartFailures[worker.Reason] {
+						return NewBuildFailure("worker cannot start: %s. (Build ID: %s)", reasonMessage(worker.Reason, worker.Message), buildID)
+					}
+					if worker.Reason != "" {
+						reason = reasonMessage(worker.Reason, worker.Message)
+					}
+				}
+			}
+		case e, ok := <-secretWatch.ResultChan():
+			if !ok {
+				if secretWatch, err = watchSecrets(); err != nil {
+					return fmt.Errorf("could not watch build %s: %s", buildID, err)
+				}
+				continue
+			}
+			if e.Type != watch.Added && e.Type != watch.Modified {
+				continue
+			}
+			for _, c := range kube.BuildConditions(*e.Object.(*v1.Secret)) {
+				if c.Type == brigade.BuildFailed && c.Status == brigade.ConditionTrue && c.Reason != kube.ReasonSyncFailed {
+					return NewBuildFailure("worker could not be created: %s. (Build ID: %s)", reasonMessage(c.Reason, c.Message), buildID)
 				}
 			}
 		case <-timeout:
-			req.Stop()
+			if reason != "" {
+				return fmt.Errorf("timeout waiting for build %s to start: %s", buildID, reason)
+			}
 			return fmt.Errorf("timeout waiting for build %s to start", buildID)
 		}
 	}
 }
 
+// reasonMessage joins a reason and the message that explains it.
+func reasonMessage(reason, message string) string {
+	switch {
+	case reason == "":
+		return "unknown reason"
+	case message == "":
+		return reason
+	}
+	return reason + ": " + message
+}
+
 func (a *Runner) podLog(name string, w io.Writer) error {
 	tailAllLines := int64(math.MaxInt64)
 	req := a.kc.CoreV1().Pods(a.namespace).GetLogs(name, &v1.PodLogOptions{
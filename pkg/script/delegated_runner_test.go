@@ -0,0 +1,114 @@
+package script
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newWatchedRunner returns a runner whose pod watches are the given fake
+// watchers, one per watch established.
+func newWatchedRunner(pods ...*watch.FakeWatcher) *Runner {
+	client := fake.NewSimpleClientset()
+	client.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+		w := pods[0]
+		pods = pods[1:]
+		return true, w, nil
+	})
+	return &Runner{
+		kc:                   client,
+		namespace:            v1.NamespaceDefault,
+		RunnerLogDestination: ioutil.Discard,
+	}
+}
+
+func workerPod(phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "brigade-worker-01", Namespace: v1.NamespaceDefault},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "brigade-runner"}}},
+		Status:     v1.PodStatus{Phase: phase, StartTime: &metav1.Time{}},
+	}
+}
+
+// wait runs waitForWorker until it returns or times out.
+func wait(t *testing.T, a *Runner) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- a.waitForWorker("01") }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected waitForWorker to return")
+		return nil
+	}
+}
+
+func TestWaitForWorker_ImagePullBackOff(t *testing.T) {
+	pods := watch.NewFakeWithChanSize(1, false)
+	a := newWatchedRunner(pods)
+
+	pod := workerPod(v1.PodPending)
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: "brigade-runner",
+		State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+			Reason:  "ImagePullBackOff",
+			Message: `Back-off pulling image "brigadecore/brigade-worker:nope"`,
+		}},
+	}}
+	pods.Add(pod)
+
+	err := wait(t, a)
+	if _, ok := err.(BuildFailure); !ok || !strings.Contains(err.Error(), "ImagePullBackOff") {
+		t.Errorf("expected the worker to fail to start with ImagePullBackOff, got %v", err)
+	}
+}
+
+func TestWaitForWorker_Unschedulable(t *testing.T) {
+	pods := watch.NewFakeWithChanSize(2, false)
+	a := newWatchedRunner(pods)
+
+	// An unschedulable worker may still start once the cluster scales up.
+	pod := workerPod(v1.PodPending)
+	pod.Status.Conditions = []v1.PodCondition{{
+		Type:    v1.PodScheduled,
+		Status:  v1.ConditionFalse,
+		Reason:  v1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 3 Insufficient cpu.",
+	}}
+	pods.Add(pod)
+	pods.Modify(workerPod(v1.PodRunning))
+
+	if err := wait(t, a); err != nil {
+		t.Errorf("expected the worker to start, got %v", err)
+	}
+
+	// Only a worker that stays unschedulable fails to start.
+	defer func(d time.Duration) { workerStartTimeout = d }(workerStartTimeout)
+	workerStartTimeout = 100 * time.Millisecond
+	pods = watch.NewFakeWithChanSize(1, false)
+	a = newWatchedRunner(pods)
+	pods.Add(pod)
+	if err := wait(t, a); err == nil || !strings.Contains(err.Error(), "Insufficient cpu") {
+		t.Errorf("expected a timeout explaining why the worker is unschedulable, got %v", err)
+	}
+}
+
+func TestWaitForWorker_ClosedWatch(t *testing.T) {
+	closed := watch.NewFake()
+	closed.Stop()
+	pods := watch.NewFakeWithChanSize(1, false)
+	a := newWatchedRunner(closed, pods)
+
+	pods.Add(workerPod(v1.PodRunning))
+	if err := wait(t, a); err != nil {
+		t.Errorf("expected the watch to be established again, got %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+// Package history records builds, workers and jobs in a SQL database, so that
+// they outlive the Kubernetes secrets and pods they are made of.
+//
+// The brigade-controller records every change to builds, workers and jobs with
+// DB.Record. The brigade-api wraps its Kubernetes store with NewStore to serve
+// the history from the database, while running builds are still read from
+// Kubernetes.
+//
+// The SQL is written for PostgreSQL and SQLite 3.24 or later, whose drivers
+// are both registered by this package. An embedded SQLite database only
+// needs a file path as data source name, such as /var/lib/brigade/history.db.
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	// Registers the postgres driver.
+	_ "github.com/lib/pq"
+	// Registers the sqlite driver, which is written in pure Go.
+	_ "modernc.org/sqlite"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// Drivers of the databases the history can be kept in.
+const (
+	PostgresDriver = "postgres"
+	SQLiteDriver   = "sqlite"
+)
+
+// DefaultDriver is the database/sql driver used unless another one is given.
+const DefaultDriver = PostgresDriver
+
+// schema creates the tables if they do not exist yet.
+//
+// brigade_reruns holds the build each rerun reruns, so that the rerun chain
+// of a build can be followed in both directions.
+//
+// Builds are stored as JSON, along with the columns they are filtered and
+// sorted by. sort_key is the start time of the worker in nanoseconds since the
+// epoch, or the largest BIGINT if the worker has not started, so that builds
+// which have not started are the newest.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS brigade_builds (
+		id VARCHAR(64) PRIMARY KEY,
+		project_id VARCHAR(128) NOT NULL,
+		type VARCHAR(256) NOT NULL DEFAULT '',
+		provider VARCHAR(256) NOT NULL DEFAULT '',
+		commit_sha VARCHAR(256) NOT NULL DEFAULT '',
+		ref VARCHAR(1024) NOT NULL DEFAULT '',
+		status VARCHAR(32) NOT NULL DEFAULT '',
+		start_time BIGINT NOT NULL DEFAULT 0,
+		sort_key BIGINT NOT NULL,
+		build TEXT NOT NULL,
+		worker TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS brigade_builds_project_sort ON brigade_builds (project_id, sort_key, id)`,
+	`CREATE INDEX IF NOT EXISTS brigade_builds_sort ON brigade_builds (sort_key, id)`,
+	`CREATE TABLE IF NOT EXISTS brigade_jobs (
+		id VARCHAR(253) PRIMARY KEY,
+		build_id VARCHAR(64) NOT NULL,
+		project_id VARCHAR(128) NOT NULL,
+		job TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS brigade_jobs_build ON brigade_jobs (build_id)`,
+	`CREATE TABLE IF NOT EXISTS brigade_reruns (
+		build_id VARCHAR(64) PRIMARY KEY,
+		parent_build_id VARCHAR(64) NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS brigade_reruns_parent ON brigade_reruns (parent_build_id)`,
+}
+
+// notStarted is the sort key of a build whose worker has not started.
+const notStarted = math.MaxInt64
+
+// DB is a database of build history.
+type DB struct {
+	db *sql.DB
+}
+
+// Open connects to a database with a database/sql driver and creates the
+// history tables if they do not exist.
+func Open(driver, dsn string) (*DB, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Record records the build, worker or job of an event. It is meant to be
+// used as the publish function of kube.NewBuildRecordHandler, so errors are
+// logged rather than returned.
+func (d *DB) Record(e *brigade.BuildEvent) {
+	var err error
+	switch {
+	case e.Build != nil:
+		err = d.RecordBuild(e.Build)
+	case e.Worker != nil:
+		err = d.RecordWorker(e.Worker)
+	case e.Job != nil:
+		err = d.RecordJob(e.Job)
+	}
+	if err != nil {
+		log.Printf("error recording %s of build %s: %s", e.Type, e.BuildID, err)
+	}
+}
+
+// RecordBuild inserts or updates a build. Its script and payload, which may
+// be large or hold secrets, are not recorded. Its worker is recorded if set.
+func (d *DB) RecordBuild(b *brigade.Build) error {
+	recorded := *b
+	recorded.Script = nil
+	recorded.Payload = nil
+	recorded.Worker = nil
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		return err
+	}
+	var commit, ref string
+	if b.Revision != nil {
+		commit, ref = b.Revision.Commit, b.Revision.Ref
+	}
+	_, err = d.db.Exec(`INSERT INTO brigade_builds (id, project_id, type, provider, commit_sha, ref, sort_key, build)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET
+			project_id = excluded.project_id, type = excluded.type, provider = excluded.provider,
+			commit_sha = excluded.commit_sha, ref = excluded.ref, build = excluded.build`,
+		b.ID, b.ProjectID, b.Type, b.Provider, commit, ref, int64(notStarted), string(data))
+	if err != nil {
+		return err
+	}
+	if b.ParentBuildID != "" {
+		_, err = d.db.Exec(`INSERT INTO brigade_reruns (build_id, parent_build_id) VALUES ($1, $2)
+			ON CONFLICT (build_id) DO UPDATE SET parent_build_id = excluded.parent_build_id`,
+			b.ID, b.ParentBuildID)
+	}
+	if err != nil || b.Worker == nil {
+		return err
+	}
+	return d.RecordWorker(b.Worker)
+}
+
+// RecordWorker inserts or updates the worker of a build. If the build has not
+// been recorded yet, a build with only its ID and project is recorded, and
+// RecordBuild later fills in the build and the columns it is filtered by.
+func (d *DB) RecordWorker(w *brigade.Worker) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	build, err := json.Marshal(&brigade.Build{ID: w.BuildID, ProjectID: w.ProjectID})
+	if err != nil {
+		return err
+	}
+	var startTime int64
+	sortKey := int64(notStarted)
+	if !w.StartTime.IsZero() {
+		startTime = w.StartTime.UnixNano()
+		sortKey = startTime
+	}
+	_, err = d.db.Exec(`INSERT INTO brigade_builds (id, project_id, status, start_time, sort_key, build, worker)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status, start_time = excluded.start_time,
+			sort_key = excluded.sort_key, worker = excluded.worker`,
+		w.BuildID, w.ProjectID, string(w.Status), startTime, sortKey, string(build), string(data))
+	return err
+}
+
+// RecordJob inserts or updates a job.
+func (d *DB) RecordJob(j *brigade.Job) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`INSERT INTO brigade_jobs (id, build_id, project_id, job)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET job = excluded.job`,
+		j.ID, j.BuildID, j.ProjectID, string(data))
+	return err
+}
+
+// GetBuild returns a recorded build, or sql.ErrNoRows if there is none.
+func (d *DB) GetBuild(id string) (*brigade.Build, error) {
+	row := d.db.QueryRow(`SELECT build, worker FROM brigade_builds WHERE id = $1`, id)
+	return scanBuild(row)
+}
+
+// ListBuilds returns a page of the recorded builds of a project, or of all
+// projects if projectID is empty. Builds are filtered, sorted and paginated
+// like storage.PageBuilds does.
+func (d *DB) ListBuilds(projectID string, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	query, args, err := listQuery(projectID, opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := &storage.BuildList{Items: []*brigade.Build{}}
+	for rows.Next() {
+		b, err := scanBuild(rows)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if opts.Limit > 0 && len(list.Items) > opts.Limit {
+		list.Items = list.Items[:opts.Limit]
+		list.Continue = storage.ContinueToken(list.Items[opts.Limit-1])
+	}
+	return list, nil
+}
+
+// GetBuildLineage returns the recorded rerun chain of a build: the oldest
+// recorded build of the builds it reruns, and all the recorded builds that
+// rerun that build, directly or not. Builds are sorted oldest first. It
+// returns sql.ErrNoRows if the build was not recorded.
+func (d *DB) GetBuildLineage(id string) ([]*brigade.Build, error) {
+	if _, err := d.GetBuild(id); err != nil {
+		return nil, err
+	}
+
+	// Follow the parents of the build up to the oldest recorded one.
+	root := id
+	seen := map[string]bool{id: true}
+	for {
+		var parent string
+		err := d.db.QueryRow(`SELECT parent_build_id FROM brigade_reruns WHERE build_id = $1`, root).Scan(&parent)
+		if err == sql.ErrNoRows || seen[parent] {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.GetBuild(parent); err == sql.ErrNoRows {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		seen[parent] = true
+		root = parent
+	}
+
+	// Collect the reruns of the oldest build, one build at a time.
+	builds := []*brigade.Build{}
+	seen = map[string]bool{root: true}
+	for pending := []string{root}; len(pending) > 0; {
+		bid := pending[0]
+		pending = pending[1:]
+		b, err := d.GetBuild(bid)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, b)
+
+		reruns, err := d.reruns(bid)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reruns {
+			if !seen[r] {
+				seen[r] = true
+				pending = append(pending, r)
+			}
+		}
+	}
+	// Build IDs are ULIDs, which sort in the order they were generated.
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	return builds, nil
+}
+
+// reruns returns the IDs of the recorded builds that rerun a build.
+func (d *DB) reruns(buildID string) ([]string, error) {
+	rows, err := d.db.Query(`SELECT build_id FROM brigade_reruns WHERE parent_build_id = $1`, buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetJob returns a recorded job, or sql.ErrNoRows if there is none.
+func (d *DB) GetJob(id string) (*brigade.Job, error) {
+	var data string
+	if err := d.db.QueryRow(`SELECT job FROM brigade_jobs WHERE id = $1`, id).Scan(&data); err != nil {
+		return nil, err
+	}
+	j := &brigade.Job{}
+	return j, json.Unmarshal([]byte(data), j)
+}
+
+// GetBuildJobs returns the recorded jobs of a build.
+func (d *DB) GetBuildJobs(buildID string) ([]*brigade.Job, error) {
+	rows, err := d.db.Query(`SELECT job FROM brigade_jobs WHERE build_id = $1 ORDER BY id`, buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*brigade.Job{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		j := &brigade.Job{}
+		if err := json.Unmarshal([]byte(data), j); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBuild(row scanner) (*brigade.Build, error) {
+	var data string
+	var worker sql.NullString
+	if err := row.Scan(&data, &worker); err != nil {
+		return nil, err
+	}
+	b := &brigade.Build{}
+	if err := json.Unmarshal([]byte(data), b); err != nil {
+		return nil, err
+	}
+	if worker.Valid {
+		b.Worker = &brigade.Worker{}
+		if err := json.Unmarshal([]byte(worker.String), b.Worker); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// timeKey returns the start time of a build as stored in the database.
+func timeKey(t time.Time) int64 {
+	return t.UnixNano()
+}
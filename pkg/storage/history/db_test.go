@@ -0,0 +1,312 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/mock"
+)
+
+// openTestDB connects to the database given by BRIGADE_TEST_HISTORY_DSN, or
+// to an embedded SQLite database if there is none. Every history table is
+// emptied first.
+func openTestDB(t *testing.T) *DB {
+	dsn := os.Getenv("BRIGADE_TEST_HISTORY_DSN")
+	driver := os.Getenv("BRIGADE_TEST_HISTORY_DRIVER")
+	if driver == "" {
+		driver = DefaultDriver
+	}
+	if dsn == "" {
+		dir, err := ioutil.TempDir("", "brigade-history")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+		driver, dsn = SQLiteDriver, filepath.Join(dir, "history.db")
+	}
+	db, err := Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"brigade_builds", "brigade_jobs", "brigade_reruns"} {
+		if _, err := db.db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestDBListBuilds(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	builds := []*brigade.Build{
+		{ID: "01a", ProjectID: "p1", Type: "push", Provider: "github", Revision: &brigade.Revision{Commit: "abc123", Ref: "refs/heads/master"},
+			Worker: &brigade.Worker{ID: "w-01a", BuildID: "01a", ProjectID: "p1", StartTime: now.Add(-3 * time.Hour), Status: brigade.JobSucceeded}},
+		{ID: "01b", ProjectID: "p1", Type: "push", Provider: "github", Revision: &brigade.Revision{Commit: "def456", Ref: "refs/heads/dev"},
+			Worker: &brigade.Worker{ID: "w-01b", BuildID: "01b", ProjectID: "p1", StartTime: now.Add(-2 * time.Hour), Status: brigade.JobFailed}},
+		{ID: "01c", ProjectID: "p2", Type: "exec", Provider: "brigade-cli",
+			Worker: &brigade.Worker{ID: "w-01c", BuildID: "01c", ProjectID: "p2", StartTime: now.Add(-1 * time.Hour), Status: brigade.JobRunning}},
+		{ID: "01d", ProjectID: "p1", Type: "push", Provider: "github"},
+	}
+	for _, b := range builds {
+		if err := db.RecordBuild(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		project string
+		opts    storage.BuildListOptions
+	}{
+		{"all", "", storage.BuildListOptions{}},
+		{"project", "p1", storage.BuildListOptions{}},
+		{"ascending", "", storage.BuildListOptions{Ascending: true}},
+		{"status", "", storage.BuildListOptions{Status: []brigade.JobStatus{"failed", brigade.JobPending}}},
+		{"commit", "", storage.BuildListOptions{Commit: "abc"}},
+		{"ref", "", storage.BuildListOptions{Ref: "refs/heads/dev"}},
+		{"since", "", storage.BuildListOptions{Since: now.Add(-150 * time.Minute)}},
+		{"until", "", storage.BuildListOptions{Until: now.Add(-150 * time.Minute)}},
+	}
+	for _, tt := range tests {
+		candidates := builds
+		if tt.project != "" {
+			candidates = []*brigade.Build{}
+			for _, b := range builds {
+				if b.ProjectID == tt.project {
+					candidates = append(candidates, b)
+				}
+			}
+		}
+		expect, err := storage.PageBuilds(candidates, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := db.ListBuilds(tt.project, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if ids(got.Items) != ids(expect.Items) {
+			t.Errorf("%s: expected %s, got %s", tt.name, ids(expect.Items), ids(got.Items))
+		}
+	}
+
+	// Page through all builds two at a time.
+	opts := storage.BuildListOptions{Limit: 2}
+	seen := []*brigade.Build{}
+	for i := 0; i < 3; i++ {
+		page, err := db.ListBuilds("", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = append(seen, page.Items...)
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	if ids(seen) != "01d,01c,01b,01a" {
+		t.Errorf("expected to page through 01d,01c,01b,01a, got %s", ids(seen))
+	}
+}
+
+func TestDBRecordWorkerBeforeBuild(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	w := &brigade.Worker{ID: "w-01a", BuildID: "01a", ProjectID: "p1", StartTime: time.Now(), Status: brigade.JobRunning}
+	if err := db.RecordWorker(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordBuild(&brigade.Build{ID: "01a", ProjectID: "p1", Type: "push", Script: []byte("secret")}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := db.GetBuild("01a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "push" || b.Worker == nil || b.Worker.Status != brigade.JobRunning {
+		t.Errorf("expected the build and its worker to be recorded, got %+v", b)
+	}
+	if b.Script != nil {
+		t.Error("expected the script not to be recorded")
+	}
+
+	for _, status := range []brigade.JobStatus{brigade.JobRunning, brigade.JobSucceeded} {
+		if err := db.RecordJob(&brigade.Job{ID: "job-1", BuildID: "01a", ProjectID: "p1", Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jobs, err := db.GetBuildJobs("01a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Status != brigade.JobSucceeded {
+		t.Errorf("expected the job to be updated, got %+v", jobs)
+	}
+}
+
+func TestDBListBuildsRecordedWorkerFirst(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	builds := []*brigade.Build{
+		{ID: "01a", ProjectID: "p1", Type: "push", Provider: "github", Revision: &brigade.Revision{Commit: "abc123", Ref: "refs/heads/master"}},
+		{ID: "01b", ProjectID: "p1", Type: "exec", Provider: "brigade-cli", Revision: &brigade.Revision{Commit: "def456", Ref: "refs/heads/master"}},
+	}
+	for i, b := range builds {
+		w := &brigade.Worker{ID: "w-" + b.ID, BuildID: b.ID, ProjectID: b.ProjectID, StartTime: now.Add(time.Duration(i) * time.Minute), Status: brigade.JobRunning}
+		if err := db.RecordWorker(w); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.RecordBuild(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		opts   storage.BuildListOptions
+		expect string
+	}{
+		{"type", storage.BuildListOptions{Type: "push"}, "01a"},
+		{"provider", storage.BuildListOptions{Provider: "brigade-cli"}, "01b"},
+		{"commit", storage.BuildListOptions{Commit: "abc"}, "01a"},
+	}
+	for _, tt := range tests {
+		got, err := db.ListBuilds("p1", tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if ids(got.Items) != tt.expect {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expect, ids(got.Items))
+		}
+		if len(got.Items) == 1 && (got.Items[0].Worker == nil || got.Items[0].Worker.Status != brigade.JobRunning) {
+			t.Errorf("%s: expected the worker to be kept, got %+v", tt.name, got.Items[0].Worker)
+		}
+	}
+}
+
+func TestStoreFallsBackToHistory(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	live := mock.New()
+	// The history lags behind a build which is still running.
+	stale := *mock.StubBuild1
+	staleWorker := *mock.StubWorker1
+	staleWorker.Status = brigade.JobRunning
+	stale.Worker = &staleWorker
+	if err := db.RecordBuild(&stale); err != nil {
+		t.Fatal(err)
+	}
+	vacuumed := &brigade.Build{ID: "vacuumed", ProjectID: mock.StubProject.ID,
+		Worker: &brigade.Worker{ID: "w-vacuumed", BuildID: "vacuumed", ProjectID: mock.StubProject.ID, StartTime: time.Now(), Status: brigade.JobSucceeded}}
+	if err := db.RecordBuild(vacuumed); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(live, db)
+
+	b, err := store.GetBuild(mock.StubBuild1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Script == nil {
+		t.Error("expected a live build to be read from the wrapped store")
+	}
+	if _, err := store.GetBuild("vacuumed"); err != nil {
+		t.Errorf("expected a vacuumed build to be read from the history, got %v", err)
+	}
+
+	list, err := store.ListProjectBuilds(mock.StubProject, storage.BuildListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(list.Items) != "vacuumed,build-id1" {
+		t.Errorf("expected builds to be listed from the history, got %s", ids(list.Items))
+	}
+	if list.Items[1].Worker.Status != brigade.JobSucceeded {
+		t.Errorf("expected a live build to be listed from the wrapped store, got %s", list.Items[1].Worker.Status)
+	}
+
+	// Builds which have not been recorded yet are read from the wrapped store.
+	builds, err := store.GetProjectBuilds(mock.StubProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(builds) != "vacuumed,build-id1,build-id2" {
+		t.Errorf("expected the live and vacuumed builds, got %s", ids(builds))
+	}
+	if builds[1].Worker.Status != brigade.JobSucceeded {
+		t.Errorf("expected a live build to be read from the wrapped store, got %s", builds[1].Worker.Status)
+	}
+	builds, err = store.GetBuilds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(builds) != "build-id2,build-id1,vacuumed" {
+		t.Errorf("expected the live and vacuumed builds oldest first, got %s", ids(builds))
+	}
+}
+
+func TestStoreGetBuildLineage(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	// 01a was rerun by 01b, which was retried by 01c and 01d. 01e is unrelated.
+	for _, b := range []*brigade.Build{
+		{ID: "01a", ProjectID: "p1"},
+		{ID: "01b", ProjectID: "p1", ParentBuildID: "01a", RerunReason: brigade.RerunManual},
+		{ID: "01c", ProjectID: "p1", ParentBuildID: "01b", RerunReason: brigade.RerunRetry, Attempt: 2},
+		{ID: "01d", ProjectID: "p1", ParentBuildID: "01c", RerunReason: brigade.RerunRetry, Attempt: 3},
+		{ID: "01e", ProjectID: "p1"},
+	} {
+		if err := db.RecordBuild(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	builds, err := db.GetBuildLineage("01c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(builds) != "01a,01b,01c,01d" {
+		t.Errorf("expected the recorded rerun chain oldest first, got %s", ids(builds))
+	}
+	if _, err := db.GetBuildLineage("nope"); err != sql.ErrNoRows {
+		t.Errorf("expected %v, got %v", sql.ErrNoRows, err)
+	}
+
+	// Builds which no longer exist in the wrapped store are read from the
+	// history.
+	live := mock.New()
+	live.GetBuildLineageErr = errors.New("build 01c not found")
+	builds, err = NewStore(live, db).GetBuildLineage("01c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(builds) != "01a,01b,01c,01d" {
+		t.Errorf("expected the rerun chain to be read from the history, got %s", ids(builds))
+	}
+}
+
+func ids(builds []*brigade.Build) string {
+	s := ""
+	for i, b := range builds {
+		if i > 0 {
+			s += ","
+		}
+		s += b.ID
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package history
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// listQuery builds the query for a page of builds. One build more than the
+// limit is selected, to tell whether there is another page.
+func listQuery(projectID string, opts storage.BuildListOptions) (string, []interface{}, error) {
+	where := []string{}
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if projectID != "" {
+		where = append(where, "project_id = "+arg(projectID))
+	}
+	if len(opts.Status) > 0 {
+		in := []string{}
+		for _, s := range opts.Status {
+			in = append(in, arg(strings.ToLower(string(s))))
+		}
+		// Builds without a worker are Pending.
+		where = append(where, fmt.Sprintf("LOWER(CASE WHEN worker IS NULL THEN 'Pending' ELSE status END) IN (%s)", strings.Join(in, ", ")))
+	}
+	if opts.Type != "" {
+		where = append(where, "type = "+arg(opts.Type))
+	}
+	if opts.Provider != "" {
+		where = append(where, "provider = "+arg(opts.Provider))
+	}
+	if opts.Commit != "" {
+		where = append(where, fmt.Sprintf("SUBSTR(commit_sha, 1, %d) = %s", len(opts.Commit), arg(opts.Commit)))
+	}
+	if opts.Ref != "" {
+		where = append(where, "ref = "+arg(opts.Ref))
+	}
+	if !opts.Since.IsZero() || !opts.Until.IsZero() {
+		where = append(where, "start_time > 0")
+		if !opts.Since.IsZero() {
+			where = append(where, "start_time >= "+arg(timeKey(opts.Since)))
+		}
+		if !opts.Until.IsZero() {
+			where = append(where, "start_time < "+arg(timeKey(opts.Until)))
+		}
+	}
+
+	order, cmp := "DESC", "<"
+	if opts.Ascending {
+		order, cmp = "ASC", ">"
+	}
+	if opts.Continue != "" {
+		startTime, id, err := storage.ParseContinueToken(opts.Continue)
+		if err != nil {
+			return "", nil, err
+		}
+		sortKey := startTime
+		if sortKey == 0 {
+			sortKey = notStarted
+		}
+		k, i := arg(sortKey), arg(id)
+		where = append(where, fmt.Sprintf("(sort_key %s %s OR (sort_key = %s AND id %s %s))", cmp, k, k, cmp, i))
+	}
+
+	query := "SELECT build, worker FROM brigade_builds"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY sort_key %s, id %s", order, order)
+	if opts.Limit > 0 {
+		query += " LIMIT " + arg(opts.Limit+1)
+	}
+	return query, args, nil
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+func TestListQuery(t *testing.T) {
+	query, args, err := listQuery("p1", storage.BuildListOptions{
+		Limit:  10,
+		Status: []brigade.JobStatus{brigade.JobFailed},
+		Commit: "abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT build, worker FROM brigade_builds WHERE project_id = $1" +
+		" AND LOWER(CASE WHEN worker IS NULL THEN 'Pending' ELSE status END) IN ($2)" +
+		" AND SUBSTR(commit_sha, 1, 3) = $3" +
+		" ORDER BY sort_key DESC, id DESC LIMIT $4"
+	if query != expect {
+		t.Errorf("expected\n%s\ngot\n%s", expect, query)
+	}
+	if len(args) != 4 || args[1] != "failed" || args[3] != 11 {
+		t.Errorf("unexpected args %v", args)
+	}
+}
+
+func TestListQueryContinue(t *testing.T) {
+	last := &brigade.Build{ID: "01b", Worker: &brigade.Worker{StartTime: time.Unix(0, 42)}}
+	query, args, err := listQuery("", storage.BuildListOptions{Ascending: true, Continue: storage.ContinueToken(last)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "SELECT build, worker FROM brigade_builds" +
+		" WHERE (sort_key > $1 OR (sort_key = $1 AND id > $2))" +
+		" ORDER BY sort_key ASC, id ASC"
+	if query != expect {
+		t.Errorf("expected\n%s\ngot\n%s", expect, query)
+	}
+	if len(args) != 2 || args[0] != int64(42) || args[1] != "01b" {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	if _, _, err := listQuery("", storage.BuildListOptions{Continue: "!"}); err != storage.ErrInvalidContinue {
+		t.Errorf("expected ErrInvalidContinue, got %v", err)
+	}
+}
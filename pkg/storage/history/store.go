@@ -0,0 +1,194 @@
+package history
+
+import (
+	"sort"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// store serves builds and jobs from a history database, and everything else
+// from the wrapped Store.
+type store struct {
+	storage.Store
+	db *DB
+}
+
+// NewStore wraps s so that builds and jobs that no longer exist in s are read
+// from the history database, and builds are paged through in it.
+//
+// s remains the source of truth for builds it knows about, so running builds
+// are never stale. Builds are still created and deleted in s only; deleting a
+// build does not remove it from the history.
+func NewStore(s storage.Store, db *DB) storage.Store {
+	return &store{Store: s, db: db}
+}
+
+func (s *store) GetBuild(id string) (*brigade.Build, error) {
+	b, err := s.Store.GetBuild(id)
+	if err == nil {
+		return b, nil
+	}
+	if h, herr := s.db.GetBuild(id); herr == nil {
+		return h, nil
+	}
+	return nil, err
+}
+
+// GetBuilds returns the builds of the wrapped store, along with recorded builds
+// which no longer exist in it, oldest first.
+func (s *store) GetBuilds() ([]*brigade.Build, error) {
+	live, err := s.Store.GetBuilds()
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.db.ListBuilds("", storage.BuildListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return sortBuilds(merge(live, list.Items), true)
+}
+
+// GetProjectBuilds returns the builds of a project in the wrapped store, along
+// with recorded builds which no longer exist in it, newest first.
+func (s *store) GetProjectBuilds(proj *brigade.Project) ([]*brigade.Build, error) {
+	live, err := s.Store.GetProjectBuilds(proj)
+	if err != nil {
+		return nil, err
+	}
+	list, err := s.db.ListBuilds(proj.ID, storage.BuildListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return sortBuilds(merge(live, list.Items), false)
+}
+
+// ListBuilds pages through the history, with the builds which still exist in
+// the wrapped store read from it.
+func (s *store) ListBuilds(opts storage.BuildListOptions) (*storage.BuildList, error) {
+	list, err := s.db.ListBuilds("", opts)
+	if err != nil {
+		return nil, err
+	}
+	live, err := s.Store.GetBuilds()
+	if err != nil {
+		return nil, err
+	}
+	refresh(list.Items, live)
+	return list, nil
+}
+
+// ListProjectBuilds pages through the history of a project, with the builds
+// which still exist in the wrapped store read from it.
+func (s *store) ListProjectBuilds(proj *brigade.Project, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	list, err := s.db.ListBuilds(proj.ID, opts)
+	if err != nil {
+		return nil, err
+	}
+	live, err := s.Store.GetProjectBuilds(proj)
+	if err != nil {
+		return nil, err
+	}
+	refresh(list.Items, live)
+	return list, nil
+}
+
+// merge returns the live builds, and the recorded builds which are not live.
+func merge(live, recorded []*brigade.Build) []*brigade.Build {
+	builds := append([]*brigade.Build{}, live...)
+	ids := map[string]bool{}
+	for _, b := range live {
+		ids[b.ID] = true
+	}
+	for _, b := range recorded {
+		if !ids[b.ID] {
+			builds = append(builds, b)
+		}
+	}
+	return builds
+}
+
+// refresh replaces the recorded builds which are live with their live versions.
+func refresh(recorded, live []*brigade.Build) {
+	byID := map[string]*brigade.Build{}
+	for _, b := range live {
+		byID[b.ID] = b
+	}
+	for i, b := range recorded {
+		if l, ok := byID[b.ID]; ok {
+			recorded[i] = l
+		}
+	}
+}
+
+// sortBuilds sorts builds by the start time of their worker, like
+// storage.PageBuilds does.
+func sortBuilds(builds []*brigade.Build, ascending bool) ([]*brigade.Build, error) {
+	list, err := storage.PageBuilds(builds, storage.BuildListOptions{Ascending: ascending})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// GetBuildLineage returns the rerun chain of a build in the wrapped store,
+// along with recorded builds of the chain which no longer exist in it, oldest
+// first.
+func (s *store) GetBuildLineage(id string) ([]*brigade.Build, error) {
+	live, err := s.Store.GetBuildLineage(id)
+	recorded, herr := s.db.GetBuildLineage(id)
+	if herr != nil {
+		return live, err
+	}
+	if err != nil {
+		return recorded, nil
+	}
+	builds := merge(live, recorded)
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	return builds, nil
+}
+
+func (s *store) GetJob(id string) (*brigade.Job, error) {
+	j, err := s.Store.GetJob(id)
+	if err == nil {
+		return j, nil
+	}
+	if h, herr := s.db.GetJob(id); herr == nil {
+		return h, nil
+	}
+	return nil, err
+}
+
+// GetBuildJobs returns the jobs of a build from the wrapped store, along with
+// recorded jobs whose pods no longer exist.
+func (s *store) GetBuildJobs(b *brigade.Build) ([]*brigade.Job, error) {
+	jobs, err := s.Store.GetBuildJobs(b)
+	recorded, herr := s.db.GetBuildJobs(b.ID)
+	if herr != nil {
+		return jobs, err
+	}
+	if err != nil {
+		return recorded, nil
+	}
+	live := map[string]bool{}
+	for _, j := range jobs {
+		live[j.ID] = true
+	}
+	for _, j := range recorded {
+		if !live[j.ID] {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *store) GetWorker(buildID string) (*brigade.Worker, error) {
+	w, err := s.Store.GetWorker(buildID)
+	if err == nil {
+		return w, nil
+	}
+	if h, herr := s.db.GetBuild(buildID); herr == nil && h.Worker != nil {
+		return h.Worker, nil
+	}
+	return nil, err
+}
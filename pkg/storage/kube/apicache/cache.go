@@ -2,9 +2,11 @@ package apicache
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
@@ -12,6 +14,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/brigadecore/brigade/pkg/merge"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 const defaultCacheSyncTimeout = 30 * time.Second
@@ -23,17 +26,23 @@ type APICache interface {
 	GetSecretsFilteredBy(labelSelectors map[string]string) ([]v1.Secret, error)
 	// get cached pods filtered by label selectors k/v pairs
 	GetPodsFilteredBy(labelSelectors map[string]string) ([]v1.Pod, error)
+	// register a handler which is notified whenever a cached secret or pod is added, updated or deleted
+	AddEventHandler(handler cache.ResourceEventHandler)
 }
 
 type apiCache struct {
 	// the kubernetes client
 	client kubernetes.Interface
+	// the namespaces whose secrets and pods are cached
+	namespaces namespaces.Set
 	// a ready to use cache.Store for secrets
 	secretStore cache.Store
 	// a ready to use cache.Store for pods
 	podStore cache.Store
 	// a chan which is going to be closed after the APICache has initially synced all cache.Store's
 	hasSyncedInitially <-chan struct{}
+	// the handlers notified of changes to the secret and pod stores
+	handlers *eventHandlers
 }
 
 type storeConfig struct {
@@ -51,22 +60,55 @@ type storeConfig struct {
 	// implement the method invoking the kubernetes.Interface to return
 	// a watch.Interface that returns the expected runtime.Object type
 	watchFunc func(client kubernetes.Interface, namespace string, options metaV1.ListOptions) (watch.Interface, error)
+	// the handler notified of changes to the store, may be nil
+	handler cache.ResourceEventHandler
 }
 
 // New returns a new APICache
 func New(client kubernetes.Interface, namespace string, resyncPeriod time.Duration) APICache {
+	return NewWithEventHandlers(client, namespace, resyncPeriod)
+}
+
+// NewWithEventHandlers is like New, but registers handlers before the informers
+// start, so that they are notified of every secret and pod that already exists.
+func NewWithEventHandlers(client kubernetes.Interface, namespace string, resyncPeriod time.Duration, handlers ...cache.ResourceEventHandler) APICache {
+	return NewForNamespaces(client, namespaces.Single(namespace), resyncPeriod, handlers...)
+}
+
+// NewForNamespaces is like NewWithEventHandlers, but caches the secrets and
+// pods of every namespace of a set.
+func NewForNamespaces(client kubernetes.Interface, set namespaces.Set, resyncPeriod time.Duration, handlers ...cache.ResourceEventHandler) APICache {
 
 	secretsSynced := make(chan struct{})
 	podsSynced := make(chan struct{})
+	fanOut := &eventHandlers{}
+	for _, h := range handlers {
+		fanOut.add(h)
+	}
+	handler := cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			o, err := meta.Accessor(obj)
+			return err == nil && namespaces.Watched(set, o.GetNamespace())
+		},
+		Handler: fanOut,
+	}
 
 	return &apiCache{
 		client:             client,
+		namespaces:         set,
 		hasSyncedInitially: merge.Channels(secretsSynced, podsSynced),
-		secretStore:        newSecretStore(client, namespace, resyncPeriod, secretsSynced),
-		podStore:           newPodStore(client, namespace, resyncPeriod, podsSynced),
+		secretStore:        newSecretStore(client, set.Watch(), resyncPeriod, secretsSynced, handler),
+		podStore:           newPodStore(client, set.Watch(), resyncPeriod, podsSynced, handler),
+		handlers:           fanOut,
 	}
 }
 
+// AddEventHandler registers a handler which is notified whenever a cached secret or pod changes.
+// Objects added before the handler was registered are not replayed.
+func (a *apiCache) AddEventHandler(handler cache.ResourceEventHandler) {
+	a.handlers.add(handler)
+}
+
 // blockUntilAPICacheSynced blocks until all cache.Store's are synced
 // returns nil if all channels are closed
 // returns error if the timeout was reached (in case timeout > 0)
@@ -85,3 +127,38 @@ func (a *apiCache) blockUntilAPICacheSynced(timeout time.Duration) error {
 		return nil
 	}
 }
+
+// eventHandlers fans out informer notifications to every registered handler
+type eventHandlers struct {
+	mu       sync.RWMutex
+	handlers []cache.ResourceEventHandler
+}
+
+func (e *eventHandlers) add(handler cache.ResourceEventHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers = append(e.handlers, handler)
+}
+
+func (e *eventHandlers) each(fn func(cache.ResourceEventHandler)) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, h := range e.handlers {
+		fn(h)
+	}
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (e *eventHandlers) OnAdd(obj interface{}) {
+	e.each(func(h cache.ResourceEventHandler) { h.OnAdd(obj) })
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (e *eventHandlers) OnUpdate(oldObj, newObj interface{}) {
+	e.each(func(h cache.ResourceEventHandler) { h.OnUpdate(oldObj, newObj) })
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (e *eventHandlers) OnDelete(obj interface{}) {
+	e.each(func(h cache.ResourceEventHandler) { h.OnDelete(obj) })
+}
@@ -20,15 +20,20 @@ func newListStore(client kubernetes.Interface, config storeConfig, hasSynced cha
 		},
 	}
 
+	var handler cache.ResourceEventHandler = cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) {},
+		UpdateFunc: func(oldObj, newObj interface{}) {},
+		DeleteFunc: func(obj interface{}) {},
+	}
+	if config.handler != nil {
+		handler = config.handler
+	}
+
 	store, ctr := cache.NewInformer(
 		&listWatch,
 		config.expectedType,
 		config.resyncPeriod,
-		cache.ResourceEventHandlerFuncs{
-			AddFunc:    func(obj interface{}) {},
-			UpdateFunc: func(oldObj, newObj interface{}) {},
-			DeleteFunc: func(obj interface{}) {},
-		})
+		handler)
 
 	// run the controller in a new goroutine, else this operation would block
 	// we currently don't supply a close chan as there is no need to
@@ -10,14 +10,17 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 // return a new cached store for secrets
-func newPodStore(client kubernetes.Interface, namespace string, resyncPeriod time.Duration, synced chan struct{}) cache.Store {
+func newPodStore(client kubernetes.Interface, namespace string, resyncPeriod time.Duration, synced chan struct{}, handler cache.ResourceEventHandler) cache.Store {
 	return newListStore(client, storeConfig{
 		resource:     "pods",
 		namespace:    namespace,
 		resyncPeriod: resyncPeriod,
+		handler:      handler,
 		expectedType: &v1.Pod{},
 		listFunc: func(client kubernetes.Interface, namespace string, options metaV1.ListOptions) (runtime.Object, error) {
 			return client.CoreV1().Pods(namespace).List(context.TODO(), options)
@@ -49,6 +52,11 @@ func (a *apiCache) GetPodsFilteredBy(selectors map[string]string) ([]v1.Pod, err
 			continue
 		}
 
+		// skip objects of namespaces outside of the set
+		if !namespaces.Watched(a.namespaces, secret.Namespace) {
+			continue
+		}
+
 		// skip if the maps don't match
 		if !stringMapsMatch(secret.Labels, selectors) {
 			continue
@@ -10,6 +10,7 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/brigadecore/brigade/pkg/merge"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 func TestPodStore(t *testing.T) {
@@ -20,7 +21,7 @@ func TestPodStore(t *testing.T) {
 	podsSynced := make(chan struct{})
 	merged := merge.Channels(secretsSynced, podsSynced)
 
-	store := newPodStore(client, "default", 1, podsSynced)
+	store := newPodStore(client, "default", 1, podsSynced, nil)
 
 	validLabels := map[string]string{
 		"foo": "bar",
@@ -66,8 +67,9 @@ func TestPodStore(t *testing.T) {
 	cache := apiCache{
 		hasSyncedInitially: merged,
 		client:             client,
+		namespaces:         namespaces.Single("default"),
 		podStore:           store,
-		secretStore:        newSecretStore(client, "default", 1, secretsSynced),
+		secretStore:        newSecretStore(client, "default", 1, secretsSynced, nil),
 	}
 
 	filteredPods, err := cache.GetPodsFilteredBy(validLabels)
@@ -10,6 +10,7 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/brigadecore/brigade/pkg/merge"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
 func TestSecretStore(t *testing.T) {
@@ -20,7 +21,7 @@ func TestSecretStore(t *testing.T) {
 	podsSynced := make(chan struct{})
 	merged := merge.Channels(secretsSynced, podsSynced)
 
-	store := newSecretStore(client, "default", 1, secretsSynced)
+	store := newSecretStore(client, "default", 1, secretsSynced, nil)
 
 	validLabels := map[string]string{
 		"foo": "bar",
@@ -66,8 +67,9 @@ func TestSecretStore(t *testing.T) {
 	cache := apiCache{
 		hasSyncedInitially: merged,
 		client:             client,
+		namespaces:         namespaces.Single("default"),
 		secretStore:        store,
-		podStore:           newPodStore(client, "default", 1, podsSynced),
+		podStore:           newPodStore(client, "default", 1, podsSynced, nil),
 	}
 
 	filteredPods, err := cache.GetSecretsFilteredBy(validLabels)
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/oklog/ulid"
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 	"github.com/brigadecore/brigade/pkg/storage"
@@ -18,6 +20,15 @@ import (
 
 const secretTypeBuild = "brigade.sh/build"
 
+const (
+	// ParentBuildLabel is the ID of the build that the build of a build secret
+	// reruns.
+	ParentBuildLabel = "parent-build"
+	// RerunReasonLabel is why the build of a build secret reruns its parent
+	// build.
+	RerunReasonLabel = "rerun-reason"
+)
+
 const jobFilter = "component in (build, job), heritage = brigade, build = %s"
 
 // GetBuild returns the build.
@@ -26,53 +37,58 @@ func (s *store) GetBuild(id string) (*brigade.Build, error) {
 
 	labels := fmt.Sprint("heritage=brigade,component=build,build=", build.ID)
 	listOption := meta.ListOptions{LabelSelector: labels}
-	secrets, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), listOption)
+	secrets, err := s.listSecrets(listOption)
 	if err != nil {
 		return nil, err
 	}
-	if len(secrets.Items) < 1 {
+	if len(secrets) < 1 {
 		return nil, fmt.Errorf("could not find build %s: no secrets exist with labels %s", id, labels)
 	}
 	// Select the first secret as the build IDs are unique
-	b := NewBuildFromSecret(secrets.Items[0])
-	b.Worker, err = s.GetWorker(build.ID)
-	return b, err
+	b := NewBuildFromSecret(secrets[0])
+	if b.Worker, err = s.GetWorker(build.ID); err != nil {
+		return b, err
+	}
+	return b, s.setQueuePositions(b)
 }
 
 // DeleteBuild deletes a build.
+//
+// If running builds are skipped and the worker of the build is pending or
+// running, nothing is deleted and storage.ErrBuildRunning is returned.
 func (s *store) DeleteBuild(bid string, options storage.DeleteBuildOptions) error {
 	opts := meta.ListOptions{
 		LabelSelector: fmt.Sprintf(jobFilter, bid),
 	}
 	delOpts := meta.NewDeleteOptions(0)
-	pods, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), opts)
+	pods, err := s.listPods(opts)
 	if err != nil {
 		return err
 	}
 	if options.SkipRunningBuilds {
-		for _, p := range pods.Items {
+		for _, p := range pods {
 			if p.Labels["component"] == "build" {
 				if p.Status.Phase == v1.PodRunning || p.Status.Phase == v1.PodPending {
 					log.Printf("skipping Build %s because its Status is %s", p.Labels["build"], p.Status.Phase)
-					return nil
+					return storage.ErrBuildRunning
 				}
 			}
 		}
 	}
-	for _, p := range pods.Items {
+	for _, p := range pods {
 		log.Printf("Deleting pod %q", p.Name)
-		if err := s.client.CoreV1().Pods(s.namespace).Delete(context.TODO(), p.Name, *delOpts); err != nil {
+		if err := s.client.CoreV1().Pods(p.Namespace).Delete(context.TODO(), p.Name, *delOpts); err != nil {
 			log.Printf("failed to delete job pod %s (continuing): %s", p.Name, err)
 		}
 	}
 
-	secrets, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), opts)
+	secrets, err := s.listSecrets(opts)
 	if err != nil {
 		return err
 	}
-	for _, sec := range secrets.Items {
+	for _, sec := range secrets {
 		log.Printf("Deleting secret %q", sec.Name)
-		if err := s.client.CoreV1().Secrets(s.namespace).Delete(context.TODO(), sec.Name, *delOpts); err != nil {
+		if err := s.client.CoreV1().Secrets(sec.Namespace).Delete(context.TODO(), sec.Name, *delOpts); err != nil {
 			log.Printf("failed to delete job secret %s (continuing): %s", sec.Name, err)
 		}
 	}
@@ -117,10 +133,27 @@ func (s *store) CreateBuild(build *brigade.Build) error {
 			"event_type":     build.Type,
 			"project_id":     build.ProjectID,
 			"log_level":      build.LogLevel,
+			"priority":       build.Priority,
+			"traceparent":    build.Traceparent,
 		},
 	}
+	if build.Attempt > 0 {
+		secret.StringData["attempt"] = strconv.Itoa(build.Attempt)
+	}
+	if build.ParentBuildID != "" {
+		secret.Labels[ParentBuildLabel] = build.ParentBuildID
+		secret.Labels[RerunReasonLabel] = def(build.RerunReason, brigade.RerunManual)
+	}
+	if build.NotBefore != nil {
+		secret.StringData["not_before"] = build.NotBefore.UTC().Format(time.RFC3339)
+	}
 
-	_, err := s.client.CoreV1().Secrets(s.namespace).Create(context.TODO(), &secret, meta.CreateOptions{})
+	// The build lives in the namespace of its project.
+	ns, err := s.projectNamespace(build.ProjectID)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.CoreV1().Secrets(ns).Create(context.TODO(), &secret, meta.CreateOptions{})
 	return err
 }
 
@@ -128,25 +161,28 @@ func (s *store) CreateBuild(build *brigade.Build) error {
 func (s *store) GetBuilds() ([]*brigade.Build, error) {
 	lo := meta.ListOptions{LabelSelector: "heritage=brigade,component=build"}
 
-	secretList, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), lo)
+	secrets, err := s.listSecrets(lo)
 	if err != nil {
 		return nil, err
 	}
 
-	podList, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), lo)
+	pods, err := s.listPods(lo)
 	if err != nil {
 		return nil, err
 	}
 
-	buildList := make([]*brigade.Build, len(secretList.Items))
-	for i := range secretList.Items {
-		b := NewBuildFromSecret(secretList.Items[i])
+	buildList := make([]*brigade.Build, len(secrets))
+	for i := range secrets {
+		b := NewBuildFromSecret(secrets[i])
 		// The error is ErrWorkerNotFound, and in that case, we just ignore
 		// it and assign nil to the worker.
-		b.Worker, _ = findWorker(b.ID, podList.Items)
+		b.Worker, _ = findWorker(b.ID, pods)
+		if b.Worker == nil {
+			b.Worker = NewWorkerFromSecret(secrets[i])
+		}
 		buildList[i] = b
 	}
-	return buildList, nil
+	return buildList, s.setQueuePositions(buildList...)
 }
 
 // GetProjectBuilds returns all the builds for the given project.
@@ -178,10 +214,77 @@ func (s *store) GetProjectBuilds(proj *brigade.Project) ([]*brigade.Build, error
 		// The error is ErrWorkerNotFound, and in that case, we just ignore
 		// it and assign nil to the worker.
 		b.Worker, _ = findWorker(b.ID, projectPods)
+		if b.Worker == nil {
+			b.Worker = NewWorkerFromSecret(projectSecrets[i])
+		}
 		buildList[i] = b
 	}
 
-	return buildList, nil
+	return buildList, s.setQueuePositions(buildList...)
+}
+
+// ListBuilds returns a filtered, sorted page of the builds in storage.
+func (s *store) ListBuilds(opts storage.BuildListOptions) (*storage.BuildList, error) {
+	return s.listBuilds(map[string]string{
+		"heritage":  "brigade",
+		"component": "build",
+	}, opts)
+}
+
+// ListProjectBuilds returns a filtered, sorted page of the builds for the given project.
+func (s *store) ListProjectBuilds(proj *brigade.Project, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	return s.listBuilds(map[string]string{
+		"heritage":  "brigade",
+		"component": "build",
+		"project":   proj.ID,
+	}, opts)
+}
+
+// listBuilds loads the builds matching the label selector. Long-lived stores
+// load them from the API cache, so that paging through large numbers of builds
+// does not hit the API server, and other stores list them from the API server
+// instead of starting the cache for a single listing.
+func (s *store) listBuilds(labelSelectorMap map[string]string, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	var secrets []v1.Secret
+	var pods []v1.Pod
+	var err error
+	if s.cachedLists {
+		if secrets, err = s.apiCache.GetSecretsFilteredBy(labelSelectorMap); err != nil {
+			return nil, err
+		}
+		if pods, err = s.apiCache.GetPodsFilteredBy(labelSelectorMap); err != nil {
+			return nil, err
+		}
+	} else {
+		lo := meta.ListOptions{LabelSelector: labels.SelectorFromSet(labelSelectorMap).String()}
+		if secrets, err = s.listSecrets(lo); err != nil {
+			return nil, err
+		}
+		if pods, err = s.listPods(lo); err != nil {
+			return nil, err
+		}
+	}
+
+	workers := make(map[string]*brigade.Worker, len(pods))
+	for i := range pods {
+		if bid, ok := pods[i].Labels["build"]; ok {
+			workers[bid] = NewWorkerFromPod(pods[i])
+		}
+	}
+
+	builds := make([]*brigade.Build, len(secrets))
+	for i := range secrets {
+		b := NewBuildFromSecret(secrets[i])
+		b.Worker = workers[b.ID]
+		if b.Worker == nil {
+			b.Worker = NewWorkerFromSecret(secrets[i])
+		}
+		builds[i] = b
+	}
+	if err := s.setQueuePositions(builds...); err != nil {
+		return nil, err
+	}
+	return storage.PageBuilds(builds, opts)
 }
 
 func findWorker(id string, pods []v1.Pod) (*brigade.Worker, bool) {
@@ -201,6 +304,14 @@ func findWorker(id string, pods []v1.Pod) (*brigade.Worker, bool) {
 func NewBuildFromSecret(secret v1.Secret) *brigade.Build {
 	lbs := secret.ObjectMeta.Labels
 	sv := SecretValues(secret.Data)
+	var supersedes []string
+	if ids := secret.Annotations[SupersedesAnnotation]; ids != "" {
+		supersedes = strings.Split(ids, ",")
+	}
+	var notBefore *time.Time
+	if t, ok := BuildNotBefore(secret); ok {
+		notBefore = &t
+	}
 	return &brigade.Build{
 		ID:         lbs["build"],
 		ProjectID:  lbs["project"],
@@ -213,8 +324,18 @@ func NewBuildFromSecret(secret v1.Secret) *brigade.Build {
 			Commit: sv.String("commit_id"),
 			Ref:    sv.String("commit_ref"),
 		},
-		Payload: sv.Bytes("payload"),
-		Script:  sv.Bytes("script"),
+		Priority:      sv.String("priority"),
+		Payload:       sv.Bytes("payload"),
+		Script:        sv.Bytes("script"),
+		SupersededBy:  secret.Annotations[SupersededByAnnotation],
+		Supersedes:    supersedes,
+		Traceparent:   sv.String("traceparent"),
+		Conditions:    BuildConditions(secret),
+		Attempt:       BuildAttempt(secret),
+		ParentBuildID: lbs[ParentBuildLabel],
+		RerunReason:   lbs[RerunReasonLabel],
+		RetriedBy:     secret.Annotations[RetriedByAnnotation],
+		NotBefore:     notBefore,
 	}
 }
 
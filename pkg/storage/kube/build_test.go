@@ -9,6 +9,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 )
@@ -72,6 +73,60 @@ func TestDeleteBuild(t *testing.T) {
 	}
 }
 
+func TestDeleteBuild_SkipRunningBuilds(t *testing.T) {
+	k, s := fakeStore()
+	createFakeWorker(k, stubWorkerPod)
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	pod, err := k.CoreV1().Pods("default").Get(context.TODO(), stubWorkerPod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod.Status.Phase = v1.PodRunning
+	if _, err := k.CoreV1().Pods("default").UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteBuild(stubBuild.ID, storage.DeleteBuildOptions{SkipRunningBuilds: true}); err != storage.ErrBuildRunning {
+		t.Fatalf("expected %q, got %v", storage.ErrBuildRunning, err)
+	}
+	secrets, _ := k.CoreV1().Secrets("default").List(context.TODO(), metav1.ListOptions{})
+	if len(secrets.Items) != 1 {
+		t.Fatal("running build was deleted")
+	}
+
+	if err := s.DeleteBuild(stubBuild.ID, storage.DeleteBuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	secrets, _ = k.CoreV1().Secrets("default").List(context.TODO(), metav1.ListOptions{})
+	if len(secrets.Items) != 0 {
+		t.Fatal("Build was not deleted")
+	}
+}
+
+func TestListBuilds_WithoutAPICache(t *testing.T) {
+	k, s := fakeStore()
+	createFakeWorker(k, stubWorkerPod)
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListBuilds(storage.BuildListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != stubBuild.ID || list.Items[0].Worker.ID != stubWorkerPod.Name {
+		t.Fatalf("unexpected builds %+v", list.Items)
+	}
+	// A store for a few requests lists builds without starting informers.
+	for _, a := range k.(*fake.Clientset).Actions() {
+		if a.GetVerb() == "watch" {
+			t.Errorf("unexpected watch of %s", a.GetResource().Resource)
+		}
+	}
+}
+
 func TestGetBuild(t *testing.T) {
 	k, s := fakeStore()
 	createFakeWorker(k, stubWorkerPod)
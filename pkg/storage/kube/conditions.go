@@ -0,0 +1,86 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// ConditionsAnnotation holds the conditions of the build of a build secret,
+// as a JSON array.
+const ConditionsAnnotation = "brigade.sh/conditions"
+
+// ReasonSyncFailed is the reason of a Failed condition that the controller
+// records for unexpected errors, such as a conflicting update of the build
+// secret. They are usually resolved when the controller retries.
+const ReasonSyncFailed = "SyncFailed"
+
+// BuildConditions returns the conditions of the build of a build secret. An
+// annotation that cannot be parsed is treated as no conditions.
+func BuildConditions(secret v1.Secret) []brigade.BuildCondition {
+	val := secret.Annotations[ConditionsAnnotation]
+	if val == "" {
+		return nil
+	}
+	conditions := []brigade.BuildCondition{}
+	if err := json.Unmarshal([]byte(val), &conditions); err != nil {
+		return nil
+	}
+	return conditions
+}
+
+// SetBuildConditions sets conditions on a build secret, replacing those of the
+// same types. The transition time of a condition is kept if its status does
+// not change.
+func SetBuildConditions(secret *v1.Secret, conditions ...brigade.BuildCondition) {
+	existing := BuildConditions(*secret)
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, c := range conditions {
+		c.LastTransitionTime = now
+		found := false
+		for i, e := range existing {
+			if e.Type != c.Type {
+				continue
+			}
+			if e.Status == c.Status {
+				c.LastTransitionTime = e.LastTransitionTime
+			}
+			existing[i] = c
+			found = true
+			break
+		}
+		if !found {
+			existing = append(existing, c)
+		}
+	}
+	// Conditions always marshal.
+	val, _ := json.Marshal(existing)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[ConditionsAnnotation] = string(val)
+}
+
+// PatchBuildConditions sets conditions on a build secret in the cluster,
+// without changing anything else about it.
+func PatchBuildConditions(client kubernetes.Interface, secret *v1.Secret, conditions ...brigade.BuildCondition) error {
+	secret = secret.DeepCopy()
+	SetBuildConditions(secret, conditions...)
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{ConditionsAnnotation: secret.Annotations[ConditionsAnnotation]},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Secrets(secret.Namespace).Patch(context.TODO(), secret.Name, types.MergePatchType, patch, meta.PatchOptions{})
+	return err
+}
@@ -0,0 +1,54 @@
+package kube
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestSetBuildConditions(t *testing.T) {
+	secret := &v1.Secret{}
+	SetBuildConditions(secret,
+		brigade.BuildCondition{Type: brigade.BuildAccepted, Status: brigade.ConditionTrue, Reason: "Queued"},
+		brigade.BuildCondition{Type: brigade.BuildWorkerCreated, Status: brigade.ConditionFalse, Reason: "Queued"},
+	)
+	conditions := BuildConditions(*secret)
+	if len(conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %v", conditions)
+	}
+
+	// Pretend the build was queued a while ago.
+	queuedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	for i := range conditions {
+		conditions[i].LastTransitionTime = queuedAt
+	}
+	val, _ := json.Marshal(conditions)
+	secret.Annotations[ConditionsAnnotation] = string(val)
+
+	SetBuildConditions(secret,
+		brigade.BuildCondition{Type: brigade.BuildAccepted, Status: brigade.ConditionTrue},
+		brigade.BuildCondition{Type: brigade.BuildWorkerCreated, Status: brigade.ConditionTrue, Reason: "WorkerCreated"},
+	)
+	conditions = NewBuildFromSecret(*secret).Conditions
+	if len(conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %v", conditions)
+	}
+	if c := conditions[0]; !c.LastTransitionTime.Equal(queuedAt) || c.Reason != "" {
+		t.Errorf("expected the Accepted condition to keep its transition time, got %v", c)
+	}
+	if c := conditions[1]; c.LastTransitionTime.Equal(queuedAt) || c.Status != brigade.ConditionTrue {
+		t.Errorf("expected the WorkerCreated condition to transition, got %v", c)
+	}
+}
+
+func TestBuildConditions_Invalid(t *testing.T) {
+	secret := v1.Secret{}
+	secret.Annotations = map[string]string{ConditionsAnnotation: "not json"}
+	if conditions := BuildConditions(secret); conditions != nil {
+		t.Errorf("expected no conditions, got %v", conditions)
+	}
+}
@@ -0,0 +1,124 @@
+package kube
+
+import (
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// buildEventHandler turns changes to build secrets and worker and job pods into BuildEvents.
+type buildEventHandler struct {
+	publish func(*brigade.BuildEvent)
+	// since is the time before which objects are ignored. Nothing is ignored if it is zero.
+	since time.Time
+}
+
+// NewBuildEventHandler returns a handler for secret and pod informers which
+// publishes a BuildEvent whenever a build is created or a worker or job pod
+// changes phase.
+//
+// The ID of each event is the resource version of the secret or pod, so that
+// clients can resume a stream of events from another API server, or one that
+// restarted.
+//
+// Objects created before the handler are ignored, so that the initial sync
+// of an informer does not replay the whole build history.
+func NewBuildEventHandler(publish func(*brigade.BuildEvent)) cache.ResourceEventHandler {
+	h := &buildEventHandler{
+		publish: publish,
+		since:   time.Now().Truncate(time.Second),
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    h.onAdd,
+		UpdateFunc: h.onUpdate,
+	}
+}
+
+// NewBuildRecordHandler is like NewBuildEventHandler, but also publishes an
+// event for every build, worker and job that exists when an informer starts,
+// so that a recorder of build history can catch up with changes it missed.
+func NewBuildRecordHandler(publish func(*brigade.BuildEvent)) cache.ResourceEventHandler {
+	h := &buildEventHandler{publish: publish}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    h.onAdd,
+		UpdateFunc: h.onUpdate,
+	}
+}
+
+func (h *buildEventHandler) onAdd(obj interface{}) {
+	switch o := obj.(type) {
+	case *v1.Secret:
+		if o.Type != secretTypeBuild || o.Labels["component"] != "build" || o.CreationTimestamp.Time.Before(h.since) {
+			return
+		}
+		b := NewBuildFromSecret(*o)
+		b.Script = nil
+		b.Payload = nil
+		h.publish(&brigade.BuildEvent{
+			ID:        resourceVersion(o.ObjectMeta),
+			Type:      brigade.BuildCreated,
+			Time:      time.Now(),
+			ProjectID: b.ProjectID,
+			BuildID:   b.ID,
+			Build:     b,
+		})
+	case *v1.Pod:
+		if o.CreationTimestamp.Time.Before(h.since) {
+			return
+		}
+		h.podChanged(o)
+	}
+}
+
+func (h *buildEventHandler) onUpdate(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*v1.Pod)
+	if !ok || (oldPod.Status.Phase == newPod.Status.Phase && terminatedStatus(*oldPod) == terminatedStatus(*newPod)) {
+		return
+	}
+	h.podChanged(newPod)
+}
+
+func (h *buildEventHandler) podChanged(pod *v1.Pod) {
+	if pod.Labels["heritage"] != "brigade" {
+		return
+	}
+	e := &brigade.BuildEvent{
+		ID:        resourceVersion(pod.ObjectMeta),
+		Time:      time.Now(),
+		ProjectID: pod.Labels["project"],
+		BuildID:   pod.Labels["build"],
+	}
+	switch pod.Labels["component"] {
+	case "build":
+		e.Type = brigade.WorkerPhaseChanged
+		e.Worker = NewWorkerFromPod(*pod)
+	case "job":
+		if len(pod.Spec.Containers) == 0 {
+			return
+		}
+		e.Type = brigade.JobPhaseChanged
+		e.Job = NewJobFromPod(*pod)
+	default:
+		return
+	}
+	h.publish(e)
+}
+
+// resourceVersion returns the resource version of an object as a number, or 0
+// if it is not one.
+//
+// Resource versions are opaque, but they are the revisions of etcd, which
+// increase with every change to any object.
+func resourceVersion(obj meta.ObjectMeta) uint64 {
+	v, _ := strconv.ParseUint(obj.ResourceVersion, 10, 64)
+	return v
+}
@@ -0,0 +1,94 @@
+package kube
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestBuildEventHandler(t *testing.T) {
+	var events []*brigade.BuildEvent
+	handler := NewBuildEventHandler(func(e *brigade.BuildEvent) {
+		events = append(events, e)
+	})
+
+	now := metav1.NewTime(time.Now().Add(time.Second))
+	old := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "brigade-worker-build-id",
+			CreationTimestamp: now,
+			ResourceVersion:   "41",
+			Labels:            map[string]string{"heritage": "brigade", "component": "build", "build": "build-id", "project": "project-id"},
+		},
+		Type: secretTypeBuild,
+		Data: map[string][]byte{"event_type": []byte("push"), "script": []byte("script")},
+	}
+	oldSecret := secret.DeepCopy()
+	oldSecret.CreationTimestamp = old
+
+	worker := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "brigade-worker-build-id",
+			CreationTimestamp: now,
+			Labels:            secret.Labels,
+		},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}
+	running := worker.DeepCopy()
+	running.ResourceVersion = "43"
+	running.Status.Phase = v1.PodRunning
+	running.Status.StartTime = &now
+
+	job := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-job-build-id",
+			CreationTimestamp: now,
+			Labels:            map[string]string{"heritage": "brigade", "component": "job", "build": "build-id", "project": "project-id", "jobname": "test"},
+		},
+		Spec:   v1.PodSpec{Containers: []v1.Container{{Image: "alpine"}}},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}
+
+	handler.OnAdd(oldSecret)
+	handler.OnAdd(secret)
+	handler.OnAdd(worker)
+	handler.OnUpdate(worker, worker)
+	handler.OnUpdate(worker, running)
+	handler.OnAdd(job)
+
+	expected := []brigade.BuildEventType{
+		brigade.BuildCreated,
+		brigade.WorkerPhaseChanged,
+		brigade.WorkerPhaseChanged,
+		brigade.JobPhaseChanged,
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, e := range events {
+		if e.Type != expected[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expected[i], e.Type)
+		}
+		if e.BuildID != "build-id" || e.ProjectID != "project-id" {
+			t.Errorf("event %d: unexpected build %q or project %q", i, e.BuildID, e.ProjectID)
+		}
+	}
+	if events[0].ID != 41 || events[2].ID != 43 {
+		t.Errorf("expected the resource versions as event IDs, got %d and %d", events[0].ID, events[2].ID)
+	}
+	if events[0].Build.Script != nil {
+		t.Error("expected the script to be stripped from build events")
+	}
+	if events[2].Worker.Status != brigade.JobRunning {
+		t.Errorf("expected worker to be running, got %s", events[2].Worker.Status)
+	}
+	if events[3].Job.Name != "test" {
+		t.Errorf("expected job name test, got %s", events[3].Job.Name)
+	}
+}
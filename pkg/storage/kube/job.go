@@ -5,27 +5,27 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 func (s *store) GetJob(id string) (*brigade.Job, error) {
 	labels := labels.Set{"heritage": "brigade"}
 	listOption := meta.ListOptions{LabelSelector: labels.AsSelector().String()}
-	pods, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), listOption)
+	pods, err := s.listPods(listOption)
 	if err != nil {
 		return nil, err
 	}
-	if len(pods.Items) < 1 {
+	if len(pods) < 1 {
 		return nil, fmt.Errorf("could not find job %s: no pod exists with label %s", id, labels.AsSelector().String())
 	}
-	for i := range pods.Items {
-		job := NewJobFromPod(pods.Items[i])
+	for i := range pods {
+		job := NewJobFromPod(pods[i])
 		if job.ID == id {
 			return job, nil
 		}
@@ -37,23 +37,23 @@ func (s *store) GetBuildJobs(build *brigade.Build) ([]*brigade.Job, error) {
 	// Load the pods that ran as part of this build.
 	lo := meta.ListOptions{LabelSelector: fmt.Sprintf("heritage=brigade,component=job,build=%s,project=%s", build.ID, build.ProjectID)}
 
-	podList, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), lo)
+	pods, err := s.listPods(lo)
 	if err != nil {
 		return nil, err
 	}
-	jobList := make([]*brigade.Job, len(podList.Items))
-	for i := range podList.Items {
-		jobList[i] = NewJobFromPod(podList.Items[i])
+	jobList := make([]*brigade.Job, len(pods))
+	for i := range pods {
+		jobList[i] = NewJobFromPod(pods[i])
 	}
 	return jobList, nil
 }
 
 func (s *store) GetJobLogStream(job *brigade.Job) (io.ReadCloser, error) {
-	return s.getJobLogStream(false, job)
+	return s.GetJobLogStreamWithOptions(job, storage.LogOptions{})
 }
 
 func (s *store) GetJobLogStreamFollow(job *brigade.Job) (io.ReadCloser, error) {
-	return s.getJobLogStream(true, job)
+	return s.GetJobLogStreamWithOptions(job, storage.LogOptions{Follow: true})
 }
 
 func (s *store) GetJobLog(job *brigade.Job) (string, error) {
@@ -67,12 +67,14 @@ func (s *store) GetJobLog(job *brigade.Job) (string, error) {
 	return buf.String(), nil
 }
 
-func (s *store) getJobLogStream(follow bool, job *brigade.Job) (io.ReadCloser, error) {
-	tailAllLines := int64(math.MaxInt64)
-	req := s.client.CoreV1().Pods(s.namespace).GetLogs(job.ID, &v1.PodLogOptions{
-		Follow:    follow,
-		TailLines: &tailAllLines,
-	})
+func (s *store) GetJobLogStreamWithOptions(job *brigade.Job, opts storage.LogOptions) (io.ReadCloser, error) {
+	// Jobs only have one container.
+	opts.Container = ""
+	ns, err := s.podNamespace(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	req := s.client.CoreV1().Pods(ns).GetLogs(job.ID, podLogOptions(opts))
 
 	readCloser, err := req.Stream(context.TODO())
 	if err != nil {
@@ -86,6 +88,8 @@ func NewJobFromPod(pod v1.Pod) *brigade.Job {
 	job := &brigade.Job{
 		ID:           pod.ObjectMeta.Name,
 		Name:         pod.ObjectMeta.Labels["jobname"],
+		BuildID:      pod.ObjectMeta.Labels["build"],
+		ProjectID:    pod.ObjectMeta.Labels["project"],
 		CreationTime: pod.ObjectMeta.CreationTimestamp.Time,
 		Image:        pod.Spec.Containers[0].Image,
 		Status:       brigade.JobStatus(pod.Status.Phase),
@@ -94,6 +98,9 @@ func NewJobFromPod(pod v1.Pod) *brigade.Job {
 	if (job.Status != brigade.JobPending) && (job.Status != brigade.JobUnknown) {
 		job.StartTime = pod.Status.StartTime.Time
 	}
+	if status := terminatedStatus(pod); status != "" {
+		job.Status = status
+	}
 
 	if len(pod.Status.ContainerStatuses) > 0 {
 		if pod.Status.ContainerStatuses[0].State.Terminated != nil {
@@ -101,6 +108,7 @@ func NewJobFromPod(pod v1.Pod) *brigade.Job {
 			job.ExitCode = pod.Status.ContainerStatuses[0].State.Terminated.ExitCode
 		}
 	}
+	job.Reason, job.Message = podReason(pod)
 
 	return job
 }
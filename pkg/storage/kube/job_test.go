@@ -21,6 +21,8 @@ func TestNewJobFromPod(t *testing.T) {
 			Name: "testpod-abc123",
 			Labels: map[string]string{
 				"jobname": "testpod",
+				"build":   "build-id",
+				"project": "project-id",
 			},
 			CreationTimestamp: podStartTime,
 		},
@@ -49,6 +51,8 @@ func TestNewJobFromPod(t *testing.T) {
 	expectedJob := &brigade.Job{
 		ID:           "testpod-abc123",
 		Name:         "testpod",
+		BuildID:      "build-id",
+		ProjectID:    "project-id",
 		Image:        "foo",
 		CreationTime: now,
 		StartTime:    now,
@@ -64,6 +68,26 @@ func TestNewJobFromPod(t *testing.T) {
 	}
 }
 
+func TestNewJobFromPod_Reason(t *testing.T) {
+	pod := v1.Pod{
+		Spec: v1.PodSpec{Containers: []v1.Container{{Image: "foo"}}},
+		Status: v1.PodStatus{
+			Phase:     v1.PodFailed,
+			StartTime: &metav1.Time{},
+			ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+			}}},
+		},
+	}
+	job := NewJobFromPod(pod)
+	if job.Reason != "OOMKilled" {
+		t.Errorf("expected reason OOMKilled, got %q", job.Reason)
+	}
+	if job.ExitCode != 137 {
+		t.Errorf("expected exit code 137, got %d", job.ExitCode)
+	}
+}
+
 func TestGetJob(t *testing.T) {
 	k, s := fakeStore()
 	createFakeJob(k, stubJobPod)
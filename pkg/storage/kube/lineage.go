@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// GetBuildLineage returns the rerun chain of a build: the oldest build that
+// still exists of the builds it reruns, and all the builds that rerun that
+// build, directly or not. Builds are sorted oldest first.
+func (s *store) GetBuildLineage(id string) ([]*brigade.Build, error) {
+	secrets := map[string]v1.Secret{}
+
+	// Follow the parents of the build up to the oldest one that still exists.
+	root := ""
+	for bid := id; bid != ""; {
+		if _, ok := secrets[bid]; ok {
+			break
+		}
+		found, err := s.listSecrets(meta.ListOptions{LabelSelector: "heritage=brigade,component=build,build=" + bid})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			break
+		}
+		secrets[bid] = found[0]
+		root = bid
+		bid = found[0].Labels[ParentBuildLabel]
+	}
+	if root == "" {
+		return nil, fmt.Errorf("could not find build %s", id)
+	}
+
+	// Collect the reruns of the oldest build, one generation at a time.
+	for generation := []string{root}; len(generation) > 0; {
+		selector := fmt.Sprintf("heritage=brigade,component=build,%s in (%s)", ParentBuildLabel, strings.Join(generation, ","))
+		found, err := s.listSecrets(meta.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		generation = nil
+		for _, secret := range found {
+			bid := secret.Labels["build"]
+			if _, ok := secrets[bid]; ok {
+				continue
+			}
+			secrets[bid] = secret
+			generation = append(generation, bid)
+		}
+	}
+
+	ids := make([]string, 0, len(secrets))
+	for bid := range secrets {
+		ids = append(ids, bid)
+	}
+	pods, err := s.listPods(meta.ListOptions{
+		LabelSelector: fmt.Sprintf("heritage=brigade,component=build,build in (%s)", strings.Join(ids, ",")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	builds := make([]*brigade.Build, 0, len(secrets))
+	for _, secret := range secrets {
+		b := NewBuildFromSecret(secret)
+		b.Worker, _ = findWorker(b.ID, pods)
+		if b.Worker == nil {
+			b.Worker = NewWorkerFromSecret(secret)
+		}
+		builds = append(builds, b)
+	}
+	// Build IDs are ULIDs, which sort in the order they were generated.
+	sort.Slice(builds, func(i, j int) bool { return builds[i].ID < builds[j].ID })
+	return builds, s.setQueuePositions(builds...)
+}
@@ -0,0 +1,48 @@
+package kube
+
+import (
+	"testing"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestGetBuildLineage(t *testing.T) {
+	_, s := fakeStore()
+	for _, b := range []*brigade.Build{
+		{ID: "01a-original"},
+		{ID: "01b-rerun", ParentBuildID: "01a-original"},
+		{ID: "01c-retry", ParentBuildID: "01b-rerun", RerunReason: brigade.RerunRetry},
+		{ID: "01d-other-rerun", ParentBuildID: "01a-original"},
+		{ID: "01e-unrelated"},
+	} {
+		b.ProjectID = stubProjectID
+		b.Revision = &brigade.Revision{}
+		if err := s.CreateBuild(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	builds, err := s.GetBuildLineage("01c-retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"01a-original", "01b-rerun", "01c-retry", "01d-other-rerun"}
+	if len(builds) != len(expect) {
+		t.Fatalf("expected builds %v, got %d builds", expect, len(builds))
+	}
+	for i, b := range builds {
+		if b.ID != expect[i] {
+			t.Errorf("expected build %d to be %s, got %s", i, expect[i], b.ID)
+		}
+	}
+	if r := builds[1].RerunReason; r != brigade.RerunManual {
+		t.Errorf("expected the rerun to be manual, got %q", r)
+	}
+	if r := builds[2].RerunReason; r != brigade.RerunRetry {
+		t.Errorf("expected the retry to be a retry, got %q", r)
+	}
+
+	if _, err := s.GetBuildLineage("01f-missing"); err == nil {
+		t.Error("expected an error for a missing build")
+	}
+}
@@ -0,0 +1,123 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+// listSecrets lists the secrets of every namespace of the store.
+func (s *store) listSecrets(opts meta.ListOptions) ([]v1.Secret, error) {
+	list, err := s.client.CoreV1().Secrets(s.namespaces.Watch()).List(context.TODO(), opts)
+	if err != nil {
+		return nil, err
+	}
+	secrets := []v1.Secret{}
+	for _, secret := range list.Items {
+		if namespaces.Watched(s.namespaces, secret.Namespace) {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets, nil
+}
+
+// listPods lists the pods of every namespace of the store.
+func (s *store) listPods(opts meta.ListOptions) ([]v1.Pod, error) {
+	list, err := s.client.CoreV1().Pods(s.namespaces.Watch()).List(context.TODO(), opts)
+	if err != nil {
+		return nil, err
+	}
+	pods := []v1.Pod{}
+	for _, pod := range list.Items {
+		if namespaces.Watched(s.namespaces, pod.Namespace) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// projectSecret returns the secret of a project, from whichever namespace of
+// the store it lives in.
+func (s *store) projectSecret(id string) (*v1.Secret, error) {
+	if ns := s.namespaces.Watch(); ns != meta.NamespaceAll {
+		return s.client.CoreV1().Secrets(ns).Get(context.TODO(), id, meta.GetOptions{})
+	}
+	secrets, err := s.listSecrets(meta.ListOptions{
+		LabelSelector: "app=brigade,component=project",
+		FieldSelector: "metadata.name=" + id,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var found *v1.Secret
+	for i := range secrets {
+		if secrets[i].Name != id {
+			continue
+		}
+		// Projects created by Brigade have IDs that are unique across
+		// namespaces, but secrets created by other means may not.
+		if found != nil {
+			return nil, fmt.Errorf("project %s exists in namespaces %s and %s", id, found.Namespace, secrets[i].Namespace)
+		}
+		found = &secrets[i]
+	}
+	if found == nil {
+		return nil, apierrors.NewNotFound(v1.Resource("secrets"), id)
+	}
+	return found, nil
+}
+
+// projectNamespace returns the namespace that a project, and so its builds,
+// live in.
+func (s *store) projectNamespace(id string) (string, error) {
+	if ns := s.namespaces.Watch(); ns != meta.NamespaceAll {
+		return ns, nil
+	}
+	secret, err := s.projectSecret(id)
+	if err != nil {
+		return "", err
+	}
+	return secret.Namespace, nil
+}
+
+// podNamespace returns the namespace of a worker or job pod.
+func (s *store) podNamespace(name string) (string, error) {
+	if ns := s.namespaces.Watch(); ns != meta.NamespaceAll {
+		return ns, nil
+	}
+	pods, err := s.listPods(meta.ListOptions{
+		LabelSelector: "heritage=brigade",
+		FieldSelector: "metadata.name=" + name,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, p := range pods {
+		if p.Name == name {
+			return p.Namespace, nil
+		}
+	}
+	return "", fmt.Errorf("could not find pod %s", name)
+}
+
+// buildNamespace returns the namespace that a build lives in.
+func (s *store) buildNamespace(bid string) (string, error) {
+	if ns := s.namespaces.Watch(); ns != meta.NamespaceAll {
+		return ns, nil
+	}
+	secrets, err := s.listSecrets(meta.ListOptions{
+		LabelSelector: fmt.Sprint("heritage=brigade,component=build,build=", bid),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(secrets) < 1 {
+		return "", fmt.Errorf("could not find build %s", bid)
+	}
+	return secrets[0].Namespace, nil
+}
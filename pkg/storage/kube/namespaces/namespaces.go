@@ -0,0 +1,173 @@
+// Package namespaces describes the Kubernetes namespaces that Brigade
+// projects, builds and jobs live in.
+//
+// A Brigade install lives in its home namespace. By default, its projects live
+// there too. It can also serve projects that live in a list of other
+// namespaces, or in every namespace with a label.
+package namespaces
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// lookupTimeout is how long Contains waits for a namespace to be read from the
+// API server while the namespaces matching a selector are being loaded.
+const lookupTimeout = 5 * time.Second
+
+// Set is a set of namespaces.
+type Set interface {
+	// Home returns the namespace of the Brigade install, which is always in
+	// the set. Projects that do not name another namespace of the set live
+	// there.
+	Home() string
+	// Watch returns the namespace to list and watch objects in: the home
+	// namespace if it is the only namespace of the set, or
+	// meta.NamespaceAll otherwise, in which case objects have to be filtered
+	// with Contains.
+	Watch() string
+	// Contains returns true if namespace is in the set.
+	Contains(namespace string) bool
+}
+
+// Single returns the set of the home namespace only.
+func Single(home string) Set {
+	return single(home)
+}
+
+type single string
+
+func (s single) Home() string                   { return string(s) }
+func (s single) Watch() string                  { return string(s) }
+func (s single) Contains(namespace string) bool { return namespace == string(s) }
+
+// List returns the set of the home namespace and the given namespaces.
+func List(home string, names ...string) Set {
+	l := list{home: home, names: map[string]bool{home: true}}
+	for _, n := range names {
+		l.names[n] = true
+	}
+	if len(l.names) == 1 {
+		return Single(home)
+	}
+	return l
+}
+
+type list struct {
+	home  string
+	names map[string]bool
+}
+
+func (l list) Home() string                   { return l.home }
+func (l list) Watch() string                  { return meta.NamespaceAll }
+func (l list) Contains(namespace string) bool { return l.names[namespace] }
+
+// Selector returns the set of the home namespace and the namespaces matching
+// a label selector. The namespaces are watched, so that namespaces that are
+// labelled later join the set.
+func Selector(client kubernetes.Interface, home, selector string) (Set, error) {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace selector %q: %s", selector, err)
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return client.CoreV1().Namespaces().List(context.TODO(), options)
+		},
+		WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return client.CoreV1().Namespaces().Watch(context.TODO(), options)
+		},
+	}
+	store, ctr := cache.NewInformer(lw, &v1.Namespace{}, 0, cache.ResourceEventHandlerFuncs{})
+	// Like the API cache, the informer runs for the life of the process.
+	go ctr.Run(nil)
+	return &selected{client: client, home: home, selector: parsed, store: store, hasSynced: ctr.HasSynced}, nil
+}
+
+type selected struct {
+	client    kubernetes.Interface
+	home      string
+	selector  labels.Selector
+	store     cache.Store
+	hasSynced func() bool
+}
+
+func (s *selected) Home() string  { return s.home }
+func (s *selected) Watch() string { return meta.NamespaceAll }
+
+func (s *selected) Contains(namespace string) bool {
+	if namespace == s.home {
+		return true
+	}
+	// Requests do not wait for the informer to sync, which takes as long as
+	// the API server takes to list every matching namespace.
+	if !s.hasSynced() {
+		return s.lookup(namespace)
+	}
+	_, exists, _ := s.store.GetByKey(namespace)
+	return exists
+}
+
+// lookup reads a namespace from the API server, and returns true if it
+// matches the selector.
+func (s *selected) lookup(namespace string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	ns, err := s.client.CoreV1().Namespaces().Get(ctx, namespace, meta.GetOptions{})
+	return err == nil && s.selector.Matches(labels.Set(ns.Labels))
+}
+
+// Config configures the namespaces of a Brigade install besides its home
+// namespace.
+type Config struct {
+	// Names are namespaces that projects live in besides the home namespace.
+	Names string
+	// Selector is a label selector of namespaces that projects live in
+	// besides the home namespace. It can not be used with Names.
+	Selector string
+}
+
+// AddFlags adds flags for the namespace configuration to fs. Their defaults
+// are read from the environment.
+func (c *Config) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Names, "namespaces", os.Getenv("BRIGADE_NAMESPACES"), "comma separated namespaces that projects live in besides --namespace")
+	fs.StringVar(&c.Selector, "namespace-selector", os.Getenv("BRIGADE_NAMESPACE_SELECTOR"), "label selector of namespaces that projects live in besides --namespace, if given --namespaces can not be")
+}
+
+// NewSet creates the set of the home namespace and the namespaces described
+// by c.
+func NewSet(client kubernetes.Interface, home string, c Config) (Set, error) {
+	names := []string{}
+	for _, n := range strings.Split(c.Names, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if c.Selector == "" {
+		return List(home, names...), nil
+	}
+	if len(names) > 0 {
+		return nil, fmt.Errorf("namespaces and a namespace selector can not both be given")
+	}
+	return Selector(client, home, c.Selector)
+}
+
+// Watched returns true if an object listed or watched in set.Watch() lives in
+// a namespace of the set.
+func Watched(set Set, namespace string) bool {
+	return set.Watch() != meta.NamespaceAll || set.Contains(namespace)
+}
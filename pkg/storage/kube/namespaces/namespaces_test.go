@@ -0,0 +1,88 @@
+package namespaces
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNewSet(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for name, team := range map[string]string{"ahab": "whalers", "starbuck": "whalers", "ishmael": ""} {
+		client.CoreV1().Namespaces().Create(context.TODO(), &v1.Namespace{
+			ObjectMeta: meta.ObjectMeta{Name: name, Labels: map[string]string{"team": team}},
+		}, meta.CreateOptions{})
+	}
+
+	tests := []struct {
+		name     string
+		config   Config
+		watch    string
+		contains map[string]bool
+	}{
+		{"single", Config{}, "brigade", map[string]bool{"brigade": true, "ahab": false}},
+		{"home only", Config{Names: " brigade, "}, "brigade", map[string]bool{"brigade": true}},
+		{"list", Config{Names: "ahab,ishmael"}, meta.NamespaceAll, map[string]bool{"brigade": true, "ahab": true, "ishmael": true, "starbuck": false}},
+		{"selector", Config{Selector: "team=whalers"}, meta.NamespaceAll, map[string]bool{"brigade": true, "ahab": true, "starbuck": true, "ishmael": false}},
+	}
+	for _, tt := range tests {
+		set, err := NewSet(client, "brigade", tt.config)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if set.Home() != "brigade" {
+			t.Errorf("%s: expected home namespace brigade, got %q", tt.name, set.Home())
+		}
+		if set.Watch() != tt.watch {
+			t.Errorf("%s: expected to watch %q, got %q", tt.name, tt.watch, set.Watch())
+		}
+		for ns, expect := range tt.contains {
+			if set.Contains(ns) != expect {
+				t.Errorf("%s: expected Contains(%q) to be %t", tt.name, ns, expect)
+			}
+		}
+	}
+
+	if _, err := NewSet(client, "brigade", Config{Names: "ahab", Selector: "team=whalers"}); err == nil {
+		t.Error("expected an error for namespaces and a selector")
+	}
+	if _, err := NewSet(client, "brigade", Config{Selector: "team in"}); err == nil {
+		t.Error("expected an error for an invalid selector")
+	}
+}
+
+func TestSelectorContains_NotSynced(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for name, team := range map[string]string{"ahab": "whalers", "ishmael": ""} {
+		client.CoreV1().Namespaces().Create(context.TODO(), &v1.Namespace{
+			ObjectMeta: meta.ObjectMeta{Name: name, Labels: map[string]string{"team": team}},
+		}, meta.CreateOptions{})
+	}
+	// The namespaces are never listed, so the informer never syncs.
+	release := make(chan struct{})
+	defer close(release)
+	client.PrependReactor("list", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+
+	set, err := Selector(client, "brigade", "team=whalers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for ns, expect := range map[string]bool{"brigade": true, "ahab": true, "ishmael": false, "starbuck": false} {
+		if set.Contains(ns) != expect {
+			t.Errorf("expected Contains(%q) to be %t", ns, expect)
+		}
+	}
+	if d := time.Since(start); d > lookupTimeout {
+		t.Errorf("expected Contains not to wait for the namespaces to be listed, took %s", d)
+	}
+}
@@ -0,0 +1,139 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+func TestStoreForNamespaces(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	set := namespaces.List("brigade", "ahab")
+	s := NewForNamespaces(client, set, apicache.NewForNamespaces(client, set, DefaultCacheResyncPeriod))
+
+	// A project of a namespace outside of the set is not served.
+	outside := stubProjectSecret.DeepCopy()
+	outside.Name = "brigade-outside"
+	client.CoreV1().Secrets("ishmael").Create(context.TODO(), outside, meta.CreateOptions{})
+
+	if err := s.CreateProject(&brigade.Project{Name: "ahab/pequod", Kubernetes: brigade.Kubernetes{Namespace: "ahab"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateProject(&brigade.Project{Name: "brigadecore/home", Kubernetes: brigade.Kubernetes{Namespace: "ishmael"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Projects outside of the home namespace have IDs qualified by their namespace.
+	pequod := brigade.NamespacedProjectID("ahab", "ahab/pequod")
+	home := brigade.ProjectID("brigadecore/home")
+	if _, err := client.CoreV1().Secrets("ahab").Get(context.TODO(), pequod, meta.GetOptions{}); err != nil {
+		t.Errorf("expected the project to be created in its namespace: %s", err)
+	}
+	if _, err := client.CoreV1().Secrets("brigade").Get(context.TODO(), home, meta.GetOptions{}); err != nil {
+		t.Errorf("expected a project of a namespace outside of the set to be created in the home namespace: %s", err)
+	}
+
+	projects, err := s.GetProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 {
+		t.Errorf("expected 2 projects, got %d", len(projects))
+	}
+	p, err := s.GetProject(pequod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Kubernetes.Namespace != "ahab" {
+		t.Errorf("expected project namespace ahab, got %q", p.Kubernetes.Namespace)
+	}
+	if _, err := s.GetProject(outside.Name); err == nil {
+		t.Error("expected a project outside of the set not to be found")
+	}
+	// A project is found by its qualified name, and by its name while that is
+	// used by a single project.
+	for _, name := range []string{"ahab/ahab/pequod", "ahab/pequod"} {
+		if p, err := s.GetProject(name); err != nil || p.ID != pequod {
+			t.Errorf("expected %s to be project %s, got %v, %v", name, pequod, p, err)
+		}
+	}
+
+	build := &brigade.Build{ID: "01-pequod", ProjectID: pequod, Revision: &brigade.Revision{}}
+	if err := s.CreateBuild(build); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Secrets("ahab").Get(context.TODO(), "brigade-worker-01-pequod", meta.GetOptions{}); err != nil {
+		t.Errorf("expected the build to be created in the namespace of its project: %s", err)
+	}
+	client.CoreV1().Pods("ahab").Create(context.TODO(), &v1.Pod{
+		ObjectMeta: meta.ObjectMeta{
+			Name:   "brigade-worker-01-pequod",
+			Labels: map[string]string{"heritage": "brigade", "component": "build", "build": "01-pequod", "project": pequod},
+		},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}, meta.CreateOptions{})
+
+	b, err := s.GetBuild("01-pequod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Worker == nil || b.Worker.Status != brigade.JobPending {
+		t.Errorf("expected a pending worker, got %+v", b.Worker)
+	}
+	list, err := s.ListBuilds(storage.BuildListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != "01-pequod" {
+		t.Errorf("expected to list build 01-pequod, got %+v", list.Items)
+	}
+
+	if err := s.CancelBuild("01-pequod"); err != nil {
+		t.Fatal(err)
+	}
+	secret, err := client.CoreV1().Secrets("ahab").Get(context.TODO(), "brigade-worker-01-pequod", meta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Labels["status"] != StatusCancelled {
+		t.Errorf("expected the build to be cancelled, got status %q", secret.Labels["status"])
+	}
+
+	if err := s.DeleteProject(pequod); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Secrets("ahab").Get(context.TODO(), pequod, meta.GetOptions{}); err == nil {
+		t.Error("expected the project to be deleted from its namespace")
+	}
+}
+
+func TestStoreForNamespaces_SameProjectName(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	set := namespaces.List("brigade", "ahab", "starbuck")
+	s := NewForNamespaces(client, set, apicache.NewForNamespaces(client, set, DefaultCacheResyncPeriod))
+
+	for _, ns := range []string{"ahab", "starbuck"} {
+		if err := s.CreateProject(&brigade.Project{Name: "whalers/pequod", Kubernetes: brigade.Kubernetes{Namespace: ns}}); err != nil {
+			t.Fatalf("%s: %s", ns, err)
+		}
+	}
+	for _, ns := range []string{"ahab", "starbuck"} {
+		p, err := s.GetProject(ns + "/whalers/pequod")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != brigade.NamespacedProjectID(ns, "whalers/pequod") || p.Kubernetes.Namespace != ns {
+			t.Errorf("expected the project of %s, got %s in %s", ns, p.ID, p.Kubernetes.Namespace)
+		}
+	}
+	if _, err := s.GetProject("whalers/pequod"); err == nil {
+		t.Error("expected a name used in several namespaces to be ambiguous")
+	}
+}
@@ -0,0 +1,97 @@
+package kube
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PriorityClasses gets the PriorityClasses of the workers of builds.
+type PriorityClasses interface {
+	// Get returns a PriorityClass, or an error for which apierrors.IsNotFound
+	// is true if it does not exist.
+	Get(name string) (*schedulingv1.PriorityClass, error)
+}
+
+// clientPriorityClasses gets PriorityClasses from the API server.
+type clientPriorityClasses struct {
+	client kubernetes.Interface
+}
+
+func (c clientPriorityClasses) Get(name string) (*schedulingv1.PriorityClass, error) {
+	return c.client.SchedulingV1().PriorityClasses().Get(context.TODO(), name, meta.GetOptions{})
+}
+
+// PriorityClassCache gets PriorityClasses from an informer, so that ordering
+// the queue does not read them from the API server every time.
+type PriorityClassCache struct {
+	client   kubernetes.Interface
+	store    cache.Store
+	informer cache.Controller
+}
+
+// NewPriorityClassCache creates a PriorityClassCache, which is filled once it
+// runs.
+func NewPriorityClassCache(client kubernetes.Interface, resyncPeriod time.Duration) *PriorityClassCache {
+	p := &PriorityClassCache{client: client}
+	p.store, p.informer = cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+				return client.SchedulingV1().PriorityClasses().List(context.TODO(), options)
+			},
+			WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+				return client.SchedulingV1().PriorityClasses().Watch(context.TODO(), options)
+			},
+		},
+		&schedulingv1.PriorityClass{},
+		resyncPeriod,
+		cache.ResourceEventHandlerFuncs{},
+	)
+	return p
+}
+
+// Run watches PriorityClasses until stopCh is closed.
+func (p *PriorityClassCache) Run(stopCh <-chan struct{}) {
+	p.informer.Run(stopCh)
+}
+
+// Get returns a PriorityClass. Until the cache has synced, PriorityClasses
+// are read from the API server.
+func (p *PriorityClassCache) Get(name string) (*schedulingv1.PriorityClass, error) {
+	if !p.informer.HasSynced() {
+		return clientPriorityClasses{p.client}.Get(name)
+	}
+	obj, exists, err := p.store.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(schedulingv1.Resource("priorityclasses"), name)
+	}
+	return obj.(*schedulingv1.PriorityClass), nil
+}
+
+// lazyPriorityClasses is a PriorityClassCache which starts the first time it
+// is used, so that stores which never order the queue do not watch
+// PriorityClasses.
+type lazyPriorityClasses struct {
+	once  sync.Once
+	new   func() *PriorityClassCache
+	cache *PriorityClassCache
+}
+
+func (l *lazyPriorityClasses) Get(name string) (*schedulingv1.PriorityClass, error) {
+	l.once.Do(func() {
+		l.cache = l.new()
+		go l.cache.Run(nil)
+	})
+	return l.cache.Get(name)
+}
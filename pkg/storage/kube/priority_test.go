@@ -0,0 +1,44 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPriorityClassCache(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	pc := &schedulingv1.PriorityClass{ObjectMeta: meta.ObjectMeta{Name: "urgent"}, Value: 1000}
+	if _, err := client.SchedulingV1().PriorityClasses().Create(context.TODO(), pc, meta.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPriorityClassCache(client, 0)
+	// PriorityClasses are read from the API server until the cache syncs.
+	if got, err := p.Get("urgent"); err != nil || got.Value != 1000 {
+		t.Fatalf("expected urgent from the API server, got %v, %v", got, err)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go p.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, p.informer.HasSynced) {
+		t.Fatal("cache did not sync")
+	}
+	// Reads no longer reach the API server.
+	client.ClearActions()
+	if got, err := p.Get("urgent"); err != nil || got.Value != 1000 {
+		t.Errorf("expected urgent from the cache, got %v, %v", got, err)
+	}
+	if _, err := p.Get("missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no requests to the API server, got %v", actions)
+	}
+}
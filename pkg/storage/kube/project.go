@@ -5,39 +5,78 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"strconv"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 const secretTypeProject = "brigade.sh/project"
 
 // GetProjects retrieves all projects from storage.
+//
+// Projects that cannot be read are logged and left out, so that one malformed
+// project does not hide the others.
 func (s *store) GetProjects() ([]*brigade.Project, error) {
 	lo := meta.ListOptions{LabelSelector: "app=brigade,component=project"}
-	secretList, err := s.client.CoreV1().Secrets(s.namespace).List(context.TODO(), lo)
+	secrets, err := s.listSecrets(lo)
 	if err != nil {
 		return nil, err
 	}
-	projList := make([]*brigade.Project, len(secretList.Items))
-	for i := range secretList.Items {
-		var err error
-		projList[i], err = NewProjectFromSecret(&secretList.Items[i], s.namespace)
+	projList := make([]*brigade.Project, 0, len(secrets))
+	for i := range secrets {
+		proj, err := NewProjectFromSecret(&secrets[i], def(secrets[i].Namespace, s.namespace))
 		if err != nil {
-			return nil, err
+			log.Printf("skipping project %s/%s: %s", secrets[i].Namespace, secrets[i].Name, err)
+			continue
 		}
+		projList = append(projList, proj)
 	}
 	return projList, nil
 }
 
 // GetProject retrieves the project from storage.
+//
+// id may be the ID of a project, its name if it lives in the home namespace,
+// or "<namespace>/<name>" otherwise. A name that is not found in the home
+// namespace is looked up in the other namespaces of the store, where it has
+// to be used by a single project.
 func (s *store) GetProject(id string) (*brigade.Project, error) {
-	return s.loadProjectConfig(brigade.ProjectID(id))
+	proj, err := s.loadProjectConfig(brigade.ProjectID(id))
+	if apierrors.IsNotFound(err) && !strings.HasPrefix(id, "brigade-") && s.namespaces.Watch() == meta.NamespaceAll {
+		return s.projectByName(id)
+	}
+	return proj, err
+}
+
+// projectByName returns the only project named name of the namespaces of the
+// store.
+func (s *store) projectByName(name string) (*brigade.Project, error) {
+	secrets, err := s.listSecrets(meta.ListOptions{LabelSelector: "app=brigade,component=project"})
+	if err != nil {
+		return nil, err
+	}
+	var found *v1.Secret
+	for i := range secrets {
+		if secrets[i].Annotations["projectName"] != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("project name %s is used in namespaces %s and %s, use <namespace>/%s", name, found.Namespace, secrets[i].Namespace, name)
+		}
+		found = &secrets[i]
+	}
+	if found == nil {
+		return nil, apierrors.NewNotFound(v1.Resource("secrets"), brigade.ProjectID(name))
+	}
+	return NewProjectFromSecret(found, def(found.Namespace, s.namespace))
 }
 
 // SecretFromProject takes a project and converts it to a Kubernetes Secret.
@@ -49,6 +88,9 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 	if project.ID == "" {
 		project.ID = brigade.ProjectID(project.Name)
 	}
+	if err := brigade.ValidateBuildTimeout(project.BuildTimeout); err != nil {
+		return v1.Secret{}, err
+	}
 
 	// The marshal on SecretsMap redacts secrets, so we cast and marshal as a raw
 	// map[string]interface{}
@@ -57,6 +99,12 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 	if err != nil {
 		return v1.Secret{}, err
 	}
+	schedulesJSON := []byte{}
+	if len(project.Schedules) > 0 {
+		if schedulesJSON, err = json.Marshal(project.Schedules); err != nil {
+			return v1.Secret{}, err
+		}
+	}
 
 	bfmt := func(b bool) string { return fmt.Sprintf("%t", b) }
 
@@ -79,6 +127,8 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 			"github.baseURL":   project.Github.BaseURL,
 			"github.uploadURL": project.Github.UploadURL,
 
+			"github.buildUntrusted": strconv.FormatBool(project.Github.BuildUntrusted),
+
 			"vcsSidecar":        project.Kubernetes.VCSSidecar,
 			"namespace":         project.Kubernetes.Namespace,
 			"serviceAccount":    project.Kubernetes.ServiceAccount,
@@ -93,7 +143,8 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 			"sshCert":    project.Repo.SSHCert,
 			"cloneURL":   project.Repo.CloneURL,
 
-			"secrets": string(secretsJSON),
+			"secrets":   string(secretsJSON),
+			"schedules": string(schedulesJSON),
 
 			"worker.registry":   project.Worker.Registry,
 			"worker.name":       project.Worker.Name,
@@ -106,6 +157,16 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 			"allowPrivilegedJobs":  bfmt(project.AllowPrivilegedJobs),
 			"allowHostMounts":      bfmt(project.AllowHostMounts),
 			"workerCommand":        project.WorkerCommand,
+			"buildTimeout":         project.BuildTimeout,
+			"maxConcurrentBuilds":  strconv.Itoa(project.MaxConcurrentBuilds),
+			"supersedeBuilds":      bfmt(project.SupersedeBuilds),
+			"defaultPriority":      project.DefaultPriority,
+			"allowedPriorities":    project.AllowedPriorities,
+			"schedulingWeight":     strconv.Itoa(project.SchedulingWeight),
+			"retry.maxAttempts":    strconv.Itoa(project.Retry.MaxAttempts),
+			"retry.backoff":        project.Retry.Backoff,
+			"retry.exitCodes":      joinExitCodes(project.Retry.ExitCodes),
+			"retry.reasons":        strings.Join(project.Retry.Reasons, ","),
 			"brigadejsPath":        project.BrigadejsPath,
 			"brigadeConfigPath":    project.BrigadeConfigPath,
 			"genericGatewaySecret": project.GenericGatewaySecret,
@@ -123,13 +184,30 @@ func SecretFromProject(project *brigade.Project) (v1.Secret, error) {
 // Project Name is a required field. If not present, Project ID will be calculated
 // from project name. This is preferred.
 //
+// The project is stored in its Kubernetes namespace if that is one of the
+// store's namespaces, and in the home namespace otherwise. The ID of a project
+// stored outside of the home namespace is brigade.NamespacedProjectID of its
+// namespace and name, unless it is given.
+//
+// If a project with the same ID exists, storage.ErrProjectExists is returned.
+//
 // Note that project secrets are not redacted.
 func (s *store) CreateProject(project *brigade.Project) error {
+	ns := s.namespace
+	if project.Kubernetes.Namespace != "" && s.namespaces.Contains(project.Kubernetes.Namespace) {
+		ns = project.Kubernetes.Namespace
+	}
+	if project.ID == "" && project.Name != "" && ns != s.namespace {
+		project.ID = brigade.NamespacedProjectID(ns, project.Name)
+	}
 	secret, err := SecretFromProject(project)
 	if err != nil {
 		return err
 	}
-	_, err = s.client.CoreV1().Secrets(s.namespace).Create(context.TODO(), &secret, meta.CreateOptions{})
+	_, err = s.client.CoreV1().Secrets(ns).Create(context.TODO(), &secret, meta.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return storage.ErrProjectExists
+	}
 	return err
 }
 
@@ -144,15 +222,23 @@ func (s *store) ReplaceProject(project *brigade.Project) error {
 	if err != nil {
 		return err
 	}
+	ns, err := s.projectNamespace(project.ID)
+	if err != nil {
+		return err
+	}
 
-	_, err = s.client.CoreV1().Secrets(s.namespace).Update(context.TODO(), &secret, meta.UpdateOptions{})
+	_, err = s.client.CoreV1().Secrets(ns).Update(context.TODO(), &secret, meta.UpdateOptions{})
 
 	return err
 }
 
 // DeleteProject deletes a project from storage.
 func (s *store) DeleteProject(id string) error {
-	return s.client.CoreV1().Secrets(s.namespace).Delete(context.TODO(), id, meta.DeleteOptions{})
+	ns, err := s.projectNamespace(id)
+	if err != nil {
+		return err
+	}
+	return s.client.CoreV1().Secrets(ns).Delete(context.TODO(), id, meta.DeleteOptions{})
 }
 
 // loadProjectConfig loads a project config from inside of Kubernetes.
@@ -160,11 +246,11 @@ func (s *store) DeleteProject(id string) error {
 // The namespace is the namespace where the secret is stored.
 func (s *store) loadProjectConfig(id string) (*brigade.Project, error) {
 	// The project config is stored in a secret.
-	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(context.TODO(), id, meta.GetOptions{})
+	secret, err := s.projectSecret(id)
 	if err != nil {
 		return nil, err
 	}
-	return NewProjectFromSecret(secret, s.namespace)
+	return NewProjectFromSecret(secret, def(secret.Namespace, s.namespace))
 }
 
 // NewProjectFromSecret creates a new project from a secret.
@@ -179,6 +265,13 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	proj.Github.Token = sv.String("github.token")
 	proj.Github.BaseURL = sv.String("github.baseURL")
 	proj.Github.UploadURL = sv.String("github.uploadURL")
+	if v := sv.String("github.buildUntrusted"); v != "" {
+		buildUntrusted, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing 'github.buildUntrusted': %s", err)
+		}
+		proj.Github.BuildUntrusted = buildUntrusted
+	}
 
 	proj.Kubernetes.VCSSidecar = sv.String("vcsSidecar")
 	proj.Kubernetes.Namespace = def(sv.String("namespace"), namespace)
@@ -216,6 +309,12 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	}
 	proj.Secrets = envVars
 
+	if d := sv.Bytes("schedules"); len(d) > 0 {
+		if err := json.Unmarshal(d, &proj.Schedules); err != nil {
+			return nil, fmt.Errorf("error parsing 'schedules': %s", err.Error())
+		}
+	}
+
 	proj.GenericGatewaySecret = sv.String("genericGatewaySecret")
 
 	proj.Worker = brigade.WorkerConfig{
@@ -229,13 +328,59 @@ func NewProjectFromSecret(secret *v1.Secret, namespace string) (*brigade.Project
 	proj.InitGitSubmodules = strings.ToLower(def(sv.String("initGitSubmodules"), "false")) == "true"
 	proj.AllowPrivilegedJobs = strings.ToLower(def(sv.String("allowPrivilegedJobs"), "true")) == "true"
 	proj.AllowHostMounts = strings.ToLower(def(sv.String("allowHostMounts"), "false")) == "true"
+	proj.SupersedeBuilds = strings.ToLower(def(sv.String("supersedeBuilds"), "false")) == "true"
 	proj.ImagePullSecrets = sv.String("imagePullSecrets")
 
 	proj.BrigadejsPath = sv.String("brigadejsPath")
 	proj.WorkerCommand = sv.String("workerCommand")
+	proj.BuildTimeout = sv.String("buildTimeout")
+	proj.MaxConcurrentBuilds, _ = strconv.Atoi(def(sv.String("maxConcurrentBuilds"), "0"))
+	proj.DefaultPriority = sv.String("defaultPriority")
+	proj.AllowedPriorities = sv.String("allowedPriorities")
+	proj.SchedulingWeight, _ = strconv.Atoi(def(sv.String("schedulingWeight"), "0"))
+
+	proj.Retry.MaxAttempts, _ = strconv.Atoi(def(sv.String("retry.maxAttempts"), "0"))
+	proj.Retry.Backoff = sv.String("retry.backoff")
+	if reasons := sv.String("retry.reasons"); reasons != "" {
+		proj.Retry.Reasons = strings.Split(reasons, ",")
+	}
+	// A project whose retry policy is broken is still served, but its failed
+	// builds are not retried.
+	exitCodes, err := splitExitCodes(sv.String("retry.exitCodes"))
+	if err != nil {
+		log.Printf("ignoring the retry policy of project %s: error parsing 'retry.exitCodes': %s", proj.ID, err)
+		proj.Retry = brigade.RetryPolicy{}
+	} else {
+		proj.Retry.ExitCodes = exitCodes
+	}
 	return proj, nil
 }
 
+// joinExitCodes joins exit codes with commas.
+func joinExitCodes(codes []int32) string {
+	s := make([]string, len(codes))
+	for i, c := range codes {
+		s[i] = strconv.Itoa(int(c))
+	}
+	return strings.Join(s, ",")
+}
+
+// splitExitCodes splits comma separated exit codes.
+func splitExitCodes(s string) ([]int32, error) {
+	if s == "" {
+		return nil, nil
+	}
+	codes := []int32{}
+	for _, c := range strings.Split(s, ",") {
+		code, err := strconv.ParseInt(strings.TrimSpace(c), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, int32(code))
+	}
+	return codes, nil
+}
+
 func def(a, b string) string {
 	if len(a) == 0 {
 		return b
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 func TestGetProjects(t *testing.T) {
@@ -23,6 +25,19 @@ func TestGetProjects(t *testing.T) {
 	if len(projects) != 1 {
 		t.Fatalf("expected one project, got %d", len(projects))
 	}
+
+	// A malformed project does not hide the others.
+	malformed := stubProjectSecret.DeepCopy()
+	malformed.Name = "brigade-malformed"
+	malformed.Data = map[string][]byte{"schedules": []byte("{")}
+	createFakeProject(k, malformed)
+	projects, err = s.GetProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 1 || projects[0].ID != stubProjectID {
+		t.Errorf("expected the malformed project to be skipped, got %v", projects)
+	}
 }
 
 func TestGetProject(t *testing.T) {
@@ -122,6 +137,7 @@ func TestCreateProject(t *testing.T) {
 		"github.token":                 proj.Github.Token,
 		"github.baseURL":               proj.Github.BaseURL,
 		"github.uploadURL":             proj.Github.UploadURL,
+		"github.buildUntrusted":        fmt.Sprintf("%t", proj.Github.BuildUntrusted),
 		"vcsSidecar":                   proj.Kubernetes.VCSSidecar,
 		"namespace":                    proj.Kubernetes.Namespace,
 		"serviceAccount":               proj.Kubernetes.ServiceAccount,
@@ -150,6 +166,18 @@ func TestCreateProject(t *testing.T) {
 			t.Errorf("For key %s, got %q, want %q", key, got, want)
 		}
 	}
+
+	if err := s.CreateProject(proj); err != storage.ErrProjectExists {
+		t.Errorf("expected %q creating the project again, got %v", storage.ErrProjectExists, err)
+	}
+}
+
+func TestCreateProject_InvalidBuildTimeout(t *testing.T) {
+	_, s := fakeStore()
+	proj := &brigade.Project{Name: "tennyson/light-brigade", BuildTimeout: "half a league"}
+	if err := s.CreateProject(proj); err == nil {
+		t.Error("expected a project with an invalid build timeout not to be created")
+	}
 }
 
 func TestReplaceProject(t *testing.T) {
@@ -349,3 +377,72 @@ func TestDef(t *testing.T) {
 		t.Error("Expected non-default value")
 	}
 }
+
+func TestProjectRetryPolicy(t *testing.T) {
+	proj := &brigade.Project{
+		Name: "tennyson/light-brigade",
+		Retry: brigade.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     "30s",
+			ExitCodes:   []int32{137, 143},
+			Reasons:     []string{"Evicted", "OOMKilled"},
+		},
+	}
+	secret, err := SecretFromProject(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Data = map[string][]byte{}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+
+	got, err := NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Retry, proj.Retry) {
+		t.Errorf("expected retry policy %+v, got %+v", proj.Retry, got.Retry)
+	}
+
+	// An invalid retry policy is ignored rather than hiding the project.
+	secret.Data["retry.exitCodes"] = []byte("137,nope")
+	got, err = NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Retry, brigade.RetryPolicy{}) {
+		t.Errorf("expected the retry policy to be ignored, got %+v", got.Retry)
+	}
+}
+
+func TestProjectSchedules(t *testing.T) {
+	proj := &brigade.Project{
+		Name: "tennyson/light-brigade",
+		Schedules: []brigade.Schedule{
+			{Name: "nightly", Cron: "0 3 * * *", EventType: "nightly", Ref: "refs/heads/main"},
+			{Name: "hourly", Cron: "@hourly", Payload: `{"quick": true}`, ConcurrencyPolicy: brigade.ConcurrencyForbid, StartingDeadline: "10m"},
+		},
+	}
+	secret, err := SecretFromProject(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Data = map[string][]byte{}
+	for k, v := range secret.StringData {
+		secret.Data[k] = []byte(v)
+	}
+
+	got, err := NewProjectFromSecret(&secret, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Schedules, proj.Schedules) {
+		t.Errorf("expected schedules %+v, got %+v", proj.Schedules, got.Schedules)
+	}
+
+	secret.Data["schedules"] = []byte("nope")
+	if _, err := NewProjectFromSecret(&secret, "default"); err == nil {
+		t.Error("expected an error for invalid schedules")
+	}
+}
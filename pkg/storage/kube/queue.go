@@ -0,0 +1,277 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+// StatusQueued is the status label of a build secret whose worker is not
+// started yet, because its project or the controller runs as many builds as
+// it may run at the same time.
+const StatusQueued = "queued"
+
+// SortQueue sorts build secrets in the order they were created in.
+func SortQueue(secrets []v1.Secret) {
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return CreatedBefore(&secrets[i], &secrets[j])
+	})
+}
+
+// CreatedBefore returns true if the build of secret a was created before the
+// build of secret b. Builds created in the same second are ordered by ID.
+func CreatedBefore(a, b *v1.Secret) bool {
+	ta, tb := a.CreationTimestamp, b.CreationTimestamp
+	if !ta.Equal(&tb) {
+		return ta.Before(&tb)
+	}
+	return a.Labels["build"] < b.Labels["build"]
+}
+
+// queuePositions returns the positions of the queued builds among secrets,
+// by build ID.
+func queuePositions(secrets []v1.Secret, q *QueueState) map[string]int {
+	queued := []v1.Secret{}
+	for _, s := range secrets {
+		if s.Labels["status"] == StatusQueued {
+			queued = append(queued, s)
+		}
+	}
+	q.Sort(queued)
+	positions := make(map[string]int, len(queued))
+	for i, s := range queued {
+		positions[s.Labels["build"]] = i + 1
+	}
+	return positions
+}
+
+// BuildPriority returns the name of the PriorityClass of the worker of a
+// build, which is the priority of the build if its project allows it, or else
+// the default priority of the project. project may be nil, in which case the
+// priority of the build is ignored.
+func BuildPriority(build, project *v1.Secret) string {
+	if project == nil {
+		return ""
+	}
+	if p := string(build.Data["priority"]); p != "" {
+		for _, allowed := range strings.Split(string(project.Data["allowedPriorities"]), ",") {
+			if strings.TrimSpace(allowed) == p {
+				return p
+			}
+		}
+	}
+	return string(project.Data["defaultPriority"])
+}
+
+// QueueState holds what the order of waiting builds depends on besides the
+// builds themselves.
+type QueueState struct {
+	// Running is the number of running workers by project key.
+	Running map[string]int
+	// projects holds the project secrets of the waiting builds by project key.
+	// It is nil for projects that do not exist.
+	projects map[string]*v1.Secret
+	// home is the namespace of builds and workers without one.
+	home string
+	// priorities holds the values of the PriorityClasses of the waiting builds
+	// by name.
+	priorities map[string]int32
+}
+
+// LoadQueueState loads the running workers of every namespace of a set, and
+// the projects of the waiting builds. The values of their PriorityClasses are
+// read from priorities.
+//
+// A PriorityClass that can not be read has the value 0, like a build without
+// a priority.
+func LoadQueueState(client kubernetes.Interface, priorities PriorityClasses, set namespaces.Set, waiting []v1.Secret) (*QueueState, error) {
+	q := &QueueState{
+		Running:    map[string]int{},
+		projects:   map[string]*v1.Secret{},
+		priorities: map[string]int32{"": 0},
+		home:       set.Home(),
+	}
+	workers, err := client.CoreV1().Pods(set.Watch()).List(context.TODO(), meta.ListOptions{
+		LabelSelector: "heritage=brigade,component=build",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range workers.Items {
+		if !namespaces.Watched(set, w.Namespace) {
+			continue
+		}
+		if w.Status.Phase != v1.PodSucceeded && w.Status.Phase != v1.PodFailed {
+			q.Running[q.ProjectKey(w.ObjectMeta)]++
+		}
+	}
+
+	for i := range waiting {
+		key := q.ProjectKey(waiting[i].ObjectMeta)
+		if _, ok := q.projects[key]; !ok {
+			// A build lives in the namespace of its project.
+			ns := def(waiting[i].Namespace, set.Home())
+			p, err := client.CoreV1().Secrets(ns).Get(context.TODO(), waiting[i].Labels["project"], meta.GetOptions{})
+			if err != nil {
+				p = nil
+			}
+			q.projects[key] = p
+		}
+		name := BuildPriority(&waiting[i], q.projects[key])
+		if _, ok := q.priorities[name]; !ok {
+			pc, err := priorities.Get(name)
+			if err != nil {
+				log.Printf("could not read PriorityClass %q (using 0): %s", name, err)
+				q.priorities[name] = 0
+			} else {
+				q.priorities[name] = pc.Value
+			}
+		}
+	}
+	return q, nil
+}
+
+// ProjectKey returns the key of the project of a build secret or worker pod,
+// which is made of its namespace and project ID, since projects in different
+// namespaces may have the same ID.
+func (q *QueueState) ProjectKey(m meta.ObjectMeta) string {
+	return def(m.Namespace, q.home) + "/" + m.Labels["project"]
+}
+
+// Project returns the project secret of a waiting build, or nil if the
+// project does not exist.
+func (q *QueueState) Project(build *v1.Secret) *v1.Secret {
+	return q.projects[q.ProjectKey(build.ObjectMeta)]
+}
+
+// Sort sorts waiting build secrets in the order their workers are started.
+//
+// Builds with a higher priority are started first. Builds with the same
+// priority are shared fairly between projects: the next build is the oldest
+// build of the project that runs the fewest builds for its scheduling weight,
+// counting the builds started before it. Projects that run as few builds for
+// their weight take turns, starting with the one whose build is the oldest.
+func (q *QueueState) Sort(waiting []v1.Secret) {
+	SortQueue(waiting)
+	priority := func(s *v1.Secret) int32 {
+		return q.priorities[BuildPriority(s, q.Project(s))]
+	}
+	sort.SliceStable(waiting, func(i, j int) bool {
+		return priority(&waiting[i]) > priority(&waiting[j])
+	})
+
+	running := make(map[string]int, len(q.Running))
+	for key, n := range q.Running {
+		running[key] = n
+	}
+	sorted := make([]v1.Secret, 0, len(waiting))
+	for start := 0; start < len(waiting); {
+		end := start
+		for end < len(waiting) && priority(&waiting[end]) == priority(&waiting[start]) {
+			end++
+		}
+		// The builds of a priority by project, oldest first.
+		byProject := map[string][]v1.Secret{}
+		for _, s := range waiting[start:end] {
+			key := q.ProjectKey(s.ObjectMeta)
+			byProject[key] = append(byProject[key], s)
+		}
+		for len(byProject) > 0 {
+			next, found := "", false
+			for key, builds := range byProject {
+				if !found {
+					next, found = key, true
+					continue
+				}
+				// Compare running/weight without dividing.
+				a := running[key] * q.weight(next)
+				b := running[next] * q.weight(key)
+				if a < b || (a == b && CreatedBefore(&builds[0], &byProject[next][0])) {
+					next = key
+				}
+			}
+			sorted = append(sorted, byProject[next][0])
+			running[next]++
+			if byProject[next] = byProject[next][1:]; len(byProject[next]) == 0 {
+				delete(byProject, next)
+			}
+		}
+		start = end
+	}
+	copy(waiting, sorted)
+}
+
+// weight returns the scheduling weight of a project by key, which is 1 unless
+// its project sets a higher one.
+func (q *QueueState) weight(key string) int {
+	p := q.projects[key]
+	if p == nil {
+		return 1
+	}
+	w, err := strconv.Atoi(string(p.Data["schedulingWeight"]))
+	if err != nil || w < 1 {
+		return 1
+	}
+	return w
+}
+
+// NewWorkerFromSecret returns the worker of a build whose worker pod does not
+// exist, because the build is queued, or was terminated before its worker was
+// started or scheduled. It returns nil for any other build.
+func NewWorkerFromSecret(secret v1.Secret) *brigade.Worker {
+	w := &brigade.Worker{
+		ID:        secret.Name,
+		BuildID:   secret.Labels["build"],
+		ProjectID: secret.Labels["project"],
+	}
+	status := secret.Labels["status"]
+	if status == StatusQueued {
+		w.Status = brigade.JobQueued
+		return w
+	}
+	t, ok := terminations[status]
+	if !ok {
+		return nil
+	}
+	w.Status = t.status
+	if end, err := time.Parse(time.RFC3339, secret.Annotations[t.annotation]); err == nil {
+		w.EndTime = end
+	}
+	return w
+}
+
+// setQueuePositions sets the queue position of the queued builds.
+func (s *store) setQueuePositions(builds ...*brigade.Build) error {
+	queued := false
+	for _, b := range builds {
+		queued = queued || (b.Worker != nil && b.Worker.Status == brigade.JobQueued)
+	}
+	if !queued {
+		return nil
+	}
+	secrets, err := s.listSecrets(meta.ListOptions{
+		LabelSelector: "heritage=brigade,component=build,status=" + StatusQueued,
+	})
+	if err != nil {
+		return err
+	}
+	q, err := LoadQueueState(s.client, s.priorityClasses, s.namespaces, secrets)
+	if err != nil {
+		return err
+	}
+	positions := queuePositions(secrets, q)
+	for _, b := range builds {
+		b.QueuePosition = positions[b.ID]
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
+)
+
+func TestQueuedBuilds(t *testing.T) {
+	k, s := fakeStore()
+	ids := []string{"01-first", "02-second"}
+	for _, id := range ids {
+		b := *stubBuild
+		b.ID = id
+		if err := s.CreateBuild(&b); err != nil {
+			t.Fatal(err)
+		}
+		patch := []byte(`{"metadata":{"labels":{"status":"queued"}}}`)
+		if _, err := k.CoreV1().Secrets("default").Patch(context.TODO(), "brigade-worker-"+id, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := s.GetBuild("02-second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Worker == nil || b.Worker.Status != brigade.JobQueued {
+		t.Errorf("expected a queued worker, got %+v", b.Worker)
+	}
+	if b.QueuePosition != 2 {
+		t.Errorf("expected queue position 2, got %d", b.QueuePosition)
+	}
+
+	list, err := s.ListBuilds(storage.BuildListOptions{Status: []brigade.JobStatus{brigade.JobQueued}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 2 {
+		t.Fatalf("expected 2 queued builds, got %d", len(list.Items))
+	}
+	for _, b := range list.Items {
+		if expect := map[string]int{"01-first": 1, "02-second": 2}[b.ID]; b.QueuePosition != expect {
+			t.Errorf("expected build %s at queue position %d, got %d", b.ID, expect, b.QueuePosition)
+		}
+	}
+}
+
+func TestQueueStateSort(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ns := v1.NamespaceDefault
+	client.SchedulingV1().PriorityClasses().Create(context.TODO(), &schedulingv1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "urgent"},
+		Value:      1000,
+	}, metav1.CreateOptions{})
+	for pid, weight := range map[string]string{"ahab": "1", "starbuck": "2"} {
+		client.CoreV1().Secrets(ns).Create(context.TODO(), &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: pid, Namespace: ns},
+			Data:       map[string][]byte{"schedulingWeight": []byte(weight), "allowedPriorities": []byte("urgent")},
+		}, metav1.CreateOptions{})
+	}
+	// ahab already runs a build.
+	client.CoreV1().Pods(ns).Create(context.TODO(), &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: ns, Labels: map[string]string{"heritage": "brigade", "component": "build", "project": "ahab"}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}, metav1.CreateOptions{})
+
+	created := time.Now()
+	waiting := []v1.Secret{}
+	for _, b := range []struct{ name, pid, priority string }{
+		{"a1", "ahab", ""},
+		{"a2", "ahab", ""},
+		{"a3", "ahab", "urgent"},
+		{"s1", "starbuck", ""},
+		{"s2", "starbuck", ""},
+		{"s3", "starbuck", ""},
+	} {
+		created = created.Add(time.Second)
+		waiting = append(waiting, v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              b.name,
+				Namespace:         ns,
+				Labels:            map[string]string{"project": b.pid, "build": b.name},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Data: map[string][]byte{"priority": []byte(b.priority)},
+		})
+	}
+
+	q, err := LoadQueueState(client, clientPriorityClasses{client}, namespaces.Single(ns), waiting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Sort(waiting)
+	names := []string{}
+	for _, s := range waiting {
+		names = append(names, s.Name)
+	}
+	// The urgent build comes first. starbuck then gets two builds for every
+	// build of ahab, which already runs one.
+	if got, expect := strings.Join(names, ","), "a3,s1,s2,s3,a1,a2"; got != expect {
+		t.Errorf("expected %s, got %s", expect, got)
+	}
+}
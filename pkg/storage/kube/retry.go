@@ -0,0 +1,102 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// RetriedByAnnotation is the ID of the build that retries the failed build of
+// a build secret.
+const RetriedByAnnotation = "brigade.sh/retried-by"
+
+// BuildAttempt returns the attempt number of the build of a build secret,
+// which is 1 for builds that do not retry another build.
+func BuildAttempt(secret v1.Secret) int {
+	attempt, err := strconv.Atoi(string(secret.Data["attempt"]))
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// BuildNotBefore returns the earliest time that the worker of the build of a
+// build secret starts, if it has one.
+func BuildNotBefore(secret v1.Secret) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, string(secret.Data["not_before"]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// RetryBuild creates a build that retries the failed build of a build secret,
+// with the same event, script and configuration, and marks the failed build as
+// retried by it. The worker of the new build starts at notBefore at the
+// earliest. The build is created like the CreateBuild of a store creates
+// builds.
+//
+// A build that was already retried is not retried again, even if it was not
+// marked as retried, so RetryBuild may be called again after it failed.
+//
+// It returns the ID of the new build.
+func RetryBuild(client kubernetes.Interface, build *v1.Secret, notBefore time.Time) (string, error) {
+	failed := NewBuildFromSecret(*build)
+	retries, err := client.CoreV1().Secrets(build.Namespace).List(context.TODO(), meta.ListOptions{
+		LabelSelector: fmt.Sprintf("heritage=brigade,component=build,%s=%s,%s=%s", ParentBuildLabel, failed.ID, RerunReasonLabel, brigade.RerunRetry),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var bid string
+	if len(retries.Items) > 0 {
+		bid = retries.Items[0].Labels["build"]
+	} else {
+		sv := SecretValues(build.Data)
+		retry := &brigade.Build{
+			ProjectID:     failed.ProjectID,
+			Type:          failed.Type,
+			Provider:      failed.Provider,
+			ShortTitle:    failed.ShortTitle,
+			LongTitle:     failed.LongTitle,
+			CloneURL:      failed.CloneURL,
+			Revision:      failed.Revision,
+			Payload:       failed.Payload,
+			Script:        failed.Script,
+			Config:        sv.Bytes("config"),
+			LogLevel:      sv.String("log_level"),
+			Priority:      failed.Priority,
+			Traceparent:   failed.Traceparent,
+			Attempt:       failed.Attempt + 1,
+			ParentBuildID: failed.ID,
+			RerunReason:   brigade.RerunRetry,
+			NotBefore:     &notBefore,
+		}
+		// The build lives in the namespace of the failed build.
+		if err := New(client, build.Namespace).CreateBuild(retry); err != nil {
+			return "", err
+		}
+		bid = retry.ID
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{RetriedByAnnotation: bid},
+		},
+	})
+	if err != nil {
+		return bid, err
+	}
+	_, err = client.CoreV1().Secrets(build.Namespace).Patch(context.TODO(), build.Name, types.MergePatchType, patch, meta.PatchOptions{})
+	return bid, err
+}
@@ -1,26 +1,95 @@
 package kube
 
 import (
+	"sync"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/brigadecore/brigade/pkg/storage"
 	"github.com/brigadecore/brigade/pkg/storage/kube/apicache"
+	"github.com/brigadecore/brigade/pkg/storage/kube/namespaces"
 )
 
+// DefaultCacheResyncPeriod is how often the store's API cache re-syncs.
+const DefaultCacheResyncPeriod = time.Duration(60) * time.Second
+
 // store represents a storage engine for a brigade.Project.
 type store struct {
-	client    kubernetes.Interface
-	namespace string
-	apiCache  apicache.APICache
+	client kubernetes.Interface
+	// namespace is the home namespace of the namespaces.
+	namespace  string
+	namespaces namespaces.Set
+	apiCache   apicache.APICache
+	// priorityClasses orders the queue by the priorities of builds.
+	priorityClasses PriorityClasses
+	// cachedLists is whether builds are listed from the API cache, which only
+	// pays off for stores that live long enough to list them many times.
+	cachedLists bool
 }
 
 // New initializes a new storage backend.
+//
+// The store is meant for a few requests, such as those of a brig command. Its
+// API cache is only started when it is first needed, and builds are listed
+// from the API server.
 func New(c kubernetes.Interface, namespace string) storage.Store {
 	return &store{
-		client:    c,
-		namespace: namespace,
-		apiCache:  apicache.New(c, namespace, time.Duration(60)*time.Second),
+		client:     c,
+		namespace:  namespace,
+		namespaces: namespaces.Single(namespace),
+		apiCache: &lazyAPICache{new: func() apicache.APICache {
+			return apicache.New(c, namespace, DefaultCacheResyncPeriod)
+		}},
+		priorityClasses: clientPriorityClasses{c},
 	}
 }
+
+// NewWithAPICache initializes a new storage backend which shares an existing API cache.
+func NewWithAPICache(c kubernetes.Interface, namespace string, apiCache apicache.APICache) storage.Store {
+	return NewForNamespaces(c, namespaces.Single(namespace), apiCache)
+}
+
+// NewForNamespaces initializes a new storage backend for projects that live in
+// any namespace of a set. apiCache has to cache the same namespaces.
+//
+// Project IDs are resolved to the namespace of their project, and the builds
+// and jobs of a project live in its namespace.
+func NewForNamespaces(c kubernetes.Interface, set namespaces.Set, apiCache apicache.APICache) storage.Store {
+	return &store{
+		client:      c,
+		namespace:   set.Home(),
+		namespaces:  set,
+		apiCache:    apiCache,
+		cachedLists: true,
+		priorityClasses: &lazyPriorityClasses{new: func() *PriorityClassCache {
+			return NewPriorityClassCache(c, DefaultCacheResyncPeriod)
+		}},
+	}
+}
+
+// lazyAPICache is an API cache which is created the first time it is used.
+type lazyAPICache struct {
+	once  sync.Once
+	new   func() apicache.APICache
+	cache apicache.APICache
+}
+
+func (l *lazyAPICache) get() apicache.APICache {
+	l.once.Do(func() { l.cache = l.new() })
+	return l.cache
+}
+
+func (l *lazyAPICache) GetSecretsFilteredBy(labelSelectors map[string]string) ([]v1.Secret, error) {
+	return l.get().GetSecretsFilteredBy(labelSelectors)
+}
+
+func (l *lazyAPICache) GetPodsFilteredBy(labelSelectors map[string]string) ([]v1.Pod, error) {
+	return l.get().GetPodsFilteredBy(labelSelectors)
+}
+
+func (l *lazyAPICache) AddEventHandler(handler cache.ResourceEventHandler) {
+	l.get().AddEventHandler(handler)
+}
@@ -142,6 +142,7 @@ var (
 		Provider: "bar",
 		Payload:  []byte("this is a payload"),
 		Script:   []byte("ohai"),
+		Attempt:  1,
 	}
 )
 
@@ -0,0 +1,190 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+const (
+	// CancelledAnnotation marks a build secret, worker pod or job pod as
+	// cancelled. Its value is the RFC 3339 time of the cancellation.
+	CancelledAnnotation = "brigade.sh/cancelled"
+	// TimedOutAnnotation marks a build secret, worker pod or job pod whose
+	// build ran for longer than its timeout. Its value is the RFC 3339 time the
+	// build was terminated.
+	TimedOutAnnotation = "brigade.sh/timed-out"
+	// SupersededAnnotation marks a build secret, worker pod or job pod whose
+	// build was cancelled because a newer build for the same ref was started.
+	// Its value is the RFC 3339 time the build was terminated.
+	SupersededAnnotation = "brigade.sh/superseded"
+	// SupersededByAnnotation is the ID of the build that superseded the build
+	// of a build secret.
+	SupersededByAnnotation = "brigade.sh/superseded-by"
+	// SupersedesAnnotation is the comma separated IDs of the builds that the
+	// build of a build secret superseded.
+	SupersedesAnnotation = "brigade.sh/supersedes"
+	// FailedAnnotation marks a build secret whose worker could not be created,
+	// such as because its PriorityClass does not exist. Its value is the RFC
+	// 3339 time the controller gave up on the build.
+	FailedAnnotation = "brigade.sh/failed"
+)
+
+// The status labels of build secrets whose build was terminated. The
+// controller does not start a worker for them.
+const (
+	StatusCancelled  = "cancelled"
+	StatusTimedOut   = "timed-out"
+	StatusSuperseded = "superseded"
+	StatusFailed     = "failed"
+)
+
+// terminations maps the status label of a terminated build secret to the
+// annotation of its pods, and to the status of its worker and jobs.
+var terminations = map[string]struct {
+	annotation string
+	status     brigade.JobStatus
+}{
+	StatusCancelled:  {CancelledAnnotation, brigade.JobCancelled},
+	StatusTimedOut:   {TimedOutAnnotation, brigade.JobTimedOut},
+	StatusSuperseded: {SupersededAnnotation, brigade.JobSuperseded},
+	StatusFailed:     {FailedAnnotation, brigade.JobFailed},
+}
+
+// CancelBuild cancels a build.
+//
+// The build secret is marked as cancelled, so that a worker is not started if
+// it has not been yet. Worker and job pods that have started get an active
+// deadline that has already passed, which makes the kubelet send them SIGTERM,
+// and SIGKILL once their termination grace period is over. The pods are kept,
+// so that their logs can still be read. Pods that were never scheduled are
+// deleted.
+//
+// Cancelling a build whose worker has already terminated, or which has already
+// been terminated, returns storage.ErrBuildFinished.
+func (s *store) CancelBuild(bid string) error {
+	ns, err := s.buildNamespace(bid)
+	if err != nil {
+		return err
+	}
+	return terminateBuild(s.client, ns, bid, StatusCancelled, nil)
+}
+
+// TimeOutBuild terminates a build that ran for longer than its timeout, like
+// CancelBuild does, and marks it as timed out.
+func TimeOutBuild(client kubernetes.Interface, namespace, bid string) error {
+	return terminateBuild(client, namespace, bid, StatusTimedOut, nil)
+}
+
+// SupersedeBuild terminates a build because a newer build for the same ref,
+// whose ID is by, was started. It does so like CancelBuild does, and marks the
+// build as superseded by the newer one.
+func SupersedeBuild(client kubernetes.Interface, namespace, bid, by string) error {
+	return terminateBuild(client, namespace, bid, StatusSuperseded, map[string]string{SupersededByAnnotation: by})
+}
+
+// FailBuild marks a build whose worker can not be created as failed, so that
+// the controller stops trying to create it. Its pods, if any, are terminated
+// like CancelBuild does.
+func FailBuild(client kubernetes.Interface, namespace, bid string) error {
+	return terminateBuild(client, namespace, bid, StatusFailed, nil)
+}
+
+// terminateBuild marks a build secret with a status, and with the annotation
+// of that status along with any other annotations, and terminates its pods.
+// It returns storage.ErrBuildFinished if the build was already terminated, or
+// its worker has terminated.
+func terminateBuild(client kubernetes.Interface, namespace, bid, status string, annotations map[string]string) error {
+	secrets, err := client.CoreV1().Secrets(namespace).List(context.TODO(), meta.ListOptions{
+		LabelSelector: fmt.Sprint("heritage=brigade,component=build,build=", bid),
+	})
+	if err != nil {
+		return err
+	}
+	if len(secrets.Items) < 1 {
+		return fmt.Errorf("could not find build %s", bid)
+	}
+	secret := secrets.Items[0]
+	if _, ok := terminations[secret.Labels["status"]]; ok {
+		return storage.ErrBuildFinished
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(context.TODO(), meta.ListOptions{
+		LabelSelector: fmt.Sprintf(jobFilter, bid),
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range pods.Items {
+		if p.Labels["component"] == "build" && (p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed) {
+			return storage.ErrBuildFinished
+		}
+	}
+
+	annotation := terminations[status].annotation
+	now := time.Now().UTC().Format(time.RFC3339)
+	secretAnnotations := map[string]string{annotation: now}
+	for k, v := range annotations {
+		secretAnnotations[k] = v
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{"status": status},
+			"annotations": secretAnnotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Secrets(namespace).Patch(context.TODO(), secret.Name, types.MergePatchType, patch, meta.PatchOptions{}); err != nil {
+		return err
+	}
+
+	for _, p := range pods.Items {
+		if err := terminatePod(client, namespace, p, annotation, now); err != nil {
+			log.Printf("failed to terminate pod %s (continuing): %s", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// terminatePod terminates a worker or job pod of a terminated build.
+func terminatePod(client kubernetes.Interface, namespace string, pod v1.Pod, annotation, now string) error {
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return nil
+	}
+	if pod.Status.StartTime == nil {
+		log.Printf("Deleting unscheduled pod %q", pod.Name)
+		return client.CoreV1().Pods(namespace).Delete(context.TODO(), pod.Name, meta.DeleteOptions{})
+	}
+	log.Printf("Terminating pod %q", pod.Name)
+	// The active deadline is counted from the start of the pod, so one second
+	// has always passed for a pod that has started.
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},"spec":{"activeDeadlineSeconds":1}}`, annotation, now)
+	_, err := client.CoreV1().Pods(namespace).Patch(context.TODO(), pod.Name, types.MergePatchType, []byte(patch), meta.PatchOptions{})
+	return err
+}
+
+// terminatedStatus returns the status of a pod that did not succeed before
+// its build was terminated, or an empty status otherwise.
+func terminatedStatus(pod v1.Pod) brigade.JobStatus {
+	if pod.Status.Phase == v1.PodSucceeded {
+		return ""
+	}
+	for _, t := range terminations {
+		if _, ok := pod.Annotations[t.annotation]; ok {
+			return t.status
+		}
+	}
+	return ""
+}
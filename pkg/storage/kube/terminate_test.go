@@ -0,0 +1,156 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+func TestCancelBuild(t *testing.T) {
+	k, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	worker := *stubWorkerPod.DeepCopy()
+	worker.Status = v1.PodStatus{Phase: v1.PodRunning, StartTime: &podStartTime}
+	createFakeWorker(k, worker)
+	job := *stubJobPod.DeepCopy()
+	job.Status = v1.PodStatus{Phase: v1.PodRunning, StartTime: &podStartTime}
+	createFakeJob(k, job)
+	unscheduled := *stubJobPod.DeepCopy()
+	unscheduled.Name = "unscheduled-" + stubBuildID
+	unscheduled.Status = v1.PodStatus{Phase: v1.PodPending}
+	createFakeJob(k, unscheduled)
+
+	if err := s.CancelBuild(stubBuild.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := k.CoreV1().Secrets("default").Get(context.TODO(), "brigade-worker-"+stubBuild.ID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Labels["status"] != StatusCancelled || secret.Annotations[CancelledAnnotation] == "" {
+		t.Errorf("expected the build secret to be marked as cancelled, got %v %v", secret.Labels, secret.Annotations)
+	}
+	for _, name := range []string{worker.Name, job.Name} {
+		pod, err := k.CoreV1().Pods("default").Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds != 1 {
+			t.Errorf("expected pod %s to get an active deadline", name)
+		}
+	}
+	if _, err := k.CoreV1().Pods("default").Get(context.TODO(), unscheduled.Name, metav1.GetOptions{}); err == nil {
+		t.Error("expected the unscheduled pod to be deleted")
+	}
+
+	w, err := s.GetWorker(stubBuild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != brigade.JobCancelled {
+		t.Errorf("expected worker to be %s, got %s", brigade.JobCancelled, w.Status)
+	}
+	j, err := s.GetJob(job.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != brigade.JobCancelled {
+		t.Errorf("expected job to be %s, got %s", brigade.JobCancelled, j.Status)
+	}
+
+	if err := s.CancelBuild(stubBuild.ID); err != storage.ErrBuildFinished {
+		t.Errorf("expected cancelling a cancelled build to return %v, got %v", storage.ErrBuildFinished, err)
+	}
+}
+
+func TestCancelBuildNotStarted(t *testing.T) {
+	_, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelBuild(stubBuild.ID); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.GetBuild(stubBuild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Worker == nil || b.Worker.Status != brigade.JobCancelled {
+		t.Errorf("expected a cancelled worker, got %+v", b.Worker)
+	}
+}
+
+func TestCancelBuildFinished(t *testing.T) {
+	k, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	createFakeWorker(k, stubWorkerPod)
+	if err := s.CancelBuild(stubBuild.ID); err != storage.ErrBuildFinished {
+		t.Errorf("expected %v, got %v", storage.ErrBuildFinished, err)
+	}
+}
+
+func TestTimeOutBuild(t *testing.T) {
+	k, s := fakeStore()
+	if err := s.CreateBuild(stubBuild); err != nil {
+		t.Fatal(err)
+	}
+	worker := *stubWorkerPod.DeepCopy()
+	worker.Status = v1.PodStatus{Phase: v1.PodRunning, StartTime: &podStartTime}
+	createFakeWorker(k, worker)
+
+	if err := TimeOutBuild(k, "default", stubBuild.ID); err != nil {
+		t.Fatal(err)
+	}
+	w, err := s.GetWorker(stubBuild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != brigade.JobTimedOut {
+		t.Errorf("expected worker to be %s, got %s", brigade.JobTimedOut, w.Status)
+	}
+
+	// A timed out build can not be cancelled anymore.
+	if err := s.CancelBuild(stubBuild.ID); err != storage.ErrBuildFinished {
+		t.Errorf("expected %v, got %v", storage.ErrBuildFinished, err)
+	}
+	if w, _ := s.GetWorker(stubBuild.ID); w.Status != brigade.JobTimedOut {
+		t.Errorf("expected worker to stay %s, got %s", brigade.JobTimedOut, w.Status)
+	}
+}
+
+func TestSupersedeBuild(t *testing.T) {
+	k, s := fakeStore()
+	older := *stubBuild
+	older.ID = "older"
+	newer := *stubBuild
+	newer.ID = "newer"
+	for _, b := range []*brigade.Build{&older, &newer} {
+		if err := s.CreateBuild(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SupersedeBuild(k, "default", older.ID, newer.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := s.GetBuild(older.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Worker == nil || b.Worker.Status != brigade.JobSuperseded {
+		t.Errorf("expected a superseded worker, got %+v", b.Worker)
+	}
+	if b.SupersededBy != newer.ID {
+		t.Errorf("expected the build to be superseded by %s, got %q", newer.ID, b.SupersededBy)
+	}
+}
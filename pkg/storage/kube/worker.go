@@ -12,6 +12,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
 )
 
 // GetWorker returns the worker description.
@@ -21,14 +22,22 @@ import (
 func (s *store) GetWorker(buildID string) (*brigade.Worker, error) {
 	labels := labels.Set{"heritage": "brigade", "component": "build", "build": buildID}
 	listOption := meta.ListOptions{LabelSelector: labels.AsSelector().String()}
-	pods, err := s.client.CoreV1().Pods(s.namespace).List(context.TODO(), listOption)
+	pods, err := s.listPods(listOption)
 	if err != nil {
 		return nil, err
 	}
-	if len(pods.Items) < 1 {
+	if len(pods) < 1 {
+		// A build that is queued, or was terminated before its worker was
+		// scheduled, has none.
+		secrets, err := s.listSecrets(listOption)
+		if err == nil && len(secrets) > 0 {
+			if w := NewWorkerFromSecret(secrets[0]); w != nil {
+				return w, nil
+			}
+		}
 		return nil, fmt.Errorf("could not find worker for build %s: no pod exists with label %s", buildID, labels.AsSelector().String())
 	}
-	return NewWorkerFromPod(pods.Items[0]), nil
+	return NewWorkerFromPod(pods[0]), nil
 }
 
 // NewWorkerFromPod creates a new *Worker from a pod definition.
@@ -44,6 +53,9 @@ func NewWorkerFromPod(pod v1.Pod) *brigade.Worker {
 	if (worker.Status != brigade.JobPending) && (worker.Status != brigade.JobUnknown) {
 		worker.StartTime = pod.Status.StartTime.Time
 	}
+	if status := terminatedStatus(pod); status != "" {
+		worker.Status = status
+	}
 
 	if len(pod.Status.ContainerStatuses) > 0 {
 		cs := pod.Status.ContainerStatuses[0]
@@ -52,15 +64,50 @@ func NewWorkerFromPod(pod v1.Pod) *brigade.Worker {
 			worker.ExitCode = cs.State.Terminated.ExitCode
 		}
 	}
+	worker.Reason, worker.Message = podReason(pod)
 
 	return worker
 }
+
+// podReason returns why a worker or job pod is not running as expected, and a
+// message that explains it, or empty strings if nothing is wrong with it.
+//
+// The reasons of the containers of the pod come first, as they are the most
+// specific, then the reason the pod is not scheduled, and then the reason of
+// the pod itself, such as Evicted.
+func podReason(pod v1.Pod) (string, string) {
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if w := cs.State.Waiting; w != nil {
+			switch w.Reason {
+			case "", "ContainerCreating", "PodInitializing":
+				continue
+			}
+			return w.Reason, w.Message
+		}
+		if t := cs.State.Terminated; t != nil {
+			// The exit code of a container that completed or exited with an
+			// error says all there is to say.
+			switch t.Reason {
+			case "", "Completed", "Error":
+				continue
+			}
+			return t.Reason, t.Message
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason != "" {
+			return c.Reason, c.Message
+		}
+	}
+	return pod.Status.Reason, pod.Status.Message
+}
 func (s *store) GetWorkerLogStream(worker *brigade.Worker) (io.ReadCloser, error) {
-	return s.getWorkerLogStream(false, worker)
+	return s.GetWorkerLogStreamWithOptions(worker, storage.LogOptions{})
 }
 
 func (s *store) GetWorkerLogStreamFollow(worker *brigade.Worker) (io.ReadCloser, error) {
-	return s.getWorkerLogStream(true, worker)
+	return s.GetWorkerLogStreamWithOptions(worker, storage.LogOptions{Follow: true})
 }
 
 func (s *store) GetWorkerLog(worker *brigade.Worker) (string, error) {
@@ -76,7 +123,7 @@ func (s *store) GetWorkerLog(worker *brigade.Worker) (string, error) {
 
 func (s *store) GetWorkerInitLog(worker *brigade.Worker) (string, error) {
 	buf := new(bytes.Buffer)
-	r, err := s.getWorkerLogStream(false, worker, "vcs-sidecar")
+	r, err := s.GetWorkerLogStreamWithOptions(worker, storage.LogOptions{Container: storage.InitContainer})
 	if err != nil {
 		return "", err
 	}
@@ -85,16 +132,12 @@ func (s *store) GetWorkerInitLog(worker *brigade.Worker) (string, error) {
 	return buf.String(), nil
 }
 
-func (s *store) getWorkerLogStream(follow bool, worker *brigade.Worker, container ...string) (io.ReadCloser, error) {
-	tailAllLines := int64(math.MaxInt64)
-	opts := &v1.PodLogOptions{
-		Follow:    follow,
-		TailLines: &tailAllLines,
-	}
-	if len(container) > 0 {
-		opts.Container = container[0]
+func (s *store) GetWorkerLogStreamWithOptions(worker *brigade.Worker, opts storage.LogOptions) (io.ReadCloser, error) {
+	ns, err := s.podNamespace(worker.ID)
+	if err != nil {
+		return nil, err
 	}
-	req := s.client.CoreV1().Pods(s.namespace).GetLogs(worker.ID, opts)
+	req := s.client.CoreV1().Pods(ns).GetLogs(worker.ID, podLogOptions(opts))
 
 	readCloser, err := req.Stream(context.TODO())
 	if err != nil {
@@ -102,3 +145,22 @@ func (s *store) getWorkerLogStream(follow bool, worker *brigade.Worker, containe
 	}
 	return readCloser, nil
 }
+
+// podLogOptions converts storage log options to pod log options.
+func podLogOptions(opts storage.LogOptions) *v1.PodLogOptions {
+	tailLines := int64(math.MaxInt64)
+	if opts.TailLines > 0 {
+		tailLines = opts.TailLines
+	}
+	podOpts := &v1.PodLogOptions{
+		Container:  opts.Container,
+		Follow:     opts.Follow,
+		TailLines:  &tailLines,
+		Timestamps: opts.Timestamps,
+	}
+	if opts.SinceSeconds > 0 {
+		sinceSeconds := opts.SinceSeconds
+		podOpts.SinceSeconds = &sinceSeconds
+	}
+	return podOpts
+}
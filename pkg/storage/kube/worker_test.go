@@ -58,6 +58,108 @@ func TestNewWorkerFromPod(t *testing.T) {
 	}
 }
 
+func TestNewWorkerFromPod_Reason(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  v1.PodStatus
+		reason  string
+		message string
+	}{
+		{
+			name: "image pull",
+			status: v1.PodStatus{
+				Phase: v1.PodPending,
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image \"brigade-worker:nope\""},
+				}}},
+			},
+			reason:  "ImagePullBackOff",
+			message: "Back-off pulling image \"brigade-worker:nope\"",
+		},
+		{
+			name: "init container",
+			status: v1.PodStatus{
+				Phase: v1.PodPending,
+				InitContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "ErrImagePull"},
+				}}},
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "PodInitializing"},
+				}}},
+			},
+			reason: "ErrImagePull",
+		},
+		{
+			name: "unschedulable",
+			status: v1.PodStatus{
+				Phase: v1.PodPending,
+				Conditions: []v1.PodCondition{{
+					Type:    v1.PodScheduled,
+					Status:  v1.ConditionFalse,
+					Reason:  v1.PodReasonUnschedulable,
+					Message: "0/3 nodes are available: 3 Insufficient memory.",
+				}},
+			},
+			reason:  "Unschedulable",
+			message: "0/3 nodes are available: 3 Insufficient memory.",
+		},
+		{
+			name: "out of memory",
+			status: v1.PodStatus{
+				Phase:     v1.PodFailed,
+				StartTime: &metav1.Time{},
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+				}}},
+			},
+			reason: "OOMKilled",
+		},
+		{
+			name:    "evicted",
+			status:  v1.PodStatus{Phase: v1.PodFailed, StartTime: &metav1.Time{}, Reason: "Evicted", Message: "The node was low on resource: memory."},
+			reason:  "Evicted",
+			message: "The node was low on resource: memory.",
+		},
+		{
+			name: "creating",
+			status: v1.PodStatus{
+				Phase: v1.PodPending,
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Waiting: &v1.ContainerStateWaiting{Reason: "ContainerCreating"},
+				}}},
+			},
+		},
+		{
+			name: "exited",
+			status: v1.PodStatus{
+				Phase:     v1.PodFailed,
+				StartTime: &metav1.Time{},
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1},
+				}}},
+			},
+		},
+		{
+			name: "completed",
+			status: v1.PodStatus{
+				Phase:     v1.PodSucceeded,
+				StartTime: &metav1.Time{},
+				ContainerStatuses: []v1.ContainerStatus{{State: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{Reason: "Completed"},
+				}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := NewWorkerFromPod(v1.Pod{Status: tt.status})
+			if worker.Reason != tt.reason || worker.Message != tt.message {
+				t.Errorf("expected reason %q and message %q, got %q and %q", tt.reason, tt.message, worker.Reason, worker.Message)
+			}
+		})
+	}
+}
+
 func TestGetWorker(t *testing.T) {
 	k, s := fakeStore()
 	createFakeWorker(k, stubWorkerPod)
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+// ErrInvalidContinue indicates that a continue token could not be decoded.
+var ErrInvalidContinue = errors.New("invalid continue token")
+
+// BuildListOptions represents options for filtering, sorting and paginating builds.
+//
+// Zero values do not filter.
+type BuildListOptions struct {
+	// Limit is the maximum number of builds to return. 0 returns all builds.
+	Limit int
+	// Continue is the token returned with the previous page of builds.
+	Continue string
+	// Status matches builds whose worker has any of the given statuses.
+	// Builds without a worker are Pending.
+	Status []brigade.JobStatus
+	// Type matches the event type of the build.
+	Type string
+	// Provider matches the event provider of the build.
+	Provider string
+	// Commit matches builds whose commit starts with the given prefix.
+	Commit string
+	// Ref matches the symbolic ref of the build.
+	Ref string
+	// Since matches builds that started at or after the given time.
+	Since time.Time
+	// Until matches builds that started before the given time.
+	Until time.Time
+	// Ascending sorts the oldest builds first. By default the newest builds
+	// (and builds that have not started yet) come first.
+	Ascending bool
+}
+
+// BuildList is a page of builds.
+type BuildList struct {
+	// Items are the builds of this page.
+	Items []*brigade.Build `json:"items"`
+	// Continue is the token to pass in BuildListOptions to get the next page.
+	// It is empty on the last page.
+	Continue string `json:"continue,omitempty"`
+}
+
+// BuildStatus returns the status of a build's worker, or Pending if the worker has not been created.
+func BuildStatus(b *brigade.Build) brigade.JobStatus {
+	if b.Worker == nil {
+		return brigade.JobPending
+	}
+	return b.Worker.Status
+}
+
+// Matches returns true if the build passes every filter of the options.
+func (o BuildListOptions) Matches(b *brigade.Build) bool {
+	if len(o.Status) > 0 {
+		status := BuildStatus(b)
+		found := false
+		for _, s := range o.Status {
+			if strings.EqualFold(string(s), string(status)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if o.Type != "" && o.Type != b.Type {
+		return false
+	}
+	if o.Provider != "" && o.Provider != b.Provider {
+		return false
+	}
+	if o.Commit != "" && (b.Revision == nil || !strings.HasPrefix(b.Revision.Commit, o.Commit)) {
+		return false
+	}
+	if o.Ref != "" && (b.Revision == nil || o.Ref != b.Revision.Ref) {
+		return false
+	}
+	if !o.Since.IsZero() || !o.Until.IsZero() {
+		start := startTime(b)
+		if start.IsZero() {
+			return false
+		}
+		if !o.Since.IsZero() && start.Before(o.Since) {
+			return false
+		}
+		if !o.Until.IsZero() && !start.Before(o.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// PageBuilds filters, sorts and paginates builds according to the options.
+//
+// It is meant for Store implementations that load builds into memory.
+func PageBuilds(builds []*brigade.Build, opts BuildListOptions) (*BuildList, error) {
+	var after *buildCursor
+	if opts.Continue != "" {
+		var err error
+		if after, err = decodeCursor(opts.Continue); err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := []*brigade.Build{}
+	for _, b := range builds {
+		if !opts.Matches(b) {
+			continue
+		}
+		if after != nil {
+			c := compareBuilds(cursorOf(b), *after)
+			if (opts.Ascending && c <= 0) || (!opts.Ascending && c >= 0) {
+				continue
+			}
+		}
+		filtered = append(filtered, b)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		c := compareBuilds(cursorOf(filtered[i]), cursorOf(filtered[j]))
+		if opts.Ascending {
+			return c < 0
+		}
+		return c > 0
+	})
+
+	list := &BuildList{Items: filtered}
+	if opts.Limit > 0 && len(filtered) > opts.Limit {
+		list.Items = filtered[:opts.Limit]
+		list.Continue = ContinueToken(list.Items[opts.Limit-1])
+	}
+	return list, nil
+}
+
+// ContinueToken returns the token which continues a listing after build b.
+func ContinueToken(b *brigade.Build) string {
+	return encodeCursor(cursorOf(b))
+}
+
+// ParseContinueToken returns the position of the last build of a page encoded
+// in a continue token: its start time in nanoseconds since the epoch, or 0 if
+// it has not started, and its ID.
+//
+// It is meant for Store implementations that sort and paginate builds themselves.
+func ParseContinueToken(token string) (startTime int64, id string, err error) {
+	c, err := decodeCursor(token)
+	if err != nil {
+		return 0, "", err
+	}
+	return c.StartTime, c.ID, nil
+}
+
+// buildCursor is the position of a build in the sort order.
+type buildCursor struct {
+	StartTime int64  `json:"s"`
+	ID        string `json:"id"`
+}
+
+func startTime(b *brigade.Build) time.Time {
+	if b.Worker == nil {
+		return time.Time{}
+	}
+	return b.Worker.StartTime
+}
+
+func cursorOf(b *brigade.Build) buildCursor {
+	c := buildCursor{ID: b.ID}
+	if start := startTime(b); !start.IsZero() {
+		c.StartTime = start.UnixNano()
+	}
+	return c
+}
+
+// compareBuilds orders builds by start time, then by ID. Builds that have not
+// started yet are newer than any build that has.
+func compareBuilds(a, b buildCursor) int {
+	if a.StartTime != b.StartTime {
+		switch {
+		case a.StartTime == 0:
+			return 1
+		case b.StartTime == 0:
+			return -1
+		case a.StartTime < b.StartTime:
+			return -1
+		default:
+			return 1
+		}
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+func encodeCursor(c buildCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*buildCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidContinue
+	}
+	c := &buildCursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, ErrInvalidContinue
+	}
+	return c, nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestPageBuilds(t *testing.T) {
+	now := time.Now()
+	builds := []*brigade.Build{
+		{ID: "01a", Type: "push", Provider: "github", Revision: &brigade.Revision{Commit: "abc123", Ref: "refs/heads/master"},
+			Worker: &brigade.Worker{StartTime: now.Add(-3 * time.Hour), Status: brigade.JobSucceeded}},
+		{ID: "01b", Type: "push", Provider: "github", Revision: &brigade.Revision{Commit: "def456", Ref: "refs/heads/dev"},
+			Worker: &brigade.Worker{StartTime: now.Add(-2 * time.Hour), Status: brigade.JobFailed}},
+		{ID: "01c", Type: "exec", Provider: "brigade-cli", Revision: &brigade.Revision{Commit: "abc789", Ref: "refs/heads/master"},
+			Worker: &brigade.Worker{StartTime: now.Add(-time.Hour), Status: brigade.JobRunning}},
+		{ID: "01d", Type: "push", Provider: "github", Revision: &brigade.Revision{}},
+	}
+
+	ids := func(l *BuildList) string {
+		s := ""
+		for _, b := range l.Items {
+			s += b.ID + " "
+		}
+		return s
+	}
+
+	tests := []struct {
+		name   string
+		opts   BuildListOptions
+		expect string
+	}{
+		{"all, newest first", BuildListOptions{}, "01d 01c 01b 01a "},
+		{"ascending", BuildListOptions{Ascending: true}, "01a 01b 01c 01d "},
+		{"pending status", BuildListOptions{Status: []brigade.JobStatus{"pending"}}, "01d "},
+		{"statuses", BuildListOptions{Status: []brigade.JobStatus{brigade.JobSucceeded, brigade.JobFailed}}, "01b 01a "},
+		{"type", BuildListOptions{Type: "push"}, "01d 01b 01a "},
+		{"provider", BuildListOptions{Provider: "brigade-cli"}, "01c "},
+		{"commit prefix", BuildListOptions{Commit: "abc"}, "01c 01a "},
+		{"ref", BuildListOptions{Ref: "refs/heads/master"}, "01c 01a "},
+		{"since", BuildListOptions{Since: now.Add(-150 * time.Minute)}, "01c 01b "},
+		{"until", BuildListOptions{Until: now.Add(-150 * time.Minute)}, "01a "},
+	}
+	for _, tt := range tests {
+		l, err := PageBuilds(builds, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if got := ids(l); got != tt.expect {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expect, got)
+		}
+	}
+
+	// Page through the builds two at a time.
+	opts := BuildListOptions{Limit: 2}
+	l, err := PageBuilds(builds, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(l) != "01d 01c " || l.Continue == "" {
+		t.Fatalf("unexpected first page %q (continue %q)", ids(l), l.Continue)
+	}
+	// A build created between pages does not shift the next page.
+	builds = append(builds, &brigade.Build{ID: "01e", Revision: &brigade.Revision{}})
+	opts.Continue = l.Continue
+	if l, err = PageBuilds(builds, opts); err != nil {
+		t.Fatal(err)
+	}
+	if ids(l) != "01b 01a " || l.Continue != "" {
+		t.Fatalf("unexpected last page %q (continue %q)", ids(l), l.Continue)
+	}
+
+	if _, err := PageBuilds(builds, BuildListOptions{Continue: "garbage!"}); err != ErrInvalidContinue {
+		t.Errorf("expected ErrInvalidContinue, got %v", err)
+	}
+}
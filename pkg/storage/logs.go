@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/merge"
+)
+
+// Sources of merged build logs other than jobs, which are named by their ID.
+const (
+	InitLogSource   = "init"
+	WorkerLogSource = "worker"
+)
+
+// InitContainer is the name of the init container of a worker which clones the project's repository.
+const InitContainer = "vcs-sidecar"
+
+// GetMergedBuildLogStream retrieves a stream of the init container, worker and
+// job logs of a build, interleaved in the order they were written. Every line is
+// prefixed with its source in square brackets, and with its timestamp if
+// timestamps is true.
+//
+// Logs which cannot be retrieved, such as those of a worker without an init
+// container or of a job pod which has not started, are left out.
+func GetMergedBuildLogStream(s Store, build *brigade.Build, timestamps bool) (io.ReadCloser, error) {
+	if build.Worker == nil {
+		return nil, fmt.Errorf("build %s has no worker", build.ID)
+	}
+	opts := LogOptions{Timestamps: true}
+
+	names := []string{}
+	streams := []io.ReadCloser{}
+	if r, err := s.GetWorkerLogStreamWithOptions(build.Worker, LogOptions{Timestamps: true, Container: InitContainer}); err == nil {
+		names = append(names, InitLogSource)
+		streams = append(streams, r)
+	}
+	r, err := s.GetWorkerLogStreamWithOptions(build.Worker, opts)
+	if err != nil {
+		closeAll(streams)
+		return nil, err
+	}
+	names = append(names, WorkerLogSource)
+	streams = append(streams, r)
+
+	jobs, err := s.GetBuildJobs(build)
+	if err != nil {
+		closeAll(streams)
+		return nil, err
+	}
+	for _, j := range jobs {
+		if r, err := s.GetJobLogStreamWithOptions(j, opts); err == nil {
+			names = append(names, j.ID)
+			streams = append(streams, r)
+		}
+	}
+
+	chans := make([]<-chan merge.Line, len(streams))
+	for i, r := range streams {
+		chans[i] = readLines(names[i], r)
+	}
+	lines := merge.Lines(chans...)
+
+	pr, pw := io.Pipe()
+	go func() {
+		for l := range lines {
+			var err error
+			if timestamps && !l.Time.IsZero() {
+				_, err = fmt.Fprintf(pw, "[%s] %s %s\n", l.Source, l.Time.Format(time.RFC3339Nano), l.Text)
+			} else {
+				_, err = fmt.Fprintf(pw, "[%s] %s\n", l.Source, l.Text)
+			}
+			if err != nil {
+				// The reader went away. Close the logs and drain the remaining lines
+				// so that no goroutine is left behind.
+				closeAll(streams)
+				for range lines {
+				}
+				break
+			}
+		}
+		closeAll(streams)
+		pw.Close()
+	}()
+	return pr, nil
+}
+
+// readLines reads the lines of a log written with timestamps. A line without a
+// timestamp takes the time of the line before it.
+func readLines(source string, r io.Reader) <-chan merge.Line {
+	c := make(chan merge.Line)
+	go func() {
+		defer close(c)
+		var last time.Time
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			l := merge.Line{Source: source, Time: last, Text: scanner.Text()}
+			if parts := strings.SplitN(l.Text, " ", 2); len(parts) == 2 {
+				if t, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+					l.Time, l.Text = t, parts[1]
+					last = t
+				}
+			}
+			c <- l
+		}
+	}()
+	return c
+}
+
+func closeAll(streams []io.ReadCloser) {
+	for _, r := range streams {
+		r.Close()
+	}
+}
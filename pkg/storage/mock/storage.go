@@ -71,6 +71,8 @@ var (
 	StubJob = &brigade.Job{
 		ID:           "job-id",
 		Name:         "job-name",
+		BuildID:      "build-id1",
+		ProjectID:    "project-id",
 		Image:        "image",
 		CreationTime: Now,
 		StartTime:    Now,
@@ -103,8 +105,16 @@ type Store struct {
 	Workers []*brigade.Worker
 	// LogData is the log data you want returned.
 	LogData string
+	// LastLogOptions are the options of the last log stream opened with options.
+	LastLogOptions storage.LogOptions
 	// ProjectList on this mock
 	ProjectList []*brigade.Project
+	// DeleteBuildErr is the error returned by DeleteBuild.
+	DeleteBuildErr error
+	// CancelBuildErr is the error returned by CancelBuild.
+	CancelBuildErr error
+	// GetBuildLineageErr is the error returned by GetBuildLineage.
+	GetBuildLineageErr error
 }
 
 // GetProjects gets the mock project wrapped as a slice of projects.
@@ -114,6 +124,11 @@ func (s *Store) GetProjects() ([]*brigade.Project, error) {
 
 // CreateProject adds a project to the internal mock
 func (s *Store) CreateProject(p *brigade.Project) error {
+	for _, pr := range s.ProjectList {
+		if pr.ID == p.ID {
+			return storage.ErrProjectExists
+		}
+	}
 	s.ProjectList = append(s.ProjectList, p)
 	return nil
 }
@@ -121,9 +136,9 @@ func (s *Store) CreateProject(p *brigade.Project) error {
 // ReplaceProject replaces a project in the internal mock
 func (s *Store) ReplaceProject(p *brigade.Project) error {
 	found := false
-	for _, pr := range s.ProjectList {
-		if pr.Name == p.Name {
-			pr = p
+	for i, pr := range s.ProjectList {
+		if pr.ID == p.ID {
+			s.ProjectList[i] = p
 			found = true
 			break
 		}
@@ -140,7 +155,7 @@ func (s *Store) ReplaceProject(p *brigade.Project) error {
 func (s *Store) DeleteProject(id string) error {
 	tmp := []*brigade.Project{}
 	for _, p := range s.ProjectList {
-		if p.ID == id {
+		if p.ID != id {
 			tmp = append(tmp, p)
 		}
 	}
@@ -168,11 +183,29 @@ func (s *Store) GetBuilds() ([]*brigade.Build, error) {
 	return s.Builds, nil
 }
 
+// ListBuilds returns a page of the mock builds.
+func (s *Store) ListBuilds(opts storage.BuildListOptions) (*storage.BuildList, error) {
+	return storage.PageBuilds(s.Builds, opts)
+}
+
+// ListProjectBuilds returns a page of the mock builds.
+func (s *Store) ListProjectBuilds(p *brigade.Project, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	return s.ListBuilds(opts)
+}
+
 // GetBuild gets the first mock Build.
 func (s *Store) GetBuild(id string) (*brigade.Build, error) {
 	return s.Builds[0], nil
 }
 
+// GetBuildLineage gets the mock Builds, unless GetBuildLineageErr is set.
+func (s *Store) GetBuildLineage(id string) ([]*brigade.Build, error) {
+	if s.GetBuildLineageErr != nil {
+		return nil, s.GetBuildLineageErr
+	}
+	return s.Builds, nil
+}
+
 // GetBuildJobs gets the mock job wrapped in a slice.
 func (s *Store) GetBuildJobs(b *brigade.Build) ([]*brigade.Job, error) {
 	return []*brigade.Job{s.Job}, nil
@@ -203,6 +236,12 @@ func (s *Store) GetJobLogStreamFollow(j *brigade.Job) (io.ReadCloser, error) {
 	return s.GetJobLogStream(j)
 }
 
+// GetJobLogStreamWithOptions gets the mock log data as a readcloser.
+func (s *Store) GetJobLogStreamWithOptions(j *brigade.Job, opts storage.LogOptions) (io.ReadCloser, error) {
+	s.LastLogOptions = opts
+	return s.GetJobLogStream(j)
+}
+
 // GetWorkerLog gets the mock log data.
 func (s *Store) GetWorkerLog(w *brigade.Worker) (string, error) {
 	return s.LogData, nil
@@ -223,6 +262,12 @@ func (s *Store) GetWorkerLogStreamFollow(w *brigade.Worker) (io.ReadCloser, erro
 	return s.GetWorkerLogStream(w)
 }
 
+// GetWorkerLogStreamWithOptions gets a readcloser of the mock log data.
+func (s *Store) GetWorkerLogStreamWithOptions(w *brigade.Worker, opts storage.LogOptions) (io.ReadCloser, error) {
+	s.LastLogOptions = opts
+	return s.GetWorkerLogStream(w)
+}
+
 // CreateBuild fakes a new build.
 func (s *Store) CreateBuild(b *brigade.Build) error {
 	s.Builds = append(s.Builds, b)
@@ -236,7 +281,12 @@ func (s *Store) GetStorageClassNames() ([]string, error) {
 
 // DeleteBuild fakes a build deletion.
 func (s *Store) DeleteBuild(bid string, options storage.DeleteBuildOptions) error {
-	return nil
+	return s.DeleteBuildErr
+}
+
+// CancelBuild fakes a build cancellation. It returns CancelBuildErr.
+func (s *Store) CancelBuild(bid string) error {
+	return s.CancelBuildErr
 }
 
 // rc wraps a string in a ReadCloser.
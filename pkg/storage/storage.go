@@ -1,16 +1,45 @@
 package storage
 
 import (
+	"errors"
 	"io"
 
 	"github.com/brigadecore/brigade/pkg/brigade"
 )
 
+// ErrBuildFinished indicates that a build could not be cancelled because its
+// worker has already terminated, or because it was already cancelled, timed
+// out or superseded.
+var ErrBuildFinished = errors.New("build has already finished")
+
+// ErrBuildRunning indicates that a build was not deleted because its worker is
+// still running and running builds were to be skipped.
+var ErrBuildRunning = errors.New("build is still running")
+
+// ErrProjectExists indicates that a project could not be created because a
+// project with the same ID already exists.
+var ErrProjectExists = errors.New("project already exists")
+
 // DeleteBuildOptions represents options for a build deletion
 type DeleteBuildOptions struct {
 	SkipRunningBuilds bool
 }
 
+// LogOptions represents options for retrieving a stream of logs.
+type LogOptions struct {
+	// Follow keeps the stream open and returns new log lines as they are written.
+	Follow bool
+	// TailLines is the number of lines from the end of the log to return. 0 returns all lines.
+	TailLines int64
+	// SinceSeconds only returns log lines written in the last SinceSeconds seconds. 0 returns all lines.
+	SinceSeconds int64
+	// Timestamps prefixes every line with an RFC 3339 timestamp.
+	Timestamps bool
+	// Container selects a container of a worker, such as its vcs-sidecar init
+	// container. The worker container is used if empty.
+	Container string
+}
+
 // ProjectStore represents storage for projects.
 type ProjectStore interface {
 	// GetProjects retrieves all projects from storage.
@@ -19,6 +48,8 @@ type ProjectStore interface {
 	GetProject(id string) (*brigade.Project, error)
 	// GetProjectBuilds retrieves the project's builds from storage.
 	GetProjectBuilds(proj *brigade.Project) ([]*brigade.Build, error)
+	// ListProjectBuilds retrieves a filtered, sorted page of the project's builds from storage.
+	ListProjectBuilds(proj *brigade.Project, opts BuildListOptions) (*BuildList, error)
 	// CreateProject creates a new project record in storage.
 	CreateProject(proj *brigade.Project) error
 	// ReplaceProject replaces a project record in storage.
@@ -32,10 +63,17 @@ type Store interface {
 	ProjectStore
 	// GetBuilds retrieves all active builds from storage.
 	GetBuilds() ([]*brigade.Build, error)
+	// ListBuilds retrieves a filtered, sorted page of builds from storage.
+	ListBuilds(opts BuildListOptions) (*BuildList, error)
 	// GetBuild retrieves the build from storage.
 	GetBuild(id string) (*brigade.Build, error)
+	// GetBuildLineage retrieves the builds of the rerun chain of a build from
+	// storage, oldest first.
+	GetBuildLineage(id string) ([]*brigade.Build, error)
 	// DeleteBuild deletes the build from storage.
 	DeleteBuild(id string, options DeleteBuildOptions) error
+	// CancelBuild stops the build's worker and jobs, and marks the build as cancelled.
+	CancelBuild(id string) error
 	// CreateBuild creates a new job for the work queue.
 	CreateBuild(build *brigade.Build) error
 	// GetBuildJobs retrieves all build jobs (pods) from storage.
@@ -50,6 +88,8 @@ type Store interface {
 	GetJobLogStream(job *brigade.Job) (io.ReadCloser, error)
 	// GetJobLogStreamFollow retrieve a follow stream of all logs for a job from storage.
 	GetJobLogStreamFollow(job *brigade.Job) (io.ReadCloser, error)
+	// GetJobLogStreamWithOptions retrieve a stream of logs for a job from storage.
+	GetJobLogStreamWithOptions(job *brigade.Job, opts LogOptions) (io.ReadCloser, error)
 	// GetWorkerInitLog retrieves all logs for a worker's init container from storage.
 	GetWorkerInitLog(job *brigade.Worker) (string, error)
 	// GetWorkerLog retrieves all logs for a worker from storage.
@@ -58,6 +98,8 @@ type Store interface {
 	GetWorkerLogStream(job *brigade.Worker) (io.ReadCloser, error)
 	// GetWorkerLogStreamFollow retrieve a followed stream of all logs for a worker from storage.
 	GetWorkerLogStreamFollow(job *brigade.Worker) (io.ReadCloser, error)
+	// GetWorkerLogStreamWithOptions retrieve a stream of logs for a worker from storage.
+	GetWorkerLogStreamWithOptions(job *brigade.Worker, opts LogOptions) (io.ReadCloser, error)
 	// GetStorageClassNames returns the names of the StorageClass instances in the cluster
 	GetStorageClassNames() ([]string, error)
 }
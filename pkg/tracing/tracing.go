@@ -0,0 +1,115 @@
+// Package tracing traces builds through the Brigade services with
+// OpenTelemetry.
+//
+// The trace context of a build is started where the build is created, by a
+// gateway or the brig CLI, and stored on the build secret as a W3C
+// traceparent. The controller and the API continue the trace of a build from
+// there, and the controller passes it to the worker in the TRACEPARENT
+// environment variable.
+package tracing
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EnvTraceparent is the environment variable that holds the traceparent of a
+// build in its worker.
+const EnvTraceparent = "TRACEPARENT"
+
+// instrumentation is the name that spans of Brigade are created with.
+const instrumentation = "github.com/brigadecore/brigade"
+
+func init() {
+	// Trace contexts are generated and propagated even if spans are not
+	// exported, so that a build can still be traced by the services that do
+	// export them.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+}
+
+// Config configures the export of spans.
+type Config struct {
+	// Endpoint is the host:port of an OTLP gRPC collector, such as
+	// localhost:4317. Spans are not exported if it is empty.
+	Endpoint string
+}
+
+// AddFlags adds flags for the tracing configuration to fs. Their defaults are
+// read from the environment.
+func (c *Config) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Endpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "host:port of an OTLP gRPC collector to export trace spans to, if not given spans are not exported")
+}
+
+// Start exports the spans of a service to the collector of c. The returned
+// function flushes the spans that were not exported yet, and should be called
+// before the service exits.
+//
+// If no collector is configured, spans are not exported and Start does
+// nothing.
+func Start(service string, c Config) (func(), error) {
+	if c.Endpoint == "" {
+		return func() {}, nil
+	}
+	exporter, err := otlp.NewExporter(context.Background(), otlp.WithInsecure(), otlp.WithAddress(c.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(service))),
+	)
+	otel.SetTracerProvider(provider)
+	return func() {
+		provider.Shutdown(context.Background())
+		exporter.Shutdown(context.Background())
+	}, nil
+}
+
+// Tracer returns the tracer that Brigade creates spans with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Traceparent returns the W3C traceparent of the span of ctx, or an empty
+// string if ctx has no span.
+func Traceparent(ctx context.Context) string {
+	c := carrier{}
+	otel.GetTextMapPropagator().Inject(ctx, c)
+	return c[traceparentHeader]
+}
+
+// WithTraceparent returns a copy of ctx which continues the trace of a
+// traceparent. ctx is returned as is if traceparent is empty or invalid.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier{traceparentHeader: traceparent})
+}
+
+// FromRequest returns a copy of the context of r which continues the trace of
+// the traceparent header of r, if it has one.
+func FromRequest(r *http.Request) context.Context {
+	return WithTraceparent(r.Context(), r.Header.Get(traceparentHeader))
+}
+
+// traceparentHeader is the key of the traceparent of the W3C trace context
+// propagator.
+const traceparentHeader = "traceparent"
+
+// carrier carries a trace context in a map.
+type carrier map[string]string
+
+func (c carrier) Get(key string) string        { return c[key] }
+func (c carrier) Set(key string, value string) { c[key] = value }
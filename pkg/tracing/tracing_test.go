@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceparent(t *testing.T) {
+	if tp := Traceparent(context.Background()); tp != "" {
+		t.Errorf("expected no traceparent without a span, got %q", tp)
+	}
+
+	ctx, span := Tracer().Start(context.Background(), "test")
+	defer span.End()
+	tp := Traceparent(ctx)
+	if !strings.HasPrefix(tp, "00-"+span.SpanContext().TraceID.String()+"-") {
+		t.Fatalf("expected the traceparent of the span, got %q", tp)
+	}
+
+	remote := trace.RemoteSpanContextFromContext(WithTraceparent(context.Background(), tp))
+	if remote.TraceID != span.SpanContext().TraceID || remote.SpanID != span.SpanContext().SpanID {
+		t.Errorf("expected the trace of %q to be continued, got %s-%s", tp, remote.TraceID, remote.SpanID)
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("traceparent", tp)
+
+	ctx, span := Tracer().Start(FromRequest(r), "test")
+	defer span.End()
+	if got := span.SpanContext().TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the trace of the request to be continued, got %s", got)
+	}
+	if got := Traceparent(ctx); got == tp {
+		t.Error("expected the span to have its own ID")
+	}
+
+	if ctx := WithTraceparent(context.Background(), "invalid"); trace.RemoteSpanContextFromContext(ctx).IsValid() {
+		t.Error("expected an invalid traceparent to be ignored")
+	}
+}
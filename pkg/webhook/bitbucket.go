@@ -0,0 +1,402 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// bitbucketPullRequestEvents are the Bitbucket Cloud pull request events that
+// builds are created for.
+var bitbucketPullRequestEvents = map[string]bool{
+	"pullrequest:created": true,
+	"pullrequest:updated": true,
+}
+
+// bitbucketServerPullRequestEvents are the Bitbucket Server pull request events
+// that builds are created for. pr:modified is not one of them, since it is sent
+// for edits of the title, description or target branch, which leave the source
+// commit as it is.
+var bitbucketServerPullRequestEvents = map[string]bool{
+	"pr:opened":           true,
+	"pr:from_ref_updated": true,
+}
+
+type bitbucketHook struct {
+	store storage.Store
+}
+
+// bitbucketRepository is a repository of a Bitbucket event.
+type bitbucketRepository struct {
+	FullName string `json:"full_name"`
+	Links    struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+// cloneURL returns the HTTPS clone URL of the repository.
+func (r bitbucketRepository) cloneURL() string {
+	return r.Links.HTML.Href + ".git"
+}
+
+// bitbucketCommit is a commit of a Bitbucket event.
+type bitbucketCommit struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+}
+
+// bitbucketPushEvent is a Bitbucket repo:push event.
+type bitbucketPushEvent struct {
+	Repository bitbucketRepository `json:"repository"`
+	Push       struct {
+		Changes []struct {
+			// New is the ref after the push. It is nil for deleted refs.
+			New *struct {
+				Type   string          `json:"type"`
+				Name   string          `json:"name"`
+				Target bitbucketCommit `json:"target"`
+			} `json:"new"`
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+// bitbucketEndpoint is the source or destination of a Bitbucket pull request.
+type bitbucketEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit     bitbucketCommit     `json:"commit"`
+	Repository bitbucketRepository `json:"repository"`
+}
+
+// bitbucketPullRequestEvent is a Bitbucket pullrequest event.
+type bitbucketPullRequestEvent struct {
+	Repository  bitbucketRepository `json:"repository"`
+	PullRequest struct {
+		ID          int               `json:"id"`
+		Title       string            `json:"title"`
+		Description string            `json:"description"`
+		Source      bitbucketEndpoint `json:"source"`
+		Destination bitbucketEndpoint `json:"destination"`
+	} `json:"pullrequest"`
+}
+
+// bitbucketServerRepository is a repository of a Bitbucket Server event.
+type bitbucketServerRepository struct {
+	Slug    string `json:"slug"`
+	Project struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []struct {
+			Href string `json:"href"`
+			Name string `json:"name"`
+		} `json:"clone"`
+	} `json:"links"`
+}
+
+// fullName returns the project key and slug of the repository.
+func (r bitbucketServerRepository) fullName() string {
+	return r.Project.Key + "/" + r.Slug
+}
+
+// cloneURL returns the HTTP clone URL of the repository, or its first clone
+// URL if it has none.
+func (r bitbucketServerRepository) cloneURL() string {
+	for _, link := range r.Links.Clone {
+		if link.Name == "http" {
+			return link.Href
+		}
+	}
+	if len(r.Links.Clone) > 0 {
+		return r.Links.Clone[0].Href
+	}
+	return ""
+}
+
+// bitbucketServerRefsChangedEvent is a Bitbucket Server repo:refs_changed
+// event.
+type bitbucketServerRefsChangedEvent struct {
+	Repository bitbucketServerRepository `json:"repository"`
+	Changes    []struct {
+		Ref struct {
+			ID        string `json:"id"`
+			DisplayID string `json:"displayId"`
+			Type      string `json:"type"`
+		} `json:"ref"`
+		ToHash string `json:"toHash"`
+		// Type is ADD, UPDATE or DELETE.
+		Type string `json:"type"`
+	} `json:"changes"`
+}
+
+// bitbucketServerRef is the source or destination of a Bitbucket Server pull
+// request.
+type bitbucketServerRef struct {
+	ID           string                    `json:"id"`
+	LatestCommit string                    `json:"latestCommit"`
+	Repository   bitbucketServerRepository `json:"repository"`
+}
+
+// bitbucketServerPullRequestEvent is a Bitbucket Server pr event.
+type bitbucketServerPullRequestEvent struct {
+	PullRequest struct {
+		ID          int                `json:"id"`
+		Title       string             `json:"title"`
+		Description string             `json:"description"`
+		FromRef     bitbucketServerRef `json:"fromRef"`
+		ToRef       bitbucketServerRef `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+// NewBitbucketHook creates a Bitbucket Cloud and Bitbucket Server webhook
+// handler.
+//
+// Like the Docker Hub hook, the project of an event is named by the route of
+// the handler. Events are authenticated with the X-Hub-Signature header, which
+// is a SHA256 HMAC of the body keyed with the shared secret of the project.
+func NewBitbucketHook(s storage.Store) gin.HandlerFunc {
+	h := &bitbucketHook{store: s}
+	return h.Handle
+}
+
+// Handle handles a push or pull request event from Bitbucket.
+func (s *bitbucketHook) Handle(c *gin.Context) {
+	ctx, span := receive(c, "bitbucket")
+	defer span.End()
+	pname := projectName(c)
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("Failed to read body: %s", err)
+		reject(span, "bitbucket", reasonMalformedBody)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	defer c.Request.Body.Close()
+
+	proj, err := s.store.GetProject(pname)
+	if err != nil {
+		log.Printf("Project %q not found. No secret loaded. %s", pname, err)
+		reject(span, "bitbucket", reasonProjectNotFound)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
+		return
+	}
+
+	if err := validateBitbucketSignature(c.Request.Header.Get("X-Hub-Signature"), proj.SharedSecret, body); err != nil {
+		log.Printf("Rejecting Bitbucket event of %s: %s", pname, err)
+		reject(span, "bitbucket", reasonUnauthorized)
+		c.JSON(http.StatusForbidden, gin.H{"status": "signature does not match"})
+		return
+	}
+
+	event := c.Request.Header.Get("X-Event-Key")
+	builds, err := bitbucketBuilds(proj, event, body)
+	if err != nil {
+		log.Printf("Failed to parse Bitbucket %s event: %s", event, err)
+		reject(span, "bitbucket", reasonInvalidEvent)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	if event == "pullrequest:updated" {
+		builds, err = unbuilt(s.store, proj, builds)
+		if err != nil {
+			log.Printf("Failed to list builds of %s: %s", pname, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"status": "could not list builds"})
+			return
+		}
+	}
+	if len(builds) == 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "event ignored"})
+		return
+	}
+
+	go s.notifyBitbucketEvent(ctx, builds)
+	c.JSON(http.StatusOK, gin.H{"status": "Success"})
+}
+
+func (s *bitbucketHook) notifyBitbucketEvent(ctx context.Context, builds []*brigade.Build) {
+	for _, b := range builds {
+		if err := createBuild(ctx, s.store, b); err != nil {
+			log.Printf("failed Bitbucket %s event: %s", b.Type, err)
+		}
+	}
+}
+
+// unbuilt returns the builds whose commit has not been built for their ref and
+// event type yet. Bitbucket Cloud sends pullrequest:updated both when commits
+// are pushed to a pull request and when its title or description is edited,
+// without telling which.
+func unbuilt(s storage.Store, proj *brigade.Project, builds []*brigade.Build) ([]*brigade.Build, error) {
+	result := []*brigade.Build{}
+	for _, b := range builds {
+		existing, err := s.ListProjectBuilds(proj, storage.BuildListOptions{
+			Limit:    1,
+			Type:     b.Type,
+			Provider: b.Provider,
+			Commit:   b.Revision.Commit,
+			Ref:      b.Revision.Ref,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(existing.Items) > 0 {
+			log.Printf("Skipping build of %s of %s, which was built already", b.Revision.Commit, b.Revision.Ref)
+			continue
+		}
+		result = append(result, b)
+	}
+	return result, nil
+}
+
+// bitbucketBuilds returns the builds of a Bitbucket Cloud or Bitbucket Server
+// event. A push creates a build for each branch or tag it updates.
+//
+// Builds of pull requests from forks clone the fork, at the source branch of
+// the pull request. Other builds clone the repository of the project. Pull
+// requests from forks that are not trusted, see trustedFork, are not built.
+func bitbucketBuilds(proj *brigade.Project, event string, body []byte) ([]*brigade.Build, error) {
+	newBuild := func(eventType string) *brigade.Build {
+		b := &brigade.Build{
+			ProjectID: proj.ID,
+			Type:      eventType,
+			Provider:  "bitbucket",
+			Revision:  &brigade.Revision{},
+			Payload:   body,
+		}
+		if proj.DefaultScript != "" {
+			b.Script = []byte(proj.DefaultScript)
+		}
+		return b
+	}
+
+	switch {
+	case event == "repo:push":
+		e := bitbucketPushEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		builds := []*brigade.Build{}
+		for _, change := range e.Push.Changes {
+			if change.New == nil {
+				log.Printf("Skipping build of deleted ref of %s", e.Repository.FullName)
+				continue
+			}
+			var b *brigade.Build
+			switch change.New.Type {
+			case "branch":
+				b = newBuild("push")
+				b.Revision.Ref = "refs/heads/" + change.New.Name
+			case "tag":
+				b = newBuild("tag")
+				b.Revision.Ref = "refs/tags/" + change.New.Name
+			default:
+				continue
+			}
+			b.Revision.Commit = change.New.Target.Hash
+			b.ShortTitle, b.LongTitle = commitTitles(change.New.Target.Message)
+			builds = append(builds, b)
+		}
+		return builds, nil
+	case bitbucketPullRequestEvents[event]:
+		e := bitbucketPullRequestEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		pr := e.PullRequest
+		if pr.ID == 0 {
+			return nil, errors.New("pull request has no id")
+		}
+		b := newBuild("pull_request")
+		b.Revision.Commit = pr.Source.Commit.Hash
+		b.Revision.Ref = "refs/heads/" + pr.Source.Branch.Name
+		if source, target := pr.Source.Repository.FullName, pr.Destination.Repository.FullName; source != target {
+			if !trustedFork(proj, source, target) {
+				log.Printf("Skipping build of pull request %d of %s from untrusted fork %s", pr.ID, target, source)
+				return nil, nil
+			}
+			b.CloneURL = pr.Source.Repository.cloneURL()
+		}
+		b.ShortTitle = pr.Title
+		b.LongTitle = pr.Description
+		return []*brigade.Build{b}, nil
+	case event == "repo:refs_changed":
+		e := bitbucketServerRefsChangedEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		builds := []*brigade.Build{}
+		for _, change := range e.Changes {
+			if change.Type == "DELETE" {
+				log.Printf("Skipping build of deleted ref %s of %s", change.Ref.ID, e.Repository.fullName())
+				continue
+			}
+			var b *brigade.Build
+			switch change.Ref.Type {
+			case "BRANCH":
+				b = newBuild("push")
+			case "TAG":
+				b = newBuild("tag")
+			default:
+				continue
+			}
+			b.Revision.Ref = change.Ref.ID
+			b.Revision.Commit = change.ToHash
+			// The event does not have the commit message.
+			b.ShortTitle = change.Ref.DisplayID
+			b.LongTitle = change.Ref.DisplayID + " at " + change.ToHash
+			builds = append(builds, b)
+		}
+		return builds, nil
+	case bitbucketServerPullRequestEvents[event]:
+		e := bitbucketServerPullRequestEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		pr := e.PullRequest
+		if pr.ID == 0 {
+			return nil, errors.New("pull request has no id")
+		}
+		b := newBuild("pull_request")
+		b.Revision.Commit = pr.FromRef.LatestCommit
+		b.Revision.Ref = pr.FromRef.ID
+		if source, target := pr.FromRef.Repository.fullName(), pr.ToRef.Repository.fullName(); source != target {
+			if !trustedFork(proj, source, target) {
+				log.Printf("Skipping build of pull request %d of %s from untrusted fork %s", pr.ID, target, source)
+				return nil, nil
+			}
+			b.CloneURL = pr.FromRef.Repository.cloneURL()
+		}
+		b.ShortTitle = pr.Title
+		b.LongTitle = pr.Description
+		return []*brigade.Build{b}, nil
+	}
+	log.Printf("Ignoring Bitbucket event %q", event)
+	return nil, nil
+}
+
+// validateBitbucketSignature returns an error if the signature of a Bitbucket
+// event does not match its body.
+func validateBitbucketSignature(signature, secret string, body []byte) error {
+	if secret == "" {
+		return errors.New("project has no shared secret")
+	}
+	if signature == "" {
+		return errors.New("no X-Hub-Signature header")
+	}
+	if !hmac.Equal([]byte(signature), []byte(SHA256HMAC([]byte(secret), body))) {
+		return errors.New("X-Hub-Signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestBitbucketHandler(t *testing.T) {
+	push := readTestdata(t, "bitbucket-push-payload.json")
+	refsChanged := readTestdata(t, "bitbucket-server-refs_changed-payload.json")
+	tests := []struct {
+		name      string
+		event     string
+		body      []byte
+		signature string
+		store     func(*testStore)
+		expected  int
+	}{
+		{"valid signature", "repo:push", push, SHA256HMAC([]byte("asdf"), push), nil, http.StatusOK},
+		{"bitbucket server", "repo:refs_changed", refsChanged, SHA256HMAC([]byte("asdf"), refsChanged), nil, http.StatusOK},
+		{"wrong secret", "repo:push", push, SHA256HMAC([]byte("nope"), push), nil, http.StatusForbidden},
+		{"sha1 signature", "repo:push", push, SHA1HMAC([]byte("asdf"), push), nil, http.StatusForbidden},
+		{"no signature", "repo:push", push, "", nil, http.StatusForbidden},
+		{"project without shared secret", "repo:push", push, SHA256HMAC([]byte(""), push), func(s *testStore) { s.proj.SharedSecret = "" }, http.StatusForbidden},
+		{"project not found", "repo:push", push, SHA256HMAC([]byte("asdf"), push), func(s *testStore) { s.err = errors.New("not found") }, http.StatusBadRequest},
+		{"malformed body", "repo:push", []byte("{"), SHA256HMAC([]byte("asdf"), []byte("{")), nil, http.StatusBadRequest},
+		{"unsupported event", "repo:fork", []byte("{}"), SHA256HMAC([]byte("asdf"), []byte("{}")), nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore()
+			if tt.store != nil {
+				tt.store(store)
+			}
+			router := gin.New()
+			router.POST("/events/bitbucket/:org/:repo", NewBitbucketHook(store))
+
+			req, err := http.NewRequest("POST", "/events/bitbucket/emmap1/brigade-test", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Event-Key", tt.event)
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature", tt.signature)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			if rw.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestBitbucketBuilds_Push(t *testing.T) {
+	proj := newProject()
+	proj.DefaultScript = `console.log("hello default script")`
+	body := readTestdata(t, "bitbucket-push-payload.json")
+
+	builds, err := bitbucketBuilds(proj, "repo:push", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The deleted branch is not built.
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(builds))
+	}
+
+	b := builds[0]
+	if b.ProjectID != proj.ID || b.Provider != "bitbucket" || b.Type != "push" {
+		t.Errorf("unexpected build %+v", b)
+	}
+	if b.Revision.Ref != "refs/heads/master" || b.Revision.Commit != "709d658dc5b6d6afcd46049c2f332ee3f515a67d" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "Add a brigade.js" || b.LongTitle != "Add a brigade.js\n\nIt runs the tests." {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+	if string(b.Script) != proj.DefaultScript {
+		t.Errorf("unexpected build script: %s", b.Script)
+	}
+
+	if b := builds[1]; b.Type != "tag" || b.Revision.Ref != "refs/tags/v1.0.0" {
+		t.Errorf("expected tag build of refs/tags/v1.0.0, got %s build of %s", b.Type, b.Revision.Ref)
+	}
+}
+
+func TestBitbucketBuilds_PullRequest(t *testing.T) {
+	body := readTestdata(t, "bitbucket-pullrequest-payload.json")
+	builds, err := bitbucketBuilds(newProject(), "pullrequest:created", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 1 {
+		t.Fatalf("expected 1 build, got %d", len(builds))
+	}
+	b := builds[0]
+	if b.Type != "pull_request" {
+		t.Errorf("unexpected type %s", b.Type)
+	}
+	if b.Revision.Ref != "refs/heads/pr-tests" || b.Revision.Commit != "d3022fc0ca3d" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "Run the tests on pull requests" || b.LongTitle != "Adds a pull_request handler to brigade.js." {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+}
+
+func TestBitbucketBuilds_PullRequestFromFork(t *testing.T) {
+	tests := []struct {
+		name           string
+		fork           string
+		buildUntrusted bool
+		build          bool
+	}{
+		{"untrusted fork", "forker/brigade-test", false, false},
+		{"untrusted fork of a project that builds untrusted authors", "forker/brigade-test", true, true},
+		{"fork in the workspace of the repository", "emmap1/brigade-test-fork", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := map[string]interface{}{}
+			if err := json.Unmarshal(readTestdata(t, "bitbucket-pullrequest-payload.json"), &event); err != nil {
+				t.Fatal(err)
+			}
+			source := event["pullrequest"].(map[string]interface{})["source"].(map[string]interface{})
+			source["repository"] = map[string]interface{}{
+				"full_name": tt.fork,
+				"links": map[string]interface{}{
+					"html": map[string]interface{}{"href": "https://bitbucket.org/" + tt.fork},
+				},
+			}
+			body, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proj := newProject()
+			proj.Github.BuildUntrusted = tt.buildUntrusted
+			builds, err := bitbucketBuilds(proj, "pullrequest:updated", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.build {
+				if len(builds) != 0 {
+					t.Errorf("expected no builds, got %d", len(builds))
+				}
+				return
+			}
+			if len(builds) != 1 {
+				t.Fatalf("expected 1 build, got %d", len(builds))
+			}
+			if b := builds[0]; b.CloneURL != "https://bitbucket.org/"+tt.fork+".git" || b.Revision.Ref != "refs/heads/pr-tests" {
+				t.Errorf("expected build of pr-tests of the fork, got %q of %q", b.Revision.Ref, b.CloneURL)
+			}
+		})
+	}
+}
+
+func TestBitbucketHandler_PullRequestUpdated(t *testing.T) {
+	body := readTestdata(t, "bitbucket-pullrequest-payload.json")
+	tests := []struct {
+		name     string
+		builds   []*brigade.Build
+		expected string
+	}{
+		{"new commit", nil, "Success"},
+		// Edits of the title or description send the same event, for a
+		// commit that was built already.
+		{"title edit", []*brigade.Build{{
+			Type:     "pull_request",
+			Provider: "bitbucket",
+			Revision: &brigade.Revision{Commit: "d3022fc0ca3d", Ref: "refs/heads/pr-tests"},
+		}}, "event ignored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore()
+			store.builds = tt.builds
+			router := gin.New()
+			router.POST("/events/bitbucket/:org/:repo", NewBitbucketHook(store))
+
+			req, err := http.NewRequest("POST", "/events/bitbucket/emmap1/brigade-test", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Event-Key", "pullrequest:updated")
+			req.Header.Set("X-Hub-Signature", SHA256HMAC([]byte("asdf"), body))
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), tt.expected) {
+				t.Errorf("expected %q, got %d: %s", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestBitbucketBuilds_ServerRefsChanged(t *testing.T) {
+	builds, err := bitbucketBuilds(newProject(), "repo:refs_changed", readTestdata(t, "bitbucket-server-refs_changed-payload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The deleted branch is not built.
+	if len(builds) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(builds))
+	}
+	b := builds[0]
+	if b.Provider != "bitbucket" || b.Type != "push" {
+		t.Errorf("unexpected build %+v", b)
+	}
+	if b.Revision.Ref != "refs/heads/master" || b.Revision.Commit != "178864a7d521b6f5e720b386b2c2b0ef8563e0dc" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "master" || b.LongTitle != "master at 178864a7d521b6f5e720b386b2c2b0ef8563e0dc" {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+	if b := builds[1]; b.Type != "tag" || b.Revision.Ref != "refs/tags/v1.0.0" {
+		t.Errorf("expected tag build of refs/tags/v1.0.0, got %s build of %s", b.Type, b.Revision.Ref)
+	}
+}
+
+func TestBitbucketBuilds_ServerPullRequest(t *testing.T) {
+	body := readTestdata(t, "bitbucket-server-pr-payload.json")
+	for _, event := range []string{"pr:opened", "pr:from_ref_updated"} {
+		builds, err := bitbucketBuilds(newProject(), event, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(builds) != 1 {
+			t.Fatalf("expected 1 build of %s, got %d", event, len(builds))
+		}
+		b := builds[0]
+		if b.Type != "pull_request" {
+			t.Errorf("unexpected type %s", b.Type)
+		}
+		if b.Revision.Ref != "refs/heads/pr-tests" || b.Revision.Commit != "d3022fc0ca3d65c7f6b1d4a6d4c0f3a5e2b1c0d9" {
+			t.Errorf("unexpected revision %+v", b.Revision)
+		}
+		if b.ShortTitle != "Run the tests on pull requests" || b.LongTitle != "Adds a pull_request handler to brigade.js." {
+			t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+		}
+		if b.CloneURL != "" {
+			t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+		}
+	}
+}
+
+func TestBitbucketBuilds_ServerPullRequestFromFork(t *testing.T) {
+	tests := []struct {
+		name           string
+		key, slug      string
+		buildUntrusted bool
+		build          bool
+	}{
+		{"untrusted fork", "~FORKER", "brigade-test", false, false},
+		{"untrusted fork of a project that builds untrusted authors", "~FORKER", "brigade-test", true, true},
+		{"fork in the project of the repository", "EMMA", "brigade-test-fork", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := map[string]interface{}{}
+			if err := json.Unmarshal(readTestdata(t, "bitbucket-server-pr-payload.json"), &event); err != nil {
+				t.Fatal(err)
+			}
+			cloneURL := "https://bitbucket.example.com/scm/" + strings.ToLower(tt.key) + "/" + tt.slug + ".git"
+			fromRef := event["pullRequest"].(map[string]interface{})["fromRef"].(map[string]interface{})
+			fromRef["repository"] = map[string]interface{}{
+				"slug":    tt.slug,
+				"project": map[string]interface{}{"key": tt.key},
+				"links": map[string]interface{}{
+					"clone": []interface{}{
+						map[string]interface{}{"href": "ssh://git@bitbucket.example.com:7999/" + strings.ToLower(tt.key) + "/" + tt.slug + ".git", "name": "ssh"},
+						map[string]interface{}{"href": cloneURL, "name": "http"},
+					},
+				},
+			}
+			body, err := json.Marshal(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			proj := newProject()
+			proj.Github.BuildUntrusted = tt.buildUntrusted
+			builds, err := bitbucketBuilds(proj, "pr:from_ref_updated", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.build {
+				if len(builds) != 0 {
+					t.Errorf("expected no builds, got %d", len(builds))
+				}
+				return
+			}
+			if len(builds) != 1 {
+				t.Fatalf("expected 1 build, got %d", len(builds))
+			}
+			if b := builds[0]; b.CloneURL != cloneURL || b.Revision.Ref != "refs/heads/pr-tests" {
+				t.Errorf("expected build of pr-tests of the fork, got %q of %q", b.Revision.Ref, b.CloneURL)
+			}
+		})
+	}
+}
+
+func TestBitbucketBuilds_ServerPullRequestTitleEdit(t *testing.T) {
+	body := bytes.Replace(readTestdata(t, "bitbucket-server-pr-payload.json"),
+		[]byte(`"eventKey": "pr:opened",`),
+		[]byte(`"eventKey": "pr:modified", "previousTitle": "Run tests", "previousDescription": "Adds a pull_request handler to brigade.js.",`), 1)
+	builds, err := bitbucketBuilds(newProject(), "pr:modified", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) != 0 {
+		t.Errorf("expected no builds of a title edit, got %d", len(builds))
+	}
+}
+
+func TestBitbucketBuilds_Ignored(t *testing.T) {
+	tests := []struct {
+		event string
+		file  string
+	}{
+		{"pullrequest:comment_created", "bitbucket-pullrequest-payload.json"},
+		{"pullrequest:fulfilled", "bitbucket-pullrequest-payload.json"},
+		{"pullrequest:rejected", "bitbucket-pullrequest-payload.json"},
+		{"pr:merged", "bitbucket-server-pr-payload.json"},
+		{"pr:declined", "bitbucket-server-pr-payload.json"},
+		{"pr:comment:added", "bitbucket-server-pr-payload.json"},
+		{"diagnostics:ping", "bitbucket-server-pr-payload.json"},
+	}
+	for _, tt := range tests {
+		builds, err := bitbucketBuilds(newProject(), tt.event, readTestdata(t, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(builds) != 0 {
+			t.Errorf("expected no builds of %s, got %d", tt.event, len(builds))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package webhook
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
 )
 
@@ -15,3 +16,12 @@ func SHA1HMAC(salt, message []byte) string {
 	sum := digest.Sum(nil)
 	return fmt.Sprintf("sha1=%x", sum)
 }
+
+// SHA256HMAC computes the GitHub SHA256 HMAC, which GitHub sends in the
+// X-Hub-Signature-256 header.
+func SHA256HMAC(salt, message []byte) string {
+	digest := hmac.New(sha256.New, salt)
+	digest.Write(message)
+	sum := digest.Sum(nil)
+	return fmt.Sprintf("sha256=%x", sum)
+}
@@ -12,3 +12,12 @@ func TestSHA1HMAC(t *testing.T) {
 		t.Fatalf("Expected \n\t%q, got\n\t%q", expect, got)
 	}
 }
+
+func TestSHA256HMAC(t *testing.T) {
+	salt := []byte("This is the way the world ends.")
+	message := []byte("Not with a bang, but a whimper.\n")
+	expect := "sha256=67c415daa6ed986b067ea83ecde5abf0dc65b454c5bb8ff6b6d860bf0df0059c"
+	if got := SHA256HMAC(salt, message); got != expect {
+		t.Fatalf("Expected \n\t%q, got\n\t%q", expect, got)
+	}
+}
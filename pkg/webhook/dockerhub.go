@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -24,15 +25,10 @@ func NewDockerPushHook(s storage.Store) gin.HandlerFunc {
 
 // Handle handles a Push webhook event from DockerHub or a compatible agent.
 func (s *dockerPushHook) Handle(c *gin.Context) {
-	var pname, commitish string
-	orgName := c.Param("org")
-	projName := c.Param("repo")
-	log.Println(projName)
-	if projName != "" {
-		pname = fmt.Sprintf("%s/%s", orgName, projName)
-	} else {
-		pname = orgName
-	}
+	ctx, span := receive(c, "dockerhub")
+	defer span.End()
+	var commitish string
+	pname := projectName(c)
 	if commitish = c.Query("commit"); commitish == "" {
 		commitish = c.Param("commit")
 	}
@@ -41,6 +37,7 @@ func (s *dockerPushHook) Handle(c *gin.Context) {
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %s", err)
+		reject(span, "dockerhub", reasonMalformedBody)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
 		return
 	}
@@ -49,6 +46,7 @@ func (s *dockerPushHook) Handle(c *gin.Context) {
 	proj, err := s.store.GetProject(pname)
 	if err != nil {
 		log.Printf("Project %q not found. No secret loaded. %s", pname, err)
+		reject(span, "dockerhub", reasonProjectNotFound)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
 		return
 	}
@@ -56,22 +54,33 @@ func (s *dockerPushHook) Handle(c *gin.Context) {
 	// Guard to make sure empty URL isn't sent to GitHub
 	if proj.Repo.Name == "" {
 		log.Printf("No Repo.Name on project")
+		reject(span, "dockerhub", reasonScriptNotFound)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "brigadejs not found"})
 		return
 	}
 
-	go s.notifyDockerImagePush(proj, commitish, body)
+	go s.notifyDockerImagePush(ctx, proj, commitish, body)
 	c.JSON(200, gin.H{"status": "Success"})
 }
 
-func (s *dockerPushHook) notifyDockerImagePush(proj *brigade.Project, commitish string, payload []byte) {
-	if err := s.doDockerImagePush(proj, commitish, payload); err != nil {
+// projectName returns the name of the project of a webhook, from the :org and
+// :repo parameters of its route. Routes without :repo name the project with
+// :org alone, which can also be the ID of the project.
+func projectName(c *gin.Context) string {
+	if repo := c.Param("repo"); repo != "" {
+		return fmt.Sprintf("%s/%s", c.Param("org"), repo)
+	}
+	return c.Param("org")
+}
+
+func (s *dockerPushHook) notifyDockerImagePush(ctx context.Context, proj *brigade.Project, commitish string, payload []byte) {
+	if err := s.doDockerImagePush(ctx, proj, commitish, payload); err != nil {
 		log.Printf("failed dockerimagepush event: %s", err)
 	}
 
 }
 
-func (s *dockerPushHook) doDockerImagePush(proj *brigade.Project, commitish string, payload []byte) error {
+func (s *dockerPushHook) doDockerImagePush(ctx context.Context, proj *brigade.Project, commitish string, payload []byte) error {
 	b := &brigade.Build{
 		ProjectID: proj.ID,
 		Type:      "image_push",
@@ -84,5 +93,5 @@ func (s *dockerPushHook) doDockerImagePush(proj *brigade.Project, commitish stri
 	if proj.DefaultScript != "" {
 		b.Script = []byte(proj.DefaultScript)
 	}
-	return s.store.CreateBuild(b)
+	return createBuild(ctx, s.store, b)
 }
@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+	"github.com/brigadecore/brigade/pkg/tracing"
+)
+
+// PriorityHeader is the header of a webhook request that sets the name of the
+// PriorityClass of the builds it creates. The priority query parameter does
+// the same. Builds of requests without either, or with a priority that their
+// project does not allow, use the default priority of their project.
+const PriorityHeader = "X-Brigade-Priority"
+
+// priorityKey is the context key of the priority of a webhook request.
+type priorityKey struct{}
+
+// receive counts a webhook event from a provider, and starts the span of its
+// request, which continues the trace of the request if it has one. The
+// returned context holds the priority of the request.
+func receive(c *gin.Context, provider string) (context.Context, trace.Span) {
+	eventsReceived.WithLabelValues(provider).Inc()
+	ctx := tracing.FromRequest(c.Request)
+	if priority := requestPriority(c); priority != "" {
+		ctx = context.WithValue(ctx, priorityKey{}, priority)
+	}
+	return tracing.Tracer().Start(ctx, "webhook "+provider,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(label.String("brigade.provider", provider)),
+	)
+}
+
+// requestPriority returns the priority set by the header or query parameter
+// of a webhook request.
+func requestPriority(c *gin.Context) string {
+	if p := c.Request.Header.Get(PriorityHeader); p != "" {
+		return p
+	}
+	return c.Query("priority")
+}
+
+// trustedFork returns true if the builds of a pull or merge request from a
+// fork may run with the secrets of a project. Repositories are given by their
+// full name, such as group/repo.
+//
+// GitLab and Bitbucket events do not tell how the author of a request is
+// associated with the repository, so only forks in the namespace of the
+// repository, where only its members may create repositories, are trusted
+// unless the project builds untrusted authors.
+func trustedFork(proj *brigade.Project, source, target string) bool {
+	ns := repoNamespace(source)
+	return proj.Github.BuildUntrusted || (ns != "" && ns == repoNamespace(target))
+}
+
+// repoNamespace returns the namespace of a repository given by its full name,
+// or an empty string if it has none.
+func repoNamespace(name string) string {
+	if i := strings.LastIndex(name, "/"); i > 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// reject counts a webhook event rejected for a reason, and marks the span of
+// its request as failed.
+func reject(span trace.Span, provider, reason string) {
+	eventsRejected.WithLabelValues(provider, reason).Inc()
+	span.SetStatus(codes.Error, reason)
+}
+
+// createBuild creates a build in the store, and counts it if it was created.
+// The build continues the trace of ctx, and has the priority of its request
+// unless it sets its own.
+func createBuild(ctx context.Context, s storage.Store, b *brigade.Build) error {
+	if p, ok := ctx.Value(priorityKey{}).(string); ok && b.Priority == "" {
+		b.Priority = p
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "create build", trace.WithAttributes(
+		label.String("brigade.project", b.ProjectID),
+		label.String("brigade.event_type", b.Type),
+		label.String("brigade.provider", b.Provider),
+	))
+	defer span.End()
+	b.Traceparent = tracing.Traceparent(ctx)
+	if err := s.CreateBuild(b); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	span.SetAttributes(label.String("brigade.build", b.ID))
+	buildsCreated.WithLabelValues(b.Provider, b.ProjectID).Inc()
+	return nil
+}
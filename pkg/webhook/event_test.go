@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/tracing"
+)
+
+func TestCreateBuild_Traceparent(t *testing.T) {
+	ctx, span := tracing.Tracer().Start(context.Background(), "test")
+	defer span.End()
+
+	store := newTestStore()
+	if err := createBuild(ctx, store, &brigade.Build{ProjectID: "brigade-1234", Provider: "dockerhub"}); err != nil {
+		t.Fatal(err)
+	}
+	tp := store.builds[0].Traceparent
+	if !strings.HasPrefix(tp, "00-"+span.SpanContext().TraceID.String()+"-") {
+		t.Errorf("expected the build to continue the trace of the event, got %q", tp)
+	}
+}
+
+func TestCreateBuild_Priority(t *testing.T) {
+	for _, tt := range []struct {
+		name, target, header, build, expect string
+	}{
+		{"none", "/", "", "", ""},
+		{"header", "/", "urgent", "", "urgent"},
+		{"query", "/?priority=urgent", "", "", "urgent"},
+		{"header over query", "/?priority=low", "urgent", "", "urgent"},
+		{"build over request", "/?priority=low", "", "urgent", "urgent"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", tt.target, nil)
+			if tt.header != "" {
+				c.Request.Header.Set(PriorityHeader, tt.header)
+			}
+			ctx, span := receive(c, "test")
+			defer span.End()
+
+			store := newTestStore()
+			if err := createBuild(ctx, store, &brigade.Build{ProjectID: "brigade-1234", Priority: tt.build}); err != nil {
+				t.Fatal(err)
+			}
+			if got := store.builds[0].Priority; got != tt.expect {
+				t.Errorf("expected priority %q, got %q", tt.expect, got)
+			}
+		})
+	}
+}
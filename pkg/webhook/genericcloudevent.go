@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"log"
@@ -26,6 +27,8 @@ func NewGenericWebhookCloudEvent(s storage.Store) gin.HandlerFunc {
 
 // Handle handles a generic Gateway CloudEvent.
 func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
+	ctx, span := receive(c, "GenericWebhook")
+	defer span.End()
 	projectID := c.Param("projectID")
 	secret := c.Param("secret")
 
@@ -33,12 +36,14 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 
 	if err != nil {
 		log.Printf("Project %q not found. No secret loaded. %s", projectID, err)
+		reject(span, "GenericWebhook", reasonProjectNotFound)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
 		return
 	}
 
 	err = validateGenericGatewaySecret(proj, secret)
 	if err != nil {
+		reject(span, "GenericWebhook", reasonUnauthorized)
 		c.JSON(http.StatusUnauthorized, gin.H{"status": err.Error()})
 		return
 	}
@@ -46,6 +51,7 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 	payload, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %s", err)
+		reject(span, "GenericWebhook", reasonMalformedBody)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
 		return
 	}
@@ -56,6 +62,7 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 	err = json.Unmarshal(payload, &event)
 	if err != nil {
 		log.Printf("Failed to convert POST data into JSON: %s", err)
+		reject(span, "GenericWebhook", reasonMalformedBody)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed POST data - Invalid JSON"})
 		return
 	}
@@ -63,27 +70,29 @@ func (g *genericWebhookCloudEvent) Handle(c *gin.Context) {
 	// CloudEvents required fields are type, specversion, source, id
 	// as per https://github.com/cloudevents/spec/blob/v0.2/spec.md
 	if event.ID == "" || event.Type == "" || event.SpecVersion == "" || event.Source.String() == "" {
+		reject(span, "GenericWebhook", reasonInvalidEvent)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "CloudEvent should have non empty type, specversion, source, id"})
 		return
 	}
 
 	// only support 0.2 of the CloudEvent spec for now
 	if event.SpecVersion != "0.2" {
+		reject(span, "GenericWebhook", reasonInvalidEvent)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "Brigade supports only '0.2' as CloudEvent specversion"})
 		return
 	}
 
-	go g.notifyGenericWebhookCloudEvent(proj, payload, event)
+	go g.notifyGenericWebhookCloudEvent(ctx, proj, payload, event)
 	c.JSON(200, gin.H{"status": "Success"})
 }
 
-func (g *genericWebhookCloudEvent) notifyGenericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event) {
-	if err := g.genericWebhookCloudEvent(proj, payload, event); err != nil {
+func (g *genericWebhookCloudEvent) notifyGenericWebhookCloudEvent(ctx context.Context, proj *brigade.Project, payload []byte, event *cloudevents.Event) {
+	if err := g.genericWebhookCloudEvent(ctx, proj, payload, event); err != nil {
 		log.Printf("failed genericWebhook Cloud Event: %s", err)
 	}
 }
 
-func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(proj *brigade.Project, payload []byte, event *cloudevents.Event) error {
+func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(ctx context.Context, proj *brigade.Project, payload []byte, event *cloudevents.Event) error {
 	var revision brigade.Revision
 	if event.Data != nil {
 		data := event.Data.(map[string]interface{})
@@ -111,5 +120,5 @@ func (g *genericWebhookCloudEvent) genericWebhookCloudEvent(proj *brigade.Projec
 		Revision:  &revision,
 	}
 
-	return g.store.CreateBuild(b)
+	return createBuild(ctx, g.store, b)
 }
@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -31,7 +32,7 @@ func TestGenericWebhookCloudEventHandler(t *testing.T) {
 		ID:     "ea35b24ede421",
 	}
 
-	if err := h.genericWebhookCloudEvent(proj, []byte(exampleCloudEvent), event); err != nil {
+	if err := h.genericWebhookCloudEvent(context.Background(), proj, []byte(exampleCloudEvent), event); err != nil {
 		t.Errorf("failed generic gateway cloud event: %s", err)
 	}
 
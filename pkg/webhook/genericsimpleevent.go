@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -25,6 +26,8 @@ func NewGenericWebhookSimpleEvent(s storage.Store) gin.HandlerFunc {
 
 // Handle handles a generic Gateway event.
 func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
+	ctx, span := receive(c, "GenericWebhook")
+	defer span.End()
 	projectID := c.Param("projectID")
 	secret := c.Param("secret")
 
@@ -32,12 +35,14 @@ func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
 
 	if err != nil {
 		log.Printf("Project %q not found. No secret loaded. %s", projectID, err)
+		reject(span, "GenericWebhook", reasonProjectNotFound)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
 		return
 	}
 
 	err = validateGenericGatewaySecret(proj, secret)
 	if err != nil {
+		reject(span, "GenericWebhook", reasonUnauthorized)
 		c.JSON(http.StatusUnauthorized, gin.H{"status": err.Error()})
 		return
 	}
@@ -45,6 +50,7 @@ func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
 	payload, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
 		log.Printf("Failed to read body: %s", err)
+		reject(span, "GenericWebhook", reasonMalformedBody)
 		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
 		return
 	}
@@ -57,22 +63,23 @@ func (g *genericWebhookSimpleEvent) Handle(c *gin.Context) {
 		err = json.Unmarshal(payload, &revision)
 		if err != nil {
 			log.Printf("Failed to convert POST data into JSON: %s", err)
+			reject(span, "GenericWebhook", reasonMalformedBody)
 			c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed POST data - Invalid JSON"})
 			return
 		}
 	}
 
-	go g.notifyGenericWebhookSimpleEvent(proj, payload, revision)
+	go g.notifyGenericWebhookSimpleEvent(ctx, proj, payload, revision)
 	c.JSON(200, gin.H{"status": "Success. Build created"})
 }
 
-func (g *genericWebhookSimpleEvent) notifyGenericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision) {
-	if err := g.genericWebhookSimpleEvent(proj, payload, revision); err != nil {
+func (g *genericWebhookSimpleEvent) notifyGenericWebhookSimpleEvent(ctx context.Context, proj *brigade.Project, payload []byte, revision *brigade.Revision) {
+	if err := g.genericWebhookSimpleEvent(ctx, proj, payload, revision); err != nil {
 		log.Printf("failed genericWebhook SimpleEvent: %s", err)
 	}
 }
 
-func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(proj *brigade.Project, payload []byte, revision *brigade.Revision) error {
+func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(ctx context.Context, proj *brigade.Project, payload []byte, revision *brigade.Revision) error {
 	b := &brigade.Build{
 		ProjectID: proj.ID,
 		Type:      "simpleevent",
@@ -88,7 +95,7 @@ func (g *genericWebhookSimpleEvent) genericWebhookSimpleEvent(proj *brigade.Proj
 		b.Revision = &brigade.Revision{Ref: "master"}
 	}
 
-	return g.store.CreateBuild(b)
+	return createBuild(ctx, g.store, b)
 }
 
 // validateGenericGatewaySecret will return an error if given Project does not have a GenericGatewaySecret or if the provided secret is wrong
@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -50,7 +51,7 @@ func TestGenericWebhookSimpleEventHandler(t *testing.T) {
 		Commit: "63c09efb6eb544f41a48901a6d0cc6ddfa4adb28",
 	}
 
-	if err := h.genericWebhookSimpleEvent(proj, []byte(exampleSimpleEvent), revision); err != nil {
+	if err := h.genericWebhookSimpleEvent(context.Background(), proj, []byte(exampleSimpleEvent), revision); err != nil {
 		t.Errorf("failed generic gateway event: %s", err)
 	}
 
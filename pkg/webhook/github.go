@@ -0,0 +1,312 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v31/github"
+	"golang.org/x/oauth2"
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// githubAPITimeout is how long requests to the GitHub API may take.
+const githubAPITimeout = 10 * time.Second
+
+// githubEvents are the GitHub events that builds are created for. Pushes of
+// tags are push events too.
+var githubEvents = map[string]bool{
+	"push":          true,
+	"pull_request":  true,
+	"release":       true,
+	"issue_comment": true,
+}
+
+// githubActions are the actions of the GitHub events that builds are created
+// for, for the events that have actions.
+var githubActions = map[string]map[string]bool{
+	"pull_request":  {"opened": true, "synchronize": true, "reopened": true},
+	"release":       {"published": true},
+	"issue_comment": {"created": true},
+}
+
+// trustedAuthors are the author associations of the authors whose pull
+// requests from forks and comments are built.
+var trustedAuthors = map[string]bool{
+	"OWNER":        true,
+	"MEMBER":       true,
+	"COLLABORATOR": true,
+}
+
+type githubHook struct {
+	store storage.Store
+}
+
+// NewGithubHook creates a GitHub webhook handler.
+//
+// Deliveries are authenticated with the X-Hub-Signature-256 header, which is
+// an HMAC of the body keyed with the shared secret of the project of the
+// repository. Deliveries signed only with SHA1 are rejected.
+func NewGithubHook(s storage.Store) gin.HandlerFunc {
+	h := &githubHook{store: s}
+	return h.Handle
+}
+
+// Handle handles a GitHub webhook event.
+func (s *githubHook) Handle(c *gin.Context) {
+	ctx, span := receive(c, "github")
+	defer span.End()
+
+	event := c.Request.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+		return
+	}
+	if !githubEvents[event] {
+		log.Printf("Ignoring GitHub event %q", event)
+		c.JSON(http.StatusOK, gin.H{"status": "event ignored"})
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("Failed to read body: %s", err)
+		reject(span, "github", reasonMalformedBody)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	defer c.Request.Body.Close()
+
+	payload, err := gh.ParseWebHook(event, body)
+	if err != nil {
+		log.Printf("Failed to parse %s event: %s", event, err)
+		reject(span, "github", reasonMalformedBody)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+
+	repo := githubRepo(payload)
+	proj, err := s.store.GetProject(brigade.ProjectID(repo.GetFullName()))
+	if err != nil {
+		log.Printf("Project %q not found. No secret loaded. %s", repo.GetFullName(), err)
+		reject(span, "github", reasonProjectNotFound)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
+		return
+	}
+
+	if err := validateGithubSignature(c.Request.Header, proj.SharedSecret, body); err != nil {
+		log.Printf("Rejecting %s event of %s: %s", event, repo.GetFullName(), err)
+		reject(span, "github", reasonUnauthorized)
+		c.JSON(http.StatusForbidden, gin.H{"status": "signature does not match"})
+		return
+	}
+
+	go s.notifyGithubEvent(ctx, proj, event, payload, body)
+	c.JSON(http.StatusOK, gin.H{"status": "Success"})
+}
+
+func (s *githubHook) notifyGithubEvent(ctx context.Context, proj *brigade.Project, event string, payload interface{}, body []byte) {
+	b, err := s.githubBuild(proj, event, payload)
+	if err != nil {
+		log.Printf("failed %s event: %s", event, err)
+		return
+	}
+	if b == nil {
+		return
+	}
+	b.Payload = body
+	if err := createBuild(ctx, s.store, b); err != nil {
+		log.Printf("failed %s event: %s", event, err)
+	}
+}
+
+// githubBuild returns the build of a GitHub event, or nil if the event does
+// not start a build.
+//
+// Builds of pull requests from forks clone the fork, at the head branch of the
+// pull request. Other builds clone the repository of the project.
+//
+// Pull requests from forks and comments are only built if their author is
+// trusted, as their builds run with the secrets of the project.
+func (s *githubHook) githubBuild(proj *brigade.Project, event string, payload interface{}) (*brigade.Build, error) {
+	if actions, ok := githubActions[event]; ok && !actions[githubAction(payload)] {
+		log.Printf("Skipping build of %s event with action %q", event, githubAction(payload))
+		return nil, nil
+	}
+
+	b := &brigade.Build{
+		ProjectID: proj.ID,
+		Type:      event,
+		Provider:  "github",
+		Revision:  &brigade.Revision{},
+	}
+	if proj.DefaultScript != "" {
+		b.Script = []byte(proj.DefaultScript)
+	}
+
+	switch e := payload.(type) {
+	case *gh.PushEvent:
+		if e.GetDeleted() {
+			log.Printf("Skipping build of deleted ref %s of %s", e.GetRef(), e.GetRepo().GetFullName())
+			return nil, nil
+		}
+		if strings.HasPrefix(e.GetRef(), "refs/tags/") {
+			b.Type = "tag"
+		}
+		b.Revision.Ref = e.GetRef()
+		b.Revision.Commit = e.GetAfter()
+		b.ShortTitle, b.LongTitle = commitTitles(e.GetHeadCommit().GetMessage())
+	case *gh.PullRequestEvent:
+		pr := e.GetPullRequest()
+		setPullRequest(b, pr)
+		if b.CloneURL != "" && !trusted(proj, pr.GetAuthorAssociation()) {
+			log.Printf("Skipping build of pull request %d of %s from fork %s by %s author", pr.GetNumber(), e.GetRepo().GetFullName(), pr.GetHead().GetRepo().GetFullName(), pr.GetAuthorAssociation())
+			return nil, nil
+		}
+	case *gh.ReleaseEvent:
+		b.Revision.Ref = "refs/tags/" + e.GetRelease().GetTagName()
+		b.ShortTitle = e.GetRelease().GetName()
+		if b.ShortTitle == "" {
+			b.ShortTitle = e.GetRelease().GetTagName()
+		}
+		b.LongTitle = e.GetRelease().GetBody()
+	case *gh.IssueCommentEvent:
+		if comment := e.GetComment(); !trusted(proj, comment.GetAuthorAssociation()) {
+			log.Printf("Skipping build of comment %d on %s by %s author", comment.GetID(), e.GetRepo().GetFullName(), comment.GetAuthorAssociation())
+			return nil, nil
+		}
+		issue := e.GetIssue()
+		b.ShortTitle = issue.GetTitle()
+		b.LongTitle = e.GetComment().GetBody()
+		if !issue.IsPullRequest() {
+			b.Revision.Ref = "refs/heads/" + e.GetRepo().GetDefaultBranch()
+			break
+		}
+		// Comments do not say which commit their pull request is at, or where
+		// it comes from, so the pull request is fetched from the API.
+		pr, err := s.getPullRequest(proj, e.GetRepo(), issue.GetNumber())
+		if err != nil {
+			// The head of a pull request can still be checked out from the
+			// repository of the project.
+			log.Printf("Failed to get pull request %d of %s, building its head ref: %s", issue.GetNumber(), e.GetRepo().GetFullName(), err)
+			b.Revision.Ref = fmt.Sprintf("refs/pull/%d/head", issue.GetNumber())
+			break
+		}
+		setPullRequest(b, pr)
+		b.LongTitle = e.GetComment().GetBody()
+	default:
+		return nil, fmt.Errorf("unsupported event %s", event)
+	}
+	return b, nil
+}
+
+// setPullRequest sets the revision, clone URL and titles of the build of a
+// pull request.
+func setPullRequest(b *brigade.Build, pr *gh.PullRequest) {
+	b.Revision.Commit = pr.GetHead().GetSHA()
+	b.Revision.Ref = fmt.Sprintf("refs/pull/%d/head", pr.GetNumber())
+	if head := pr.GetHead().GetRepo(); head.GetFullName() != "" && head.GetFullName() != pr.GetBase().GetRepo().GetFullName() {
+		b.CloneURL = head.GetCloneURL()
+		b.Revision.Ref = "refs/heads/" + pr.GetHead().GetRef()
+	}
+	b.ShortTitle = pr.GetTitle()
+	b.LongTitle = pr.GetBody()
+}
+
+// getPullRequest fetches a pull request of a repository from the GitHub API.
+func (s *githubHook) getPullRequest(proj *brigade.Project, repo *gh.Repository, number int) (*gh.PullRequest, error) {
+	// The context of the request of the event is done once it is answered, so
+	// the pull request is fetched with a context of its own.
+	ctx, cancel := context.WithTimeout(context.Background(), githubAPITimeout)
+	defer cancel()
+	client, err := githubClient(ctx, proj)
+	if err != nil {
+		return nil, err
+	}
+	pr, _, err := client.PullRequests.Get(ctx, repo.GetOwner().GetLogin(), repo.GetName(), number)
+	return pr, err
+}
+
+// githubClient creates a client of the GitHub API for a project, with the
+// token of the project if it has one. Projects with a base URL use GitHub
+// Enterprise.
+func githubClient(ctx context.Context, proj *brigade.Project) (*gh.Client, error) {
+	var httpClient *http.Client
+	if proj.Github.Token != "" {
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: proj.Github.Token}))
+	}
+	if proj.Github.BaseURL == "" {
+		return gh.NewClient(httpClient), nil
+	}
+	uploadURL := proj.Github.UploadURL
+	if uploadURL == "" {
+		uploadURL = proj.Github.BaseURL
+	}
+	return gh.NewEnterpriseClient(proj.Github.BaseURL, uploadURL, httpClient)
+}
+
+// githubAction returns the action of a GitHub event, or an empty string if
+// the event has none.
+func githubAction(payload interface{}) string {
+	switch e := payload.(type) {
+	case *gh.PullRequestEvent:
+		return e.GetAction()
+	case *gh.ReleaseEvent:
+		return e.GetAction()
+	case *gh.IssueCommentEvent:
+		return e.GetAction()
+	}
+	return ""
+}
+
+// trusted returns true if the builds of an author with an author association
+// may run with the secrets of a project.
+func trusted(proj *brigade.Project, association string) bool {
+	return proj.Github.BuildUntrusted || trustedAuthors[association]
+}
+
+// githubRepo returns the repository of a GitHub event.
+func githubRepo(payload interface{}) *gh.Repository {
+	switch e := payload.(type) {
+	case *gh.PushEvent:
+		// Push events have their own repository type.
+		r := e.GetRepo()
+		return &gh.Repository{FullName: r.FullName, Name: r.Name}
+	case *gh.PullRequestEvent:
+		return e.GetRepo()
+	case *gh.ReleaseEvent:
+		return e.GetRepo()
+	case *gh.IssueCommentEvent:
+		return e.GetRepo()
+	}
+	return &gh.Repository{}
+}
+
+// commitTitles returns the first line of a commit message, and the message.
+func commitTitles(message string) (string, string) {
+	return strings.SplitN(message, "\n", 2)[0], message
+}
+
+// validateGithubSignature returns an error if the SHA256 signature of a GitHub
+// delivery is missing or does not match its body.
+func validateGithubSignature(header http.Header, secret string, body []byte) error {
+	if secret == "" {
+		return errors.New("project has no shared secret")
+	}
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		if !hmac.Equal([]byte(signature), []byte(SHA256HMAC([]byte(secret), body))) {
+			return errors.New("X-Hub-Signature-256 does not match")
+		}
+		return nil
+	}
+	return errors.New("no X-Hub-Signature-256 header")
+}
@@ -0,0 +1,403 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gh "github.com/google/go-github/v31/github"
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func readGithubPayload(t *testing.T, event, file string) ([]byte, interface{}) {
+	t.Helper()
+	body, err := ioutil.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := gh.ParseWebHook(event, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body, payload
+}
+
+func TestGithubHandler(t *testing.T) {
+	push, _ := readGithubPayload(t, "push", "github-push-payload.json")
+	tests := []struct {
+		name     string
+		event    string
+		body     []byte
+		header   map[string]string
+		store    func(*testStore)
+		expected int
+	}{
+		{
+			name:     "sha256 signature",
+			event:    "push",
+			body:     push,
+			header:   map[string]string{"X-Hub-Signature-256": SHA256HMAC([]byte("asdf"), push)},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "sha1 signature",
+			event:    "push",
+			body:     push,
+			header:   map[string]string{"X-Hub-Signature": SHA1HMAC([]byte("asdf"), push)},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:  "wrong sha256 signature with sha1 signature",
+			event: "push",
+			body:  push,
+			header: map[string]string{
+				"X-Hub-Signature-256": SHA256HMAC([]byte("nope"), push),
+				"X-Hub-Signature":     SHA1HMAC([]byte("asdf"), push),
+			},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "wrong secret",
+			event:    "push",
+			body:     push,
+			header:   map[string]string{"X-Hub-Signature-256": SHA256HMAC([]byte("nope"), push)},
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "no signature",
+			event:    "push",
+			body:     push,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "project without shared secret",
+			event:    "push",
+			body:     push,
+			header:   map[string]string{"X-Hub-Signature-256": SHA256HMAC([]byte(""), push)},
+			store:    func(s *testStore) { s.proj.SharedSecret = "" },
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "project not found",
+			event:    "push",
+			body:     push,
+			header:   map[string]string{"X-Hub-Signature-256": SHA256HMAC([]byte("asdf"), push)},
+			store:    func(s *testStore) { s.err = errors.New("not found") },
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "malformed body",
+			event:    "push",
+			body:     []byte("{"),
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "ping",
+			event:    "ping",
+			body:     []byte(`{"zen": "Keep it logically awesome."}`),
+			expected: http.StatusOK,
+		},
+		{
+			name:     "unsupported event",
+			event:    "deployment",
+			body:     []byte(`{}`),
+			expected: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore()
+			if tt.store != nil {
+				tt.store(store)
+			}
+			router := gin.New()
+			router.POST("/events/github", NewGithubHook(store))
+
+			req, err := http.NewRequest("POST", "/events/github", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-GitHub-Event", tt.event)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			if rw.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestGithubBuild_Push(t *testing.T) {
+	proj := newProject()
+	proj.DefaultScript = `console.log("hello default script")`
+	_, payload := readGithubPayload(t, "push", "github-push-payload.json")
+
+	b, err := (&githubHook{}).githubBuild(proj, "push", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ProjectID != proj.ID || b.Provider != "github" || b.Type != "push" {
+		t.Errorf("unexpected build %+v", b)
+	}
+	if b.Revision.Ref != "refs/heads/changes" || b.Revision.Commit != "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "Update README.md" {
+		t.Errorf("unexpected short title %q", b.ShortTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+	if string(b.Script) != proj.DefaultScript {
+		t.Errorf("unexpected build script: %s", b.Script)
+	}
+}
+
+func TestGithubBuild_Tag(t *testing.T) {
+	_, payload := readGithubPayload(t, "push", "github-push-payload.json")
+	payload.(*gh.PushEvent).Ref = gh.String("refs/tags/v0.1.0")
+
+	b, err := (&githubHook{}).githubBuild(newProject(), "push", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "tag" || b.Revision.Ref != "refs/tags/v0.1.0" {
+		t.Errorf("expected tag build of refs/tags/v0.1.0, got %s build of %s", b.Type, b.Revision.Ref)
+	}
+}
+
+func TestGithubBuild_DeletedBranch(t *testing.T) {
+	_, payload := readGithubPayload(t, "push", "github-push-delete-branch.json")
+	b, err := (&githubHook{}).githubBuild(newProject(), "push", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != nil {
+		t.Errorf("expected no build for a deleted branch, got %+v", b)
+	}
+}
+
+func TestGithubBuild_PullRequest(t *testing.T) {
+	_, payload := readGithubPayload(t, "pull_request", "github-pull_request-payload.json")
+	b, err := (&githubHook{}).githubBuild(newProject(), "pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "pull_request" {
+		t.Errorf("unexpected type %s", b.Type)
+	}
+	if b.Revision.Ref != "refs/pull/1/head" || b.Revision.Commit != "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "Update the README with new information" {
+		t.Errorf("unexpected short title %q", b.ShortTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+}
+
+func TestGithubBuild_PullRequestFromFork(t *testing.T) {
+	_, payload := readGithubPayload(t, "pull_request", "github-pull_request-payload.json")
+	head := payload.(*gh.PullRequestEvent).PullRequest.Head
+	head.Repo.FullName = gh.String("forker/public-repo")
+	head.Repo.CloneURL = gh.String("https://github.com/forker/public-repo.git")
+
+	b, err := (&githubHook{}).githubBuild(newProject(), "pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.CloneURL != "https://github.com/forker/public-repo.git" {
+		t.Errorf("expected the clone URL of the fork, got %q", b.CloneURL)
+	}
+	if b.Revision.Ref != "refs/heads/changes" || b.Revision.Commit != "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+}
+
+func TestGithubBuild_UntrustedPullRequestFromFork(t *testing.T) {
+	_, payload := readGithubPayload(t, "pull_request", "github-pull_request-payload.json")
+	pr := payload.(*gh.PullRequestEvent).PullRequest
+	pr.Head.Repo.FullName = gh.String("forker/public-repo")
+	pr.Head.Repo.CloneURL = gh.String("https://github.com/forker/public-repo.git")
+	pr.AuthorAssociation = gh.String("CONTRIBUTOR")
+
+	proj := newProject()
+	b, err := (&githubHook{}).githubBuild(proj, "pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != nil {
+		t.Errorf("expected no build of a pull request from a fork by a contributor, got %+v", b)
+	}
+
+	proj.Github.BuildUntrusted = true
+	b, err = (&githubHook{}).githubBuild(proj, "pull_request", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || b.CloneURL != "https://github.com/forker/public-repo.git" {
+		t.Errorf("expected a build of the fork for a project that builds untrusted authors, got %+v", b)
+	}
+}
+
+func TestGithubBuild_Actions(t *testing.T) {
+	tests := []struct {
+		event  string
+		file   string
+		action string
+		build  bool
+	}{
+		{"pull_request", "github-pull_request-payload.json", "opened", true},
+		{"pull_request", "github-pull_request-payload.json", "synchronize", true},
+		{"pull_request", "github-pull_request-payload.json", "reopened", true},
+		{"pull_request", "github-pull_request-payload.json", "closed", false},
+		{"pull_request", "github-pull_request-payload.json", "labeled", false},
+		{"release", "github-release-payload.json", "published", true},
+		{"release", "github-release-payload.json", "created", false},
+		{"release", "github-release-payload.json", "deleted", false},
+		{"issue_comment", "github-issue_comment-payload.json", "created", true},
+		{"issue_comment", "github-issue_comment-payload.json", "edited", false},
+		{"issue_comment", "github-issue_comment-payload.json", "deleted", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event+" "+tt.action, func(t *testing.T) {
+			_, payload := readGithubPayload(t, tt.event, tt.file)
+			switch e := payload.(type) {
+			case *gh.PullRequestEvent:
+				e.Action = gh.String(tt.action)
+			case *gh.ReleaseEvent:
+				e.Action = gh.String(tt.action)
+			case *gh.IssueCommentEvent:
+				e.Action = gh.String(tt.action)
+				e.Issue.PullRequestLinks = nil
+			}
+			b, err := (&githubHook{}).githubBuild(newProject(), tt.event, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (b != nil) != tt.build {
+				t.Errorf("expected build %t, got %+v", tt.build, b)
+			}
+		})
+	}
+}
+
+func TestGithubBuild_Release(t *testing.T) {
+	_, payload := readGithubPayload(t, "release", "github-release-payload.json")
+	b, err := (&githubHook{}).githubBuild(newProject(), "release", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "release" || b.Revision.Ref != "refs/tags/0.0.1" {
+		t.Errorf("expected release build of refs/tags/0.0.1, got %s build of %s", b.Type, b.Revision.Ref)
+	}
+	if b.ShortTitle != "0.0.1" {
+		t.Errorf("expected the tag as short title of a release without name, got %q", b.ShortTitle)
+	}
+}
+
+func TestGithubBuild_IssueComment(t *testing.T) {
+	_, payload := readGithubPayload(t, "issue_comment", "github-issue_comment-payload.json")
+	payload.(*gh.IssueCommentEvent).Issue.PullRequestLinks = nil
+
+	b, err := (&githubHook{}).githubBuild(newProject(), "issue_comment", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "issue_comment" || b.Revision.Ref != "refs/heads/master" {
+		t.Errorf("expected issue_comment build of refs/heads/master, got %s build of %s", b.Type, b.Revision.Ref)
+	}
+	if b.ShortTitle != "Spelling error in the README file" || b.LongTitle != "/brigade test" {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+}
+
+func TestGithubBuild_UntrustedComment(t *testing.T) {
+	_, payload := readGithubPayload(t, "issue_comment", "github-issue_comment-payload.json")
+	e := payload.(*gh.IssueCommentEvent)
+	e.Issue.PullRequestLinks = nil
+	e.Comment.AuthorAssociation = gh.String("NONE")
+
+	proj := newProject()
+	b, err := (&githubHook{}).githubBuild(proj, "issue_comment", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != nil {
+		t.Errorf("expected no build of a comment by an author without association, got %+v", b)
+	}
+
+	proj.Github.BuildUntrusted = true
+	if b, _ := (&githubHook{}).githubBuild(proj, "issue_comment", payload); b == nil {
+		t.Error("expected a build of the comment for a project that builds untrusted authors")
+	}
+}
+
+func TestGithubBuild_PullRequestComment(t *testing.T) {
+	// The pull request is fetched from the GitHub Enterprise API of the project.
+	var auth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/baxterthehacker/public-repo/pulls/2" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(gh.PullRequest{
+			Number: gh.Int(2),
+			Title:  gh.String("Spelling error in the README file"),
+			Head: &gh.PullRequestBranch{
+				Ref: gh.String("fix-spelling"),
+				SHA: gh.String("b7a1f9c27caa4e03c14a88feb56e2d4f7500aa63"),
+				Repo: &gh.Repository{
+					FullName: gh.String("forker/public-repo"),
+					CloneURL: gh.String("https://ghe.example.com/forker/public-repo.git"),
+				},
+			},
+			Base: &gh.PullRequestBranch{
+				Repo: &gh.Repository{FullName: gh.String("baxterthehacker/public-repo")},
+			},
+		})
+	}))
+	defer api.Close()
+
+	proj := newProject()
+	proj.Github = brigade.Github{BaseURL: api.URL, Token: "half-a-league"}
+	_, payload := readGithubPayload(t, "issue_comment", "github-issue_comment-payload.json")
+
+	b, err := (&githubHook{}).githubBuild(proj, "issue_comment", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer half-a-league" {
+		t.Errorf("expected the API to be called with the token of the project, got %q", auth)
+	}
+	if b.Revision.Ref != "refs/heads/fix-spelling" || b.Revision.Commit != "b7a1f9c27caa4e03c14a88feb56e2d4f7500aa63" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.CloneURL != "https://ghe.example.com/forker/public-repo.git" {
+		t.Errorf("expected the clone URL of the fork, got %q", b.CloneURL)
+	}
+	if b.LongTitle != "/brigade test" {
+		t.Errorf("expected the comment as long title, got %q", b.LongTitle)
+	}
+
+	// Without the API, the head of the pull request is built.
+	api.Close()
+	b, err = (&githubHook{}).githubBuild(proj, "issue_comment", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Revision.Ref != "refs/pull/2/head" || b.CloneURL != "" {
+		t.Errorf("expected build of refs/pull/2/head of the project, got %+v and %q", b.Revision, b.CloneURL)
+	}
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+	"github.com/brigadecore/brigade/pkg/storage"
+)
+
+// zeroCommit is the commit that GitLab pushes of deleted refs are at.
+const zeroCommit = "0000000000000000000000000000000000000000"
+
+type gitlabHook struct {
+	store storage.Store
+}
+
+// gitlabProject is a project of a GitLab event.
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	GitHTTPURL        string `json:"git_http_url"`
+}
+
+// gitlabCommit is a commit of a GitLab event.
+type gitlabCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+// gitlabPushEvent is a GitLab push or tag push event.
+type gitlabPushEvent struct {
+	Ref         string         `json:"ref"`
+	After       string         `json:"after"`
+	CheckoutSHA string         `json:"checkout_sha"`
+	Message     string         `json:"message"`
+	Commits     []gitlabCommit `json:"commits"`
+	Project     gitlabProject  `json:"project"`
+}
+
+// gitlabMergeRequestEvent is a GitLab merge request event.
+type gitlabMergeRequestEvent struct {
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID             int           `json:"iid"`
+		Title           string        `json:"title"`
+		Description     string        `json:"description"`
+		Action          string        `json:"action"`
+		OldRev          string        `json:"oldrev"`
+		SourceBranch    string        `json:"source_branch"`
+		SourceProjectID int           `json:"source_project_id"`
+		TargetProjectID int           `json:"target_project_id"`
+		Source          gitlabProject `json:"source"`
+		Target          gitlabProject `json:"target"`
+		LastCommit      gitlabCommit  `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// NewGitlabHook creates a GitLab webhook handler.
+//
+// Like the Docker Hub hook, the project of an event is named by the route of
+// the handler. Events are authenticated with the X-Gitlab-Token header, which
+// has to be the shared secret of the project.
+func NewGitlabHook(s storage.Store) gin.HandlerFunc {
+	h := &gitlabHook{store: s}
+	return h.Handle
+}
+
+// Handle handles a push, tag push or merge request event from GitLab.
+func (s *gitlabHook) Handle(c *gin.Context) {
+	ctx, span := receive(c, "gitlab")
+	defer span.End()
+	pname := projectName(c)
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		log.Printf("Failed to read body: %s", err)
+		reject(span, "gitlab", reasonMalformedBody)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	defer c.Request.Body.Close()
+
+	proj, err := s.store.GetProject(pname)
+	if err != nil {
+		log.Printf("Project %q not found. No secret loaded. %s", pname, err)
+		reject(span, "gitlab", reasonProjectNotFound)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "project not found"})
+		return
+	}
+
+	if err := validateGitlabToken(c.Request.Header.Get("X-Gitlab-Token"), proj.SharedSecret); err != nil {
+		log.Printf("Rejecting GitLab event of %s: %s", pname, err)
+		reject(span, "gitlab", reasonUnauthorized)
+		c.JSON(http.StatusForbidden, gin.H{"status": "token does not match"})
+		return
+	}
+
+	event := c.Request.Header.Get("X-Gitlab-Event")
+	b, err := gitlabBuild(proj, event, body)
+	if err != nil {
+		log.Printf("Failed to parse GitLab %s: %s", event, err)
+		reject(span, "gitlab", reasonInvalidEvent)
+		c.JSON(http.StatusBadRequest, gin.H{"status": "Malformed body"})
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "event ignored"})
+		return
+	}
+
+	go s.notifyGitlabEvent(ctx, b)
+	c.JSON(http.StatusOK, gin.H{"status": "Success"})
+}
+
+func (s *gitlabHook) notifyGitlabEvent(ctx context.Context, b *brigade.Build) {
+	if err := createBuild(ctx, s.store, b); err != nil {
+		log.Printf("failed GitLab %s event: %s", b.Type, err)
+	}
+}
+
+// gitlabBuild returns the build of a GitLab event, or nil if the event does not
+// start a build.
+//
+// Builds of merge requests from forks clone the fork, at the source branch of
+// the merge request. Other builds clone the repository of the project. Merge
+// requests are built when they are opened or reopened, and when commits are
+// pushed to them. Merge requests from forks that are not trusted, see
+// trustedFork, are not built.
+func gitlabBuild(proj *brigade.Project, event string, body []byte) (*brigade.Build, error) {
+	b := &brigade.Build{
+		ProjectID: proj.ID,
+		Provider:  "gitlab",
+		Revision:  &brigade.Revision{},
+		Payload:   body,
+	}
+	if proj.DefaultScript != "" {
+		b.Script = []byte(proj.DefaultScript)
+	}
+
+	switch event {
+	case "Push Hook", "Tag Push Hook":
+		e := gitlabPushEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		if e.CheckoutSHA == "" || e.After == zeroCommit {
+			log.Printf("Skipping build of deleted ref %s of %s", e.Ref, e.Project.PathWithNamespace)
+			return nil, nil
+		}
+		b.Revision.Ref = e.Ref
+		b.Revision.Commit = e.CheckoutSHA
+		if event == "Tag Push Hook" {
+			b.Type = "tag"
+			b.ShortTitle = strings.TrimPrefix(e.Ref, "refs/tags/")
+			b.LongTitle = e.Message
+			break
+		}
+		b.Type = "push"
+		for _, commit := range e.Commits {
+			if commit.ID == e.CheckoutSHA {
+				b.ShortTitle, b.LongTitle = commitTitles(commit.Message)
+			}
+		}
+	case "Merge Request Hook":
+		e := gitlabMergeRequestEvent{}
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, err
+		}
+		mr := e.ObjectAttributes
+		if mr.IID == 0 {
+			return nil, errors.New("merge request has no iid")
+		}
+		// Only updates that push commits have the previous head of the merge
+		// request, unlike updates of its title or labels.
+		if !(mr.Action == "open" || mr.Action == "reopen" || (mr.Action == "update" && mr.OldRev != "")) {
+			log.Printf("Skipping build of %s action of merge request %d of %s", mr.Action, mr.IID, e.Project.PathWithNamespace)
+			return nil, nil
+		}
+		b.Type = "merge_request"
+		b.Revision.Commit = mr.LastCommit.ID
+		b.Revision.Ref = fmt.Sprintf("refs/merge-requests/%d/head", mr.IID)
+		if mr.SourceProjectID != mr.TargetProjectID {
+			if !trustedFork(proj, mr.Source.PathWithNamespace, mr.Target.PathWithNamespace) {
+				log.Printf("Skipping build of merge request %d of %s from untrusted fork %s", mr.IID, e.Project.PathWithNamespace, mr.Source.PathWithNamespace)
+				return nil, nil
+			}
+			b.CloneURL = mr.Source.GitHTTPURL
+			b.Revision.Ref = "refs/heads/" + mr.SourceBranch
+		}
+		b.ShortTitle = mr.Title
+		b.LongTitle = mr.Description
+	default:
+		log.Printf("Ignoring GitLab event %q", event)
+		return nil, nil
+	}
+	return b, nil
+}
+
+// validateGitlabToken returns an error if the token of a GitLab event is not the
+// shared secret of its project.
+func validateGitlabToken(token, secret string) error {
+	if secret == "" {
+		return errors.New("project has no shared secret")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return errors.New("token does not match")
+	}
+	return nil
+}
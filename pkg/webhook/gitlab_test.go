@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "gopkg.in/gin-gonic/gin.v1"
+)
+
+func readTestdata(t *testing.T, file string) []byte {
+	t.Helper()
+	body, err := ioutil.ReadFile("testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestGitlabHandler(t *testing.T) {
+	push := readTestdata(t, "gitlab-push-payload.json")
+	tests := []struct {
+		name     string
+		event    string
+		body     []byte
+		token    string
+		store    func(*testStore)
+		expected int
+	}{
+		{"valid token", "Push Hook", push, "asdf", nil, http.StatusOK},
+		{"wrong token", "Push Hook", push, "nope", nil, http.StatusForbidden},
+		{"no token", "Push Hook", push, "", nil, http.StatusForbidden},
+		{"project without shared secret", "Push Hook", push, "", func(s *testStore) { s.proj.SharedSecret = "" }, http.StatusForbidden},
+		{"project not found", "Push Hook", push, "asdf", func(s *testStore) { s.err = errors.New("not found") }, http.StatusBadRequest},
+		{"malformed body", "Push Hook", []byte("{"), "asdf", nil, http.StatusBadRequest},
+		{"unsupported event", "Note Hook", []byte("{}"), "asdf", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore()
+			if tt.store != nil {
+				tt.store(store)
+			}
+			router := gin.New()
+			router.POST("/events/gitlab/:org/:repo", NewGitlabHook(store))
+
+			req, err := http.NewRequest("POST", "/events/gitlab/mike/diaspora", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Gitlab-Event", tt.event)
+			if tt.token != "" {
+				req.Header.Set("X-Gitlab-Token", tt.token)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, req)
+			if rw.Code != tt.expected {
+				t.Errorf("expected status %d, got %d: %s", tt.expected, rw.Code, rw.Body.String())
+			}
+		})
+	}
+}
+
+func TestGitlabBuild_Push(t *testing.T) {
+	proj := newProject()
+	proj.DefaultScript = `console.log("hello default script")`
+	body := readTestdata(t, "gitlab-push-payload.json")
+
+	b, err := gitlabBuild(proj, "Push Hook", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ProjectID != proj.ID || b.Provider != "gitlab" || b.Type != "push" {
+		t.Errorf("unexpected build %+v", b)
+	}
+	if b.Revision.Ref != "refs/heads/master" || b.Revision.Commit != "da1560886d4f094c3e6c9ef40349f7d38b5d27d7" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "fixed readme" || b.LongTitle != "fixed readme\n\nThe readme had a typo." {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+	if string(b.Payload) != string(body) {
+		t.Error("expected the event as payload")
+	}
+	if string(b.Script) != proj.DefaultScript {
+		t.Errorf("unexpected build script: %s", b.Script)
+	}
+}
+
+func TestGitlabBuild_DeletedBranch(t *testing.T) {
+	body := bytes.Replace(readTestdata(t, "gitlab-push-payload.json"),
+		[]byte(`"after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"`),
+		[]byte(`"after": "0000000000000000000000000000000000000000"`), 1)
+	body = bytes.Replace(body, []byte(`"checkout_sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"`), []byte(`"checkout_sha": null`), 1)
+
+	b, err := gitlabBuild(newProject(), "Push Hook", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != nil {
+		t.Errorf("expected no build for a deleted branch, got %+v", b)
+	}
+}
+
+func TestGitlabBuild_TagPush(t *testing.T) {
+	b, err := gitlabBuild(newProject(), "Tag Push Hook", readTestdata(t, "gitlab-tag_push-payload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "tag" || b.Revision.Ref != "refs/tags/v1.0.0" || b.Revision.Commit != "82b3d5ae55f7080f1e6022629cdb57bfae7cccc7" {
+		t.Errorf("unexpected %s build of %+v", b.Type, b.Revision)
+	}
+	if b.ShortTitle != "v1.0.0" || b.LongTitle != "Tag message" {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+}
+
+func TestGitlabBuild_MergeRequest(t *testing.T) {
+	body := readTestdata(t, "gitlab-merge_request-payload.json")
+	b, err := gitlabBuild(newProject(), "Merge Request Hook", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Type != "merge_request" {
+		t.Errorf("unexpected type %s", b.Type)
+	}
+	if b.Revision.Ref != "refs/merge-requests/1/head" || b.Revision.Commit != "da1560886d4f094c3e6c9ef40349f7d38b5d27d7" {
+		t.Errorf("unexpected revision %+v", b.Revision)
+	}
+	if b.ShortTitle != "MS-Viewport" || b.LongTitle != "Makes the viewport fit on Windows phones." {
+		t.Errorf("unexpected titles %q and %q", b.ShortTitle, b.LongTitle)
+	}
+	if b.CloneURL != "" {
+		t.Errorf("expected the clone URL of the project, got %q", b.CloneURL)
+	}
+
+}
+
+func TestGitlabBuild_MergeRequestFromFork(t *testing.T) {
+	tests := []struct {
+		name           string
+		fork           string
+		buildUntrusted bool
+		build          bool
+	}{
+		{"untrusted fork", "forker/awesome_project", false, false},
+		{"untrusted fork of a project that builds untrusted authors", "forker/awesome_project", true, true},
+		{"fork in the namespace of the repository", "awesome_space/awesome_project_fork", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Merge requests from forks come from another project.
+			body := readTestdata(t, "gitlab-merge_request-payload.json")
+			body = bytes.Replace(body, []byte(`"source_project_id": 14`), []byte(`"source_project_id": 15`), 1)
+			body = bytes.Replace(body, []byte("awesome_space/awesome_project"), []byte(tt.fork), 4)
+			proj := newProject()
+			proj.Github.BuildUntrusted = tt.buildUntrusted
+			b, err := gitlabBuild(proj, "Merge Request Hook", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.build {
+				if b != nil {
+					t.Errorf("expected no build, got a build of %s", b.CloneURL)
+				}
+				return
+			}
+			if b == nil {
+				t.Fatal("expected a build")
+			}
+			if b.CloneURL != "http://example.com/"+tt.fork+".git" {
+				t.Errorf("expected the clone URL of the fork, got %q", b.CloneURL)
+			}
+			if b.Revision.Ref != "refs/heads/ms-viewport" {
+				t.Errorf("expected the source branch of the fork, got %q", b.Revision.Ref)
+			}
+		})
+	}
+}
+
+func TestGitlabBuild_MergeRequestActions(t *testing.T) {
+	tests := []struct {
+		action string
+		oldrev string
+		build  bool
+	}{
+		{"open", "", true},
+		{"reopen", "", true},
+		{"update", "5e2c9b1ae5d7d1c7d3a6d45e4e1ef9c5d4a3b2a1", true},
+		{"update", "", false},
+		{"close", "", false},
+		{"merge", "", false},
+		{"approved", "", false},
+		{"unapproved", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.oldrev, func(t *testing.T) {
+			body := bytes.Replace(readTestdata(t, "gitlab-merge_request-payload.json"),
+				[]byte(`"action": "open"`),
+				[]byte(fmt.Sprintf(`"action": %q, "oldrev": %q`, tt.action, tt.oldrev)), 1)
+			b, err := gitlabBuild(newProject(), "Merge Request Hook", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (b != nil) != tt.build {
+				t.Errorf("expected build %t, got %+v", tt.build, b)
+			}
+		})
+	}
+}
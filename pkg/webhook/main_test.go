@@ -26,6 +26,10 @@ func (s *testStore) CreateBuild(build *brigade.Build) error {
 	return s.err
 }
 
+func (s *testStore) ListProjectBuilds(proj *brigade.Project, opts storage.BuildListOptions) (*storage.BuildList, error) {
+	return storage.PageBuilds(s.builds, opts)
+}
+
 func newTestStore() *testStore {
 	return &testStore{
 		proj: &brigade.Project{
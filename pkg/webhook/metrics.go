@@ -0,0 +1,39 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The reasons that webhook events are rejected for.
+const (
+	reasonMalformedBody   = "malformed_body"
+	reasonProjectNotFound = "project_not_found"
+	reasonUnauthorized    = "unauthorized"
+	reasonInvalidEvent    = "invalid_event"
+	reasonScriptNotFound  = "script_not_found"
+)
+
+var (
+	eventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "gateway",
+		Name:      "events_received_total",
+		Help:      "Number of webhook events received, by provider.",
+	}, []string{"provider"})
+	eventsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "gateway",
+		Name:      "events_rejected_total",
+		Help:      "Number of webhook events rejected, by provider and reason.",
+	}, []string{"provider", "reason"})
+	buildsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "brigade",
+		Subsystem: "gateway",
+		Name:      "builds_created_total",
+		Help:      "Number of builds created from webhook events, by provider and project.",
+	}, []string{"provider", "project"})
+)
+
+func init() {
+	prometheus.MustRegister(eventsReceived, eventsRejected, buildsCreated)
+}
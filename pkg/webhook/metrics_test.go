@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/brigadecore/brigade/pkg/brigade"
+)
+
+func TestMetrics_Rejected(t *testing.T) {
+	received := testutil.ToFloat64(eventsReceived.WithLabelValues("GenericWebhook"))
+	notFound := testutil.ToFloat64(eventsRejected.WithLabelValues("GenericWebhook", reasonProjectNotFound))
+	unauthorized := testutil.ToFloat64(eventsRejected.WithLabelValues("GenericWebhook", reasonUnauthorized))
+
+	for _, url := range []string{
+		"/simpleevents/v1/brigade-fakeProject/fakeCode",
+		"/simpleevents/v1/brigade-fakeProject/wrongCode",
+	} {
+		router := newMockRouterSimpleEvent(newTestStoreWithFakeProjectAndSecret("fakeCode"))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", url, bytes.NewBufferString("{}")))
+	}
+	router := newMockRouterSimpleEvent(newTestStoreWithFakeProject2())
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/simpleevents/v1/brigade-fakeProject/fakeCode", nil))
+
+	if got := testutil.ToFloat64(eventsReceived.WithLabelValues("GenericWebhook")) - received; got != 3 {
+		t.Errorf("expected 3 events received, got %v", got)
+	}
+	if got := testutil.ToFloat64(eventsRejected.WithLabelValues("GenericWebhook", reasonUnauthorized)) - unauthorized; got != 1 {
+		t.Errorf("expected 1 unauthorized event, got %v", got)
+	}
+	if got := testutil.ToFloat64(eventsRejected.WithLabelValues("GenericWebhook", reasonProjectNotFound)) - notFound; got != 1 {
+		t.Errorf("expected 1 event for a missing project, got %v", got)
+	}
+}
+
+func TestMetrics_BuildsCreated(t *testing.T) {
+	created := buildsCreated.WithLabelValues("dockerhub", "brigade-1234")
+	before := testutil.ToFloat64(created)
+
+	store := newTestStore()
+	if err := createBuild(context.Background(), store, &brigade.Build{ProjectID: "brigade-1234", Provider: "dockerhub"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(created) - before; got != 1 {
+		t.Errorf("expected 1 build created, got %v", got)
+	}
+
+	store.err = errors.New("store unavailable")
+	if err := createBuild(context.Background(), store, &brigade.Build{ProjectID: "brigade-1234", Provider: "dockerhub"}); err == nil {
+		t.Error("expected an error")
+	}
+	if got := testutil.ToFloat64(created) - before; got != 1 {
+		t.Errorf("expected a failed build not to be counted, got %v", got)
+	}
+}
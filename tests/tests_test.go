@@ -78,7 +78,7 @@ func generate() (payload []byte, hmac string) {
 	if err != nil {
 		panic(err)
 	}
-	hmac = webhook.SHA1HMAC([]byte(proj.SharedSecret), out)
+	hmac = webhook.SHA256HMAC([]byte(proj.SharedSecret), out)
 	return out, hmac
 }
 
@@ -90,8 +90,8 @@ func TestFunctional(t *testing.T) {
 		URL:    &url.URL{Scheme: "http", Host: "localhost:7744", Path: "/events/github"},
 		Body:   ioutil.NopCloser(bytes.NewReader(payload)),
 		Header: http.Header{
-			"X-Github-Event":  []string{"push"},
-			"X-Hub-Signature": []string{hmac},
+			"X-Github-Event":      []string{"push"},
+			"X-Hub-Signature-256": []string{hmac},
 		},
 	}}
 